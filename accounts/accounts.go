@@ -113,6 +113,13 @@ type Wallet interface {
 	// the account in a keystore).
 	SignTx(account Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
 
+	// SignTypedData requests the wallet to sign the given EIP-712 structured data.
+	//
+	// Contrary to SignHash, the wallet receives the full typed message, so hardware
+	// wallets may display the signing domain and message to the user before signing.
+	// Wallets unable to do so return ErrNotSupported.
+	SignTypedData(account Account, data *TypedData) ([]byte, error)
+
 	// SignHashWithPassphrase requests the wallet to sign the given hash with the
 	// given passphrase as extra authentication information.
 	//
@@ -126,6 +133,10 @@ type Wallet interface {
 	// It looks up the account specified either solely via its address contained within,
 	// or optionally with the aid of any location metadata from the embedded URL field.
 	SignTxWithPassphrase(account Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+
+	// SignTypedDataWithPassphrase requests the wallet to sign the given EIP-712
+	// structured data, with the given passphrase as extra authentication information.
+	SignTypedDataWithPassphrase(account Account, passphrase string, data *TypedData) ([]byte, error)
 }
 
 // Backend is a "wallet provider" that may contain a batch of accounts they can
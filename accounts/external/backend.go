@@ -0,0 +1,298 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an accounts.Backend delegating all signing to a
+// standalone signer process over JSON-RPC.
+package external
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	xdn "github.com/xdn/go-xdn"
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/crypto"
+	"github.com/xdn/go-xdn/event"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rpc"
+)
+
+// ExternalScheme is the protocol scheme prefixing external signer URLs.
+const ExternalScheme = "extapi"
+
+// listTimeout is the time the signer (and its user, if listing requires manual
+// approval) has to reveal the accounts.
+const listTimeout = time.Minute
+
+// ExternalBackend is an accounts.Backend exposing a single external signer.
+type ExternalBackend struct {
+	signers []accounts.Wallet
+}
+
+// NewExternalBackend connects to the external signer at the given endpoint. HTTP
+// endpoints are authenticated with auth, if set.
+func NewExternalBackend(endpoint string, auth rpc.ClientAuth) (*ExternalBackend, error) {
+	signer, err := NewExternalSigner(endpoint, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalBackend{signers: []accounts.Wallet{signer}}, nil
+}
+
+// Wallets implements accounts.Backend, returning the external signer.
+func (eb *ExternalBackend) Wallets() []accounts.Wallet {
+	return eb.signers
+}
+
+// Subscribe implements accounts.Backend. The external signer never changes, so
+// no events are ever fired.
+func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// ExternalSigner implements accounts.Wallet on top of a signer's account_ API.
+// Passwords never leave the signer, so the passphrase based signing methods
+// are not supported.
+type ExternalSigner struct {
+	client   *rpc.Client
+	endpoint string
+
+	cache  []accounts.Account // Accounts last revealed by the signer, nil if never listed
+	denied error              // Failure of the last listing, until the next successful one
+	lock   sync.RWMutex
+}
+
+// NewExternalSigner connects to the external signer at the given endpoint. HTTP
+// endpoints are authenticated with auth, if set.
+func NewExternalSigner(endpoint string, auth rpc.ClientAuth) (*ExternalSigner, error) {
+	var (
+		client *rpc.Client
+		err    error
+	)
+	if auth != nil && (strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")) {
+		client, err = rpc.DialHTTPWithAuth(endpoint, auth)
+	} else {
+		client, err = rpc.Dial(endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalSigner{client: client, endpoint: endpoint}, nil
+}
+
+// URL implements accounts.Wallet, returning the endpoint of the signer.
+func (api *ExternalSigner) URL() accounts.URL {
+	return accounts.URL{Scheme: ExternalScheme, Path: api.endpoint}
+}
+
+// Status implements accounts.Wallet, reporting whxdner the signer revealed its
+// accounts yet, or why it refused to.
+func (api *ExternalSigner) Status() (string, error) {
+	api.lock.RLock()
+	defer api.lock.RUnlock()
+
+	if api.denied != nil {
+		return "Listing failed", api.denied
+	}
+	if api.cache == nil {
+		return "Not listed", nil
+	}
+	return "Ok", nil
+}
+
+// Open implements accounts.Wallet, (re)fetching the account list from the
+// signer. Depending on its rules, the signer may require manual approval.
+func (api *ExternalSigner) Open(passphrase string) error {
+	return api.listAccounts()
+}
+
+// Close implements accounts.Wallet, but is a noop since the connection is kept
+// open for the lifetime of the backend.
+func (api *ExternalSigner) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning the accounts revealed by the
+// signer when it was last opened. The signer is never contacted from here, as
+// listing may wait on manual approval.
+func (api *ExternalSigner) Accounts() []accounts.Account {
+	api.lock.RLock()
+	defer api.lock.RUnlock()
+
+	return append([]accounts.Account{}, api.cache...)
+}
+
+// listAccounts retrieves the account list from the signer and caches it. A
+// failure (e.g. the user denying the listing) is cached too, and reported by
+// the wallet status until the next successful listing.
+func (api *ExternalSigner) listAccounts() error {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	var addresses []common.Address
+	if err := api.client.CallContext(ctx, &addresses, "account_list"); err != nil {
+		log.Warn("Failed to list external signer accounts", "url", api.URL(), "err", err)
+
+		api.lock.Lock()
+		api.denied = err
+		api.lock.Unlock()
+		return err
+	}
+	accs := make([]accounts.Account, len(addresses))
+	for i, address := range addresses {
+		accs[i] = accounts.Account{Address: address, URL: api.URL()}
+	}
+	api.lock.Lock()
+	api.cache, api.denied = accs, nil
+	api.lock.Unlock()
+
+	return nil
+}
+
+// Contains implements accounts.Wallet, returning whxdner the account is among
+// the ones revealed by the signer.
+func (api *ExternalSigner) Contains(account accounts.Account) bool {
+	for _, acc := range api.Accounts() {
+		if acc.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == acc.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported by external signers.
+func (api *ExternalSigner) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for external signers.
+func (api *ExternalSigner) SelfDerive(base accounts.DerivationPath, chain xdn.ChainStateReader) {}
+
+// SignHash implements accounts.Wallet, but is not supported since the signer
+// refuses to sign opaque hashes. Use account_signData on the signer instead.
+func (api *ExternalSigner) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// signTxResult is the response of account_signTransaction.
+type signTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// SignTx implements accounts.Wallet, requesting the signer to sign the
+// transaction and verifying the result matches the request.
+func (api *ExternalSigner) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := map[string]interface{}{
+		"from":     account.Address,
+		"to":       tx.To(),
+		"gas":      (*hexutil.Big)(tx.Gas()),
+		"gasPrice": (*hexutil.Big)(tx.GasPrice()),
+		"value":    (*hexutil.Big)(tx.Value()),
+		"nonce":    hexutil.Uint64(tx.Nonce()),
+		"data":     hexutil.Bytes(tx.Data()),
+	}
+	var res signTxResult
+	if err := api.client.Call(&res, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	if res.Tx == nil {
+		return nil, errors.New("external signer returned no transaction")
+	}
+	// Make sure the signer signed what was asked, for the expected chain
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	from, err := types.Sender(signer, res.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external signature: %v", err)
+	}
+	if from != account.Address {
+		return nil, fmt.Errorf("external signer used wrong account: have %x, want %x", from, account.Address)
+	}
+	// The signer may adjust the gas, but not what the transaction does
+	if res.Tx.Nonce() != tx.Nonce() {
+		return nil, fmt.Errorf("external signer modified nonce: have %d, want %d", res.Tx.Nonce(), tx.Nonce())
+	}
+	if (res.Tx.To() == nil) != (tx.To() == nil) || (tx.To() != nil && *res.Tx.To() != *tx.To()) {
+		return nil, fmt.Errorf("external signer modified recipient: have %v, want %v", res.Tx.To(), tx.To())
+	}
+	if res.Tx.Value().Cmp(tx.Value()) != 0 {
+		return nil, fmt.Errorf("external signer modified value: have %v, want %v", res.Tx.Value(), tx.Value())
+	}
+	if !bytes.Equal(res.Tx.Data(), tx.Data()) {
+		return nil, errors.New("external signer modified data")
+	}
+	return res.Tx, nil
+}
+
+// SignTypedData implements accounts.Wallet, requesting the signer to sign the
+// typed data via account_signTypedData and verifying the signing account.
+func (api *ExternalSigner) SignTypedData(account accounts.Account, data *accounts.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	var res hexutil.Bytes
+	if err := api.client.Call(&res, "account_signTypedData", account.Address, data); err != nil {
+		return nil, err
+	}
+	if len(res) != 65 || res[64] < 27 {
+		return nil, errors.New("external signer returned invalid signature")
+	}
+	// Transform V from 27/28 back to 0/1 and make sure the right account signed
+	signature := make([]byte, 65)
+	copy(signature, res)
+	signature[64] -= 27
+
+	pubkey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external signature: %v", err)
+	}
+	if from := crypto.PubkeyToAddress(*pubkey); from != account.Address {
+		return nil, fmt.Errorf("external signer used wrong account: have %x, want %x", from, account.Address)
+	}
+	return signature, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, but is not supported since
+// passwords are kept within the signer.
+func (api *ExternalSigner) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but is not supported since
+// passwords are kept within the signer.
+func (api *ExternalSigner) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, but is not supported
+// since passwords are kept within the signer.
+func (api *ExternalSigner) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, data *accounts.TypedData) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/common/math"
+	"github.com/xdn/go-xdn/crypto"
+)
+
+// hdMasterKeySalt is the HMAC key used to derive BIP-32 master keys from seeds.
+var hdMasterKeySalt = []byte("Bitcoin seed")
+
+var errInvalidHDKey = errors.New("invalid hierarchical deterministic key")
+
+// hdKey is a BIP-32 extended private key: a secp256k1 private key along with
+// the chain code needed to derive its children.
+type hdKey struct {
+	key   *big.Int
+	chain []byte
+}
+
+// newMasterKey derives the BIP-32 master key of a seed.
+func newMasterKey(seed []byte) (*hdKey, error) {
+	mac := hmac.New(sha512.New, hdMasterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errInvalidHDKey
+	}
+	return &hdKey{key: key, chain: sum[32:]}, nil
+}
+
+// child derives the child key at the given index, hardened if the index is at
+// least 2^31.
+func (k *hdKey) child(index uint32) (*hdKey, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, math.PaddedBigBytes(k.key, 32)...)
+	} else {
+		x, y := crypto.S256().ScalarBaseMult(math.PaddedBigBytes(k.key, 32))
+		data = compressPoint(x, y)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.chain)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	// The odds of an invalid child are below 2^-127, the spec says to skip it
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, errInvalidHDKey
+	}
+	key := tweak.Add(tweak, k.key)
+	key.Mod(key, n)
+	if key.Sign() == 0 {
+		return nil, errInvalidHDKey
+	}
+	return &hdKey{key: key, chain: sum[32:]}, nil
+}
+
+// derive walks the derivation path from this key and returns the private key at
+// its end.
+func (k *hdKey) derive(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	var err error
+	for _, index := range path {
+		if k, err = k.child(index); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(k.key, 32))
+}
+
+// deriveKey derives the private key at the given path from a BIP-39 seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	return master.derive(path)
+}
+
+// compressPoint encodes a secp256k1 point in the 33 byte compressed format.
+func compressPoint(x, y *big.Int) []byte {
+	point := make([]byte, 33)
+	point[0] = 0x02 | byte(y.Bit(0))
+	math.ReadBits(x, point[1:])
+	return point
+}
@@ -30,6 +30,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -38,12 +39,17 @@ import (
 	"github.com/xdn/go-xdn/core/types"
 	"github.com/xdn/go-xdn/crypto"
 	"github.com/xdn/go-xdn/event"
+	"github.com/pborman/uuid"
 )
 
 var (
 	ErrLocked  = accounts.NewAuthNeededError("password or unlock")
 	ErrNoMatch = errors.New("no key for given address or file")
 	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+	// ErrHDAccount is returned if a key file operation is requested on an account
+	// derived from an HD wallet, which has no key file of its own.
+	ErrHDAccount = errors.New("account is derived from an HD wallet")
 )
 
 // KeyStoreType is the reflect type of a keystore backend.
@@ -63,6 +69,7 @@ type KeyStore struct {
 	unlocked map[common.Address]*unlocked // Currently unlocked account (decrypted private keys)
 
 	wallets     []accounts.Wallet       // Wallet wrappers around the individual key files
+	hdwallets   []*hdWallet             // HD wallets backed by encrypted mnemonic seeds
 	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
 	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
 	updating    bool                    // Whxdner the event notification loop is running
@@ -113,10 +120,11 @@ func (ks *KeyStore) init(keydir string) {
 	for i := 0; i < len(accs); i++ {
 		ks.wallets[i] = &keystoreWallet{account: accs[i], keystore: ks}
 	}
+	ks.hdwallets = loadHDWallets(ks, ks.storage.JoinPath(hdWalletDir))
 }
 
-// Wallets implements accounts.Backend, returning all single-key wallets from the
-// keystore directory.
+// Wallets implements accounts.Backend, returning all single-key wallets and HD
+// wallets from the keystore directory.
 func (ks *KeyStore) Wallets() []accounts.Wallet {
 	// Make sure the list of wallets is in sync with the account cache
 	ks.refreshWallets()
@@ -124,8 +132,12 @@ func (ks *KeyStore) Wallets() []accounts.Wallet {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
-	cpy := make([]accounts.Wallet, len(ks.wallets))
+	cpy := make([]accounts.Wallet, len(ks.wallets), len(ks.wallets)+len(ks.hdwallets))
 	copy(cpy, ks.wallets)
+	for _, wallet := range ks.hdwallets {
+		cpy = append(cpy, wallet)
+	}
+	sort.Slice(cpy, func(i, j int) bool { return cpy[i].URL().Cmp(cpy[j].URL()) < 0 })
 	return cpy
 }
 
@@ -241,6 +253,9 @@ func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
 	if err != nil {
 		return err
 	}
+	if ks.findHDWallet(a) != nil {
+		return ErrHDAccount
+	}
 	// The order is crucial here. The key is dropped from the
 	// cache after the file is gone so that a reload happening in
 	// between won't insert it into the cache again.
@@ -369,30 +384,49 @@ func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout t
 func (ks *KeyStore) Find(a accounts.Account) (accounts.Account, error) {
 	ks.cache.maybeReload()
 	ks.cache.mu.Lock()
-	a, err := ks.cache.find(a)
+	found, err := ks.cache.find(a)
 	ks.cache.mu.Unlock()
-	return a, err
+
+	// Accounts of HD wallets have no key files, look them up separately
+	if err == ErrNoMatch {
+		if wallet := ks.findHDWallet(a); wallet != nil {
+			return accounts.Account{Address: a.Address, URL: wallet.url}, nil
+		}
+	}
+	return found, err
 }
 
-func (ks *KeyStore) getDecryptedKey(a accounts.Account, auth string) (accounts.Account, *Key, error) {
-	a, err := ks.Find(a)
-	if err != nil {
-		return a, nil, err
+// findHDWallet returns the HD wallet tracking the given account, or nil if the
+// account is not derived from any of them.
+func (ks *KeyStore) findHDWallet(a accounts.Account) *hdWallet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, wallet := range ks.hdwallets {
+		if wallet.Contains(a) {
+			return wallet
+		}
 	}
-	key, err := ks.storage.GetKey(a.Address, a.URL.Path, auth)
-	return a, key, err
+	return nil
 }
 
-func (ks *KeyStore) GetDecryptedKey(a accounts.Account, auth string) (accounts.Account, *Key, error) {
+func (ks *KeyStore) getDecryptedKey(a accounts.Account, auth string) (accounts.Account, *Key, error) {
 	a, err := ks.Find(a)
 	if err != nil {
 		return a, nil, err
 	}
+	// If the account belongs to an HD wallet, derive its key from the seed
+	if wallet := ks.findHDWallet(a); wallet != nil {
+		priv, err := wallet.signingKeyWithPassphrase(a, auth)
+		if err != nil {
+			return a, nil, err
+		}
+		return a, &Key{Id: uuid.Parse(wallet.id), Address: a.Address, PrivateKey: priv}, nil
+	}
 	key, err := ks.storage.GetKey(a.Address, a.URL.Path, auth)
 	return a, key, err
 }
 
-
 func (ks *KeyStore) expire(addr common.Address, u *unlocked, timeout time.Duration) {
 	t := time.NewTimer(timeout)
 	defer t.Stop()
@@ -433,13 +467,27 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 	if err != nil {
 		return nil, err
 	}
-	var N, P int
+	N, P := ks.scryptParams()
+	return EncryptKey(key, newPassphrase, N, P)
+}
+
+// ExportRaw decrypts the key of the given account and returns the unencrypted
+// private key bytes. The caller is responsible for not leaking them.
+func (ks *KeyStore) ExportRaw(a accounts.Account, passphrase string) ([]byte, error) {
+	_, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	return crypto.FromECDSA(key.PrivateKey), nil
+}
+
+// scryptParams returns the scrypt parameters to encrypt exported data with.
+func (ks *KeyStore) scryptParams() (int, int) {
 	if store, ok := ks.storage.(*keyStorePassphrase); ok {
-		N, P = store.scryptN, store.scryptP
-	} else {
-		N, P = StandardScryptN, StandardScryptP
+		return store.scryptN, store.scryptP
 	}
-	return EncryptKey(key, newPassphrase, N, P)
+	return StandardScryptN, StandardScryptP
 }
 
 // Import stores the given encrypted JSON key into the key directory.
@@ -479,6 +527,10 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	if err != nil {
 		return err
 	}
+	if ks.findHDWallet(a) != nil {
+		zeroKey(key.PrivateKey)
+		return ErrHDAccount
+	}
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
@@ -494,6 +546,73 @@ func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (account
 	return a, nil
 }
 
+// NewMnemonicWallet generates a new BIP-39 mnemonic and stores an HD wallet for
+// it, encrypted with the passphrase. The mnemonic is returned for backup and is
+// not retrievable afterwards.
+func (ks *KeyStore) NewMnemonicWallet(passphrase string) (accounts.Wallet, string, error) {
+	mnemonic, err := newMnemonic(crand.Reader, mnemonicEntropyBits)
+	if err != nil {
+		return nil, "", err
+	}
+	wallet, err := ks.ImportMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return wallet, mnemonic, nil
+}
+
+// ImportMnemonic stores an HD wallet for the given BIP-39 mnemonic, encrypting
+// its seed with the passphrase. The first account of the default derivation
+// path is pinned to the wallet.
+func (ks *KeyStore) ImportMnemonic(mnemonic string, passphrase string) (accounts.Wallet, error) {
+	seed, err := mnemonicToSeed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	// Derive the first account, also used to detect duplicate imports
+	path := accounts.DefaultBaseDerivationPath
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	// Encrypt the seed up front, it's slow and needs no locking
+	N, P := ks.scryptParams()
+	enc, err := encryptDataV3(seed, passphrase, N, P)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.NewRandom()
+	file := ks.storage.JoinPath(filepath.Join(hdWalletDir, fmt.Sprintf("UTC--%s--%s", toISO8601(time.Now().UTC()), id)))
+
+	wallet := newHDWallet(ks, file, id.String(), enc)
+	wallet.track(address, path)
+	wallet.pinned = append(wallet.pinned, address)
+
+	// Check for duplicates and store the wallet atomically, so concurrent imports
+	// of the same mnemonic can't both succeed
+	ks.mu.Lock()
+	for _, existing := range ks.hdwallets {
+		if existing.Contains(accounts.Account{Address: address}) {
+			ks.mu.Unlock()
+			return nil, fmt.Errorf("wallet already exists: %s", existing.URL())
+		}
+	}
+	if err := wallet.save(); err != nil {
+		ks.mu.Unlock()
+		return nil, err
+	}
+	ks.hdwallets = append(ks.hdwallets, wallet)
+	ks.mu.Unlock()
+
+	ks.updateFeed.Send(accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	return wallet, nil
+}
+
 // zeroKey zeroes a private key in memory.
 func zeroKey(k *ecdsa.PrivateKey) {
 	b := k.D.Bits()
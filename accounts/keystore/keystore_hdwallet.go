@@ -0,0 +1,477 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	xdn "github.com/xdn/go-xdn"
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/crypto"
+	"github.com/xdn/go-xdn/log"
+)
+
+// hdWalletDir is the folder within the keystore holding the HD wallet files.
+const hdWalletDir = "hdwallets"
+
+// hdAccountJSON is a pinned account of an HD wallet file.
+type hdAccountJSON struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+// hdWalletJSON is the on disk format of an HD wallet: its BIP-39 seed encrypted
+// the same way as version 3 key files, along with the pinned accounts.
+type hdWalletJSON struct {
+	Crypto   cryptoJSON      `json:"crypto"`
+	Accounts []hdAccountJSON `json:"accounts"`
+	Id       string          `json:"id"`
+	Version  int             `json:"version"`
+}
+
+// hdWallet implements the accounts.Wallet interface for a hierarchical
+// deterministic wallet backed by an encrypted mnemonic seed in the keystore.
+type hdWallet struct {
+	url      accounts.URL // Location of the wallet file within the keystore
+	keystore *KeyStore    // Keystore where the wallet originates from
+
+	id     string                                     // Unique id of the wallet file
+	crypto cryptoJSON                                 // Encrypted seed of the wallet
+	pinned []common.Address                           // Accounts persisted in the wallet file
+	accs   []accounts.Account                         // Currently tracked accounts
+	paths  map[common.Address]accounts.DerivationPath // Derivation paths of the tracked accounts
+
+	seed []byte // Decrypted seed while the wallet is open, nil otherwise
+
+	deriveNextPath accounts.DerivationPath // Next derivation path for account auto-discovery
+	deriveChain    xdn.ChainStateReader    // Blockchain state reader to discover used account with
+
+	lock sync.RWMutex
+}
+
+// loadHDWallets loads all the HD wallet files from the given directory.
+func loadHDWallets(ks *KeyStore, dir string) []*hdWallet {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to list HD wallets", "dir", dir, "err", err)
+		}
+		return nil
+	}
+	var wallets []*hdWallet
+	for _, fi := range files {
+		if fi.IsDir() || fi.Mode()&os.ModeType != 0 || fi.Name()[0] == '.' {
+			continue
+		}
+		wallet, err := loadHDWallet(ks, filepath.Join(dir, fi.Name()))
+		if err != nil {
+			log.Warn("Failed to load HD wallet", "file", fi.Name(), "err", err)
+			continue
+		}
+		wallets = append(wallets, wallet)
+	}
+	return wallets
+}
+
+// loadHDWallet reads an HD wallet file, without decrypting its seed.
+func loadHDWallet(ks *KeyStore, path string) (*hdWallet, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var enc hdWalletJSON
+	if err := json.Unmarshal(blob, &enc); err != nil {
+		return nil, err
+	}
+	if enc.Version != version {
+		return nil, fmt.Errorf("version not supported: %v", enc.Version)
+	}
+	wallet := newHDWallet(ks, path, enc.Id, enc.Crypto)
+	for _, acc := range enc.Accounts {
+		if !common.IsHexAddress(acc.Address) {
+			return nil, fmt.Errorf("invalid account address %q", acc.Address)
+		}
+		path, err := accounts.ParseDerivationPath(acc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path of %s: %v", acc.Address, err)
+		}
+		address := common.HexToAddress(acc.Address)
+		wallet.track(address, path)
+		wallet.pinned = append(wallet.pinned, address)
+	}
+	return wallet, nil
+}
+
+// newHDWallet creates an HD wallet without any accounts.
+func newHDWallet(ks *KeyStore, path string, id string, enc cryptoJSON) *hdWallet {
+	return &hdWallet{
+		url:      accounts.URL{Scheme: KeyStoreScheme, Path: path},
+		keystore: ks,
+		id:       id,
+		crypto:   enc,
+		paths:    make(map[common.Address]accounts.DerivationPath),
+	}
+}
+
+// save persists the wallet file with the currently pinned accounts.
+//
+// The caller must hold the write lock.
+func (w *hdWallet) save() error {
+	enc := hdWalletJSON{
+		Crypto:   w.crypto,
+		Accounts: make([]hdAccountJSON, len(w.pinned)),
+		Id:       w.id,
+		Version:  version,
+	}
+	for i, address := range w.pinned {
+		enc.Accounts[i] = hdAccountJSON{Address: address.Hex(), Path: w.paths[address].String()}
+	}
+	blob, err := json.Marshal(enc)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(w.url.Path, blob)
+}
+
+// track adds an account to the list of tracked ones, if not yet present.
+//
+// The caller must hold the write lock.
+func (w *hdWallet) track(address common.Address, path accounts.DerivationPath) accounts.Account {
+	account := accounts.Account{Address: address, URL: w.url}
+	if _, ok := w.paths[address]; !ok {
+		w.accs = append(w.accs, account)
+		w.paths[address] = append(accounts.DerivationPath{}, path...)
+	}
+	return account
+}
+
+// URL implements accounts.Wallet, returning the URL of the wallet file.
+func (w *hdWallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whxdner the seed of the wallet
+// is decrypted or not.
+func (w *hdWallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.seed != nil {
+		return "Unlocked", nil
+	}
+	return "Locked", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed of the wallet with the
+// passphrase and keeping it in memory until the wallet is closed. Since wallets
+// are opened without a passphrase when they appear, an empty one is rejected
+// up front instead of wasting a key derivation on it.
+func (w *hdWallet) Open(passphrase string) error {
+	w.lock.Lock()
+	if w.seed != nil {
+		w.lock.Unlock()
+		return accounts.ErrWalletAlreadyOpen
+	}
+	if passphrase == "" {
+		w.lock.Unlock()
+		return accounts.NewAuthNeededError("passphrase")
+	}
+	seed, err := decryptDataV3(w.crypto, passphrase)
+	if err != nil {
+		w.lock.Unlock()
+		return err
+	}
+	w.seed = seed
+	w.lock.Unlock()
+
+	// Notify anyone listening that the wallet can be used (and derived from)
+	w.keystore.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+	return nil
+}
+
+// Close implements accounts.Wallet, zeroing out the decrypted seed.
+func (w *hdWallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	zeroBytes(w.seed)
+	w.seed = nil
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet file or discovered via self-derivation.
+func (w *hdWallet) Accounts() []accounts.Account {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accs))
+	copy(cpy, w.accs)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whxdner a particular account is
+// or is not tracked by this wallet instance.
+func (w *hdWallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists && (account.URL == (accounts.URL{}) || account.URL == w.url)
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts and persisted into the wallet file.
+func (w *hdWallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.seed == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := deriveKey(w.seed, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	if !pin {
+		return accounts.Account{Address: address, URL: w.url}, nil
+	}
+	account := w.track(address, path)
+	for _, pinned := range w.pinned {
+		if pinned == address {
+			return account, nil
+		}
+	}
+	w.pinned = append(w.pinned, address)
+	return account, w.save()
+}
+
+// SelfDerive implements accounts.Wallet, setting a base account derivation path
+// from which the wallet discovers all the accounts with on-chain activity, and
+// the first empty one after them.
+func (w *hdWallet) SelfDerive(base accounts.DerivationPath, chain xdn.ChainStateReader) {
+	w.lock.Lock()
+	w.deriveNextPath = append(accounts.DerivationPath{}, base...)
+	w.deriveChain = chain
+	w.lock.Unlock()
+
+	if chain != nil {
+		go w.selfDerive()
+	}
+}
+
+// selfDerive derives accounts from the next derivation path for as long as they
+// have a non zero balance or nonce, tracking them and the first empty one.
+func (w *hdWallet) selfDerive() {
+	for {
+		// Derive the next account if the wallet is still open
+		w.lock.RLock()
+		if w.seed == nil || w.deriveChain == nil || len(w.deriveNextPath) == 0 {
+			w.lock.RUnlock()
+			return
+		}
+		chain, path := w.deriveChain, append(accounts.DerivationPath{}, w.deriveNextPath...)
+		key, err := deriveKey(w.seed, path)
+		w.lock.RUnlock()
+
+		if err != nil {
+			log.Warn("HD wallet account derivation failed", "url", w.url, "path", path, "err", err)
+			return
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		zeroKey(key)
+
+		// Check the account's status against the current chain state
+		ctx := context.Background()
+		balance, err := chain.BalanceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet balance retrieval failed", "url", w.url, "err", err)
+			return
+		}
+		nonce, err := chain.NonceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet nonce retrieval failed", "url", w.url, "err", err)
+			return
+		}
+		used := balance.Sign() != 0 || nonce != 0
+
+		// Track the account and move on to the next one if it was used
+		w.lock.Lock()
+		if w.deriveChain != chain || w.deriveNextPath.String() != path.String() {
+			w.lock.Unlock()
+			return // Derivation restarted meanwhile
+		}
+		if _, known := w.paths[address]; !known && used {
+			log.Info("HD wallet discovered new account", "address", address, "path", path, "balance", balance, "nonce", nonce)
+		}
+		w.track(address, path)
+		if used {
+			w.deriveNextPath[len(w.deriveNextPath)-1]++
+		}
+		w.lock.Unlock()
+
+		if !used {
+			return
+		}
+	}
+}
+
+// signingKey derives the private key of a tracked account from the given seed.
+//
+// The caller must hold the lock.
+func (w *hdWallet) signingKey(account accounts.Account, seed []byte) (*ecdsa.PrivateKey, error) {
+	path, ok := w.paths[account.Address]
+	if !ok || (account.URL != (accounts.URL{}) && account.URL != w.url) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	if seed == nil {
+		return nil, ErrLocked
+	}
+	return deriveKey(seed, path)
+}
+
+// signingKeyWithPassphrase decrypts the seed with the passphrase and derives
+// the private key of a tracked account from it.
+func (w *hdWallet) signingKeyWithPassphrase(account accounts.Account, passphrase string) (*ecdsa.PrivateKey, error) {
+	w.lock.RLock()
+	enc := w.crypto
+	_, ok := w.paths[account.Address]
+	w.lock.RUnlock()
+
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	seed, err := decryptDataV3(enc, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.signingKey(account, seed)
+}
+
+// SignHash implements accounts.Wallet, signing the given hash with the given
+// account if the wallet is open, or the account is unlocked in the keystore.
+func (w *hdWallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	w.lock.RLock()
+	key, err := w.signingKey(account, w.seed)
+	w.lock.RUnlock()
+
+	if err == ErrLocked {
+		// The wallet is closed, but the account might be unlocked in the keystore
+		return w.keystore.SignHash(account, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return crypto.Sign(hash, key)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the
+// given account if the wallet is open, or the account is unlocked in the keystore.
+func (w *hdWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	w.lock.RLock()
+	key, err := w.signingKey(account, w.seed)
+	w.lock.RUnlock()
+
+	if err == ErrLocked {
+		// The wallet is closed, but the account might be unlocked in the keystore
+		return w.keystore.SignTx(account, tx, chainID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return signTx(tx, chainID, key)
+}
+
+// SignTypedData implements accounts.Wallet, signing the hash of the given typed
+// data with the given account if the wallet is open.
+func (w *hdWallet) SignTypedData(account accounts.Account, data *accounts.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHash(account, hash)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, signing the given hash
+// with the given account using passphrase to decrypt the seed.
+func (w *hdWallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	key, err := w.signingKeyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return crypto.Sign(hash, key)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, signing the given transaction
+// with the given account using passphrase to decrypt the seed.
+func (w *hdWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.signingKeyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return signTx(tx, chainID, key)
+}
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, signing the hash of
+// the given typed data with the given account using passphrase to decrypt the
+// seed.
+func (w *hdWallet) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, data *accounts.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHashWithPassphrase(account, passphrase, hash)
+}
+
+// signTx signs a transaction with EIP155 if a chain ID is given, or with the
+// homestead rules otherwise.
+func signTx(tx *types.Transaction, chainID *big.Int, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	if chainID != nil {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+	}
+	return types.SignTx(tx, types.HomesteadSigner{}, key)
+}
+
+// zeroBytes zeroes a byte slice in memory.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -110,6 +110,16 @@ func (w *keystoreWallet) SignTx(account accounts.Account, tx *types.Transaction,
 	return w.keystore.SignTx(account, tx, chainID)
 }
 
+// SignTypedData implements accounts.Wallet, attempting to sign the hash of the
+// given typed data with the given account.
+func (w *keystoreWallet) SignTypedData(account accounts.Account, data *accounts.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHash(account, hash)
+}
+
 // SignHashWithPassphrase implements accounts.Wallet, attempting to sign the
 // given hash with the given account using passphrase as extra authentication.
 func (w *keystoreWallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
@@ -137,3 +147,14 @@ func (w *keystoreWallet) SignTxWithPassphrase(account accounts.Account, passphra
 	// Account seems valid, request the keystore to sign
 	return w.keystore.SignTxWithPassphrase(account, passphrase, tx, chainID)
 }
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, attempting to sign the
+// hash of the given typed data with the given account using passphrase as extra
+// authentication.
+func (w *keystoreWallet) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, data *accounts.TypedData) ([]byte, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHashWithPassphrase(account, passphrase, hash)
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// mnemonicEntropyBits is the entropy of the mnemonics generated by the keystore,
+// resulting in 24 word phrases.
+const mnemonicEntropyBits = 256
+
+var (
+	errMnemonicLength   = errors.New("invalid mnemonic length")
+	errMnemonicChecksum = errors.New("invalid mnemonic checksum")
+)
+
+// newMnemonic generates a BIP-39 mnemonic phrase from the given source of
+// randomness with the given entropy size in bits.
+func newMnemonic(rand io.Reader, bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("invalid mnemonic entropy size %d", bits)
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := io.ReadFull(rand, entropy); err != nil {
+		return "", err
+	}
+	return entropyToMnemonic(entropy), nil
+}
+
+// entropyToMnemonic encodes the entropy together with its checksum into a list
+// of words, 11 bits each.
+func entropyToMnemonic(entropy []byte) string {
+	var (
+		bits     = len(entropy) * 8
+		checksum = sha256.Sum256(entropy)
+		value    = new(big.Int).SetBytes(entropy)
+		words    = make([]string, (bits+bits/32)/11)
+		mask     = big.NewInt(2047)
+	)
+	// Append the checksum bits and split the result into word indexes
+	value.Lsh(value, uint(bits/32))
+	value.Or(value, big.NewInt(int64(checksum[0]>>uint(8-bits/32))))
+
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 11)
+	}
+	return strings.Join(words, " ")
+}
+
+// mnemonicToEntropy decodes a mnemonic phrase, validating its length, words and
+// checksum, and returns the entropy it encodes.
+func mnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errMnemonicLength
+	}
+	value := new(big.Int)
+	for _, word := range words {
+		index := sort.SearchStrings(mnemonicWords[:], word)
+		if index == len(mnemonicWords) || mnemonicWords[index] != word {
+			return nil, fmt.Errorf("invalid mnemonic word %q", word)
+		}
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+	// Strip the checksum and verify it by re-encoding the entropy
+	checksumBits := len(words) / 3
+	value.Rsh(value, uint(checksumBits))
+
+	entropy := make([]byte, (len(words)*11-checksumBits)/8)
+	bytes := value.Bytes()
+	copy(entropy[len(entropy)-len(bytes):], bytes)
+
+	if entropyToMnemonic(entropy) != strings.Join(words, " ") {
+		return nil, errMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// normalizeMnemonic lowercases the mnemonic and collapses all whitespace into
+// single spaces.
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
+
+// mnemonicToSeed validates a mnemonic phrase and derives the BIP-39 seed from
+// it, protected by an optional extra password.
+func mnemonicToSeed(mnemonic string, password string) ([]byte, error) {
+	mnemonic = normalizeMnemonic(mnemonic)
+	if _, err := mnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+password), 2048, 64, sha512.New), nil
+}
@@ -0,0 +1,416 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/common/math"
+	"github.com/xdn/go-xdn/crypto"
+)
+
+// typedDataDomain is the name of the mandatory type describing the signing
+// domain of a typed data message.
+const typedDataDomain = "EIP712Domain"
+
+// maxTypedDataDepth is the maximum nesting depth of structs and arrays accepted
+// while encoding a typed data message, guarding against recursive definitions.
+const maxTypedDataDepth = 32
+
+var (
+	typedIntRegexp   = regexp.MustCompile(`^(u?)int([0-9]*)$`)
+	typedBytesRegexp = regexp.MustCompile(`^bytes([0-9]+)$`)
+	typedArrayRegexp = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+)
+
+// ErrTypedDataInvalid is returned if a typed data message does not conform to
+// its own type definitions.
+var ErrTypedDataInvalid = errors.New("invalid typed data")
+
+// TypedDataField is a single named member of a typed data struct definition.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is a structured message as specified by EIP-712. It carries both
+// the type definitions and the values of the signing domain and the message,
+// allowing wallets to display readable content before signing its hash.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// UnmarshalJSON decodes a typed data message, retaining the full precision of
+// any numeric values instead of rounding them to floats.
+func (td *TypedData) UnmarshalJSON(input []byte) error {
+	type typedData TypedData
+
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+
+	var data typedData
+	if err := dec.Decode(&data); err != nil {
+		return err
+	}
+	*td = TypedData(data)
+	return nil
+}
+
+// Validate checks that the primary type and the domain are defined and that
+// every referenced member type is either atomic, dynamic or a known struct.
+func (td *TypedData) Validate() error {
+	if _, ok := td.Types[typedDataDomain]; !ok {
+		return fmt.Errorf("%v: missing %s type", ErrTypedDataInvalid, typedDataDomain)
+	}
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return fmt.Errorf("%v: unknown primary type %q", ErrTypedDataInvalid, td.PrimaryType)
+	}
+	for name, fields := range td.Types {
+		if name == "" || strings.ContainsAny(name, "(),[] ") {
+			return fmt.Errorf("%v: invalid type name %q", ErrTypedDataInvalid, name)
+		}
+		for _, field := range fields {
+			if field.Name == "" {
+				return fmt.Errorf("%v: unnamed field in type %s", ErrTypedDataInvalid, name)
+			}
+			typ := field.Type
+			for match := typedArrayRegexp.FindStringSubmatch(typ); match != nil; match = typedArrayRegexp.FindStringSubmatch(typ) {
+				typ = match[1]
+			}
+			if _, ok := td.Types[typ]; !ok && !isAtomicType(typ) {
+				return fmt.Errorf("%v: unknown type %q of %s.%s", ErrTypedDataInvalid, field.Type, name, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Hash validates the typed data and returns the digest a wallet needs to sign,
+// being keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+func (td *TypedData) Hash() ([]byte, error) {
+	domain, err := td.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	message, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256([]byte{0x19, 0x01}, domain[:], message[:]), nil
+}
+
+// DomainSeparator returns the struct hash of the signing domain.
+func (td *TypedData) DomainSeparator() (common.Hash, error) {
+	if err := td.Validate(); err != nil {
+		return common.Hash{}, err
+	}
+	return td.HashStruct(typedDataDomain, td.Domain)
+}
+
+// HashStruct returns keccak256(typeHash ‖ encodeData(data)) of the given value
+// interpreted as the named struct type.
+func (td *TypedData) HashStruct(primary string, data map[string]interface{}) (common.Hash, error) {
+	enc, err := td.EncodeData(primary, data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(enc), nil
+}
+
+// TypeHash returns the keccak256 hash of the encoded type of a struct.
+func (td *TypedData) TypeHash(primary string) common.Hash {
+	return crypto.Keccak256Hash([]byte(td.EncodeType(primary)))
+}
+
+// EncodeType returns the canonical signature of a struct type, e.g.
+//
+//     Mail(Person from,Person to,string contents)Person(string name,address wallet)
+//
+// with the primary type first followed by all referenced types sorted by name.
+// Undefined types encode to the empty string.
+func (td *TypedData) EncodeType(primary string) string {
+	deps := td.dependencies(primary, make(map[string]bool))
+	if len(deps) == 0 {
+		return ""
+	}
+	sort.Strings(deps[1:])
+
+	var buf bytes.Buffer
+	for _, dep := range deps {
+		fields := make([]string, 0, len(td.Types[dep]))
+		for _, field := range td.Types[dep] {
+			fields = append(fields, field.Type+" "+field.Name)
+		}
+		buf.WriteString(dep + "(" + strings.Join(fields, ",") + ")")
+	}
+	return buf.String()
+}
+
+// dependencies collects the struct type and all other struct types it references,
+// directly or transitively, with the requested type always first.
+func (td *TypedData) dependencies(primary string, found map[string]bool) []string {
+	for match := typedArrayRegexp.FindStringSubmatch(primary); match != nil; match = typedArrayRegexp.FindStringSubmatch(primary) {
+		primary = match[1]
+	}
+	if found[primary] {
+		return nil
+	}
+	if _, ok := td.Types[primary]; !ok {
+		return nil
+	}
+	found[primary] = true
+
+	deps := []string{primary}
+	for _, field := range td.Types[primary] {
+		deps = append(deps, td.dependencies(field.Type, found)...)
+	}
+	return deps
+}
+
+// EncodeData returns typeHash ‖ enc(value₁) ‖ … ‖ enc(valueₙ) of the given value
+// interpreted as the named struct type.
+func (td *TypedData) EncodeData(primary string, data map[string]interface{}) ([]byte, error) {
+	return td.encodeData(primary, data, 0)
+}
+
+func (td *TypedData) encodeData(primary string, data map[string]interface{}, depth int) ([]byte, error) {
+	if depth > maxTypedDataDepth {
+		return nil, fmt.Errorf("%v: nesting too deep", ErrTypedDataInvalid)
+	}
+	fields, ok := td.Types[primary]
+	if !ok {
+		return nil, fmt.Errorf("%v: unknown type %q", ErrTypedDataInvalid, primary)
+	}
+	if len(data) > len(fields) {
+		return nil, fmt.Errorf("%v: %s has undefined members", ErrTypedDataInvalid, primary)
+	}
+	typeHash := td.TypeHash(primary)
+
+	buf := bytes.NewBuffer(typeHash[:])
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%v: missing member %s.%s", ErrTypedDataInvalid, primary, field.Name)
+		}
+		enc, err := td.encodeValue(field.Type, value, depth)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", primary, field.Name, err)
+		}
+		buf.Write(enc)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValue returns the 32 byte encoding of a single struct member.
+func (td *TypedData) encodeValue(typ string, value interface{}, depth int) ([]byte, error) {
+	// Arrays are encoded as the hash of their concatenated element encodings
+	if match := typedArrayRegexp.FindStringSubmatch(typ); match != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v: %s expects an array, got %T", ErrTypedDataInvalid, typ, value)
+		}
+		if match[2] != "" {
+			if size, err := strconv.Atoi(match[2]); err != nil || size != len(items) {
+				return nil, fmt.Errorf("%v: %s expects %s items, got %d", ErrTypedDataInvalid, typ, match[2], len(items))
+			}
+		}
+		var buf bytes.Buffer
+		for _, item := range items {
+			enc, err := td.encodeValue(match[1], item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(enc)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+	// Nested structs are encoded by their struct hash
+	if _, ok := td.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v: %s expects an object, got %T", ErrTypedDataInvalid, typ, value)
+		}
+		enc, err := td.encodeData(typ, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(enc), nil
+	}
+	// Dynamic types are encoded by the hash of their contents
+	switch typ {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v: string expects a string, got %T", ErrTypedDataInvalid, value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+
+	case "bytes":
+		blob, err := parseTypedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(blob), nil
+	}
+	return encodeAtomic(typ, value)
+}
+
+// isAtomicType reports whxdner the type is a valid elementary EIP-712 type.
+func isAtomicType(typ string) bool {
+	switch typ {
+	case "address", "bool", "string", "bytes":
+		return true
+	}
+	if match := typedBytesRegexp.FindStringSubmatch(typ); match != nil {
+		size, err := strconv.Atoi(match[1])
+		return err == nil && size >= 1 && size <= 32
+	}
+	if match := typedIntRegexp.FindStringSubmatch(typ); match != nil {
+		if match[2] == "" {
+			return true
+		}
+		bits, err := strconv.Atoi(match[2])
+		return err == nil && bits >= 8 && bits <= 256 && bits%8 == 0
+	}
+	return false
+}
+
+// encodeAtomic encodes an elementary value into a single 32 byte word.
+func encodeAtomic(typ string, value interface{}) ([]byte, error) {
+	if !isAtomicType(typ) {
+		return nil, fmt.Errorf("%v: unknown type %q", ErrTypedDataInvalid, typ)
+	}
+	switch {
+	case typ == "address":
+		str, ok := value.(string)
+		if !ok || !common.IsHexAddress(str) {
+			return nil, fmt.Errorf("%v: invalid address %v", ErrTypedDataInvalid, value)
+		}
+		return common.LeftPadBytes(common.HexToAddress(str).Bytes(), 32), nil
+
+	case typ == "bool":
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v: bool expects a boolean, got %T", ErrTypedDataInvalid, value)
+		}
+		if flag {
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		}
+		return make([]byte, 32), nil
+
+	case strings.HasPrefix(typ, "bytes"):
+		size, _ := strconv.Atoi(typ[len("bytes"):])
+		blob, err := parseTypedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(blob) != size {
+			return nil, fmt.Errorf("%v: %s expects %d bytes, got %d", ErrTypedDataInvalid, typ, size, len(blob))
+		}
+		return common.RightPadBytes(blob, 32), nil
+	}
+	// Only integer types remain, ensure they fit into their declared size
+	match := typedIntRegexp.FindStringSubmatch(typ)
+	bits := 256
+	if match[2] != "" {
+		bits, _ = strconv.Atoi(match[2])
+	}
+	num, err := parseTypedInteger(value)
+	if err != nil {
+		return nil, err
+	}
+	if match[1] == "u" {
+		if num.Sign() < 0 || num.BitLen() > bits {
+			return nil, fmt.Errorf("%v: %v overflows %s", ErrTypedDataInvalid, num, typ)
+		}
+	} else {
+		limit := new(big.Int).Lsh(common.Big1, uint(bits-1))
+		if num.Cmp(limit) >= 0 || num.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%v: %v overflows %s", ErrTypedDataInvalid, num, typ)
+		}
+	}
+	return math.PaddedBigBytes(math.U256(num), 32), nil
+}
+
+// parseTypedBytes converts a hex string or raw byte slice into bytes.
+func parseTypedBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		blob, err := hexutil.Decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid bytes %q: %v", ErrTypedDataInvalid, v, err)
+		}
+		return blob, nil
+	case []byte:
+		return v, nil
+	case hexutil.Bytes:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%v: bytes expect a hex string, got %T", ErrTypedDataInvalid, value)
+}
+
+// parseTypedInteger converts a JSON number, or a decimal or hex string into a
+// big integer.
+func parseTypedInteger(value interface{}) (*big.Int, error) {
+	var str string
+	switch v := value.(type) {
+	case *big.Int:
+		return new(big.Int).Set(v), nil
+	case json.Number:
+		str = string(v)
+	case string:
+		str = v
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%v: non-integer number %v", ErrTypedDataInvalid, v)
+		}
+		return big.NewInt(int64(v)), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	default:
+		return nil, fmt.Errorf("%v: integer expects a number, got %T", ErrTypedDataInvalid, value)
+	}
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	}
+	num, ok := math.ParseBig256(str)
+	if !ok {
+		return nil, fmt.Errorf("%v: invalid integer %q", ErrTypedDataInvalid, value)
+	}
+	if neg {
+		num.Neg(num)
+	}
+	return num, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/crypto"
+)
+
+// typedMail is the Mail example message of the EIP-712 specification.
+const typedMail = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+// newTypedMail decodes a fresh copy of the EIP-712 Mail example.
+func newTypedMail(t *testing.T) *TypedData {
+	data := new(TypedData)
+	if err := json.Unmarshal([]byte(typedMail), data); err != nil {
+		t.Fatalf("failed to decode typed data: %v", err)
+	}
+	return data
+}
+
+// Tests that the Mail example of EIP-712 encodes and hashes to the reference
+// values of the specification.
+func TestTypedDataReference(t *testing.T) {
+	data := newTypedMail(t)
+
+	if enc, want := data.EncodeType("Mail"), "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; enc != want {
+		t.Errorf("Mail type encoding mismatch: have %s, want %s", enc, want)
+	}
+	if hash, want := data.TypeHash("Mail"), common.HexToHash("0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"); hash != want {
+		t.Errorf("Mail type hash mismatch: have %x, want %x", hash, want)
+	}
+	if hash, want := data.TypeHash("Person"), common.HexToHash("0xb9d8c78acf9b987311de6c7b45bb6a9c8e1bf361fa7fd3467a2163f994c79500"); hash != want {
+		t.Errorf("Person type hash mismatch: have %x, want %x", hash, want)
+	}
+	domain, err := data.DomainSeparator()
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	if want := common.HexToHash("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"); domain != want {
+		t.Errorf("domain separator mismatch: have %x, want %x", domain, want)
+	}
+	message, err := data.HashStruct(data.PrimaryType, data.Message)
+	if err != nil {
+		t.Fatalf("failed to hash message: %v", err)
+	}
+	if want := common.HexToHash("0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"); message != want {
+		t.Errorf("message hash mismatch: have %x, want %x", message, want)
+	}
+	hash, err := data.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash typed data: %v", err)
+	}
+	if want := hexutil.MustDecode("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"); !bytes.Equal(hash, want) {
+		t.Errorf("signing hash mismatch: have %x, want %x", hash, want)
+	}
+	// Sign with the key of Cow and compare against the reference signature
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if addr, want := crypto.PubkeyToAddress(key.PublicKey), common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"); addr != want {
+		t.Fatalf("signer mismatch: have %x, want %x", addr, want)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("failed to sign typed data: %v", err)
+	}
+	sig[64] += 27
+	if want := hexutil.MustDecode("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c"); !bytes.Equal(sig, want) {
+		t.Errorf("signature mismatch: have %x, want %x", sig, want)
+	}
+}
+
+// Tests that undefined types encode to nothing instead of crashing, and that
+// messages not matching their types are rejected.
+func TestTypedDataInvalid(t *testing.T) {
+	data := newTypedMail(t)
+	if enc := data.EncodeType("Unknown"); enc != "" {
+		t.Errorf("undefined type encoding mismatch: have %q, want empty", enc)
+	}
+	if enc := data.EncodeType("Unknown[]"); enc != "" {
+		t.Errorf("undefined array type encoding mismatch: have %q, want empty", enc)
+	}
+	tests := []struct {
+		name   string
+		modify func(data *TypedData)
+	}{
+		{"unknown primary type", func(data *TypedData) { data.PrimaryType = "Unknown" }},
+		{"missing domain type", func(data *TypedData) { delete(data.Types, typedDataDomain) }},
+		{"unknown member type", func(data *TypedData) {
+			data.Types["Mail"] = append(data.Types["Mail"], TypedDataField{Name: "attachment", Type: "File"})
+		}},
+		{"mistyped member", func(data *TypedData) { data.Message["contents"] = 5 }},
+		{"mistyped address", func(data *TypedData) {
+			data.Message["to"] = map[string]interface{}{"name": "Bob", "wallet": "0x01"}
+		}},
+	}
+	for _, tt := range tests {
+		data := newTypedMail(t)
+		tt.modify(data)
+		if _, err := data.Hash(); err == nil {
+			t.Errorf("%s: invalid typed data hashed", tt.name)
+		}
+	}
+}
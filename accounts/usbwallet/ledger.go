@@ -51,6 +51,7 @@ const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Dnp address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Dnp transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignTypedMessage ledgerOpcode = 0x0c // Signs an EIP-712 typed message after having the user validate the hashes
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1ConfirmFetchAddress     ledgerParam1 = 0x01 // Require a user confirmation before returning the address
@@ -58,6 +59,7 @@ const (
 	ledgerP1ContTransactionData     ledgerParam1 = 0x80 // Subsequent transaction data block for signing
 	ledgerP2DiscardAddressChainCode ledgerParam2 = 0x00 // Do not return the chain code along with the address
 	ledgerP2ReturnAddressChainCode  ledgerParam2 = 0x01 // Require a user confirmation before returning the address
+	ledgerP2TypedMessageHashes      ledgerParam2 = 0x00 // Typed message is sent as domain and message hashes
 )
 
 // errLedgerReplyInvalidHeader is the error message returned by a Ledger data exchange
@@ -170,6 +172,25 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignTypedData implements usbwallet.driver, sending the EIP-712 domain separator
+// and message hash to the Ledger and waiting for the user to confirm or deny the
+// signature.
+//
+// Note, typed data signing is only available from v1.5.0 of the Dnp app, older
+// versions will return an error opposed to blindly signing the final hash.
+func (w *ledgerDriver) SignTypedData(path accounts.DerivationPath, domainHash []byte, messageHash []byte) ([]byte, error) {
+	// If the Dnp app doesn't run, abort
+	if w.offline() {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is capable of signing typed data
+	if w.version[0] < 1 || (w.version[0] == 1 && w.version[1] < 5) {
+		return nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing typed data, please update to v1.5.0 at least", w.version[0], w.version[1], w.version[2])
+	}
+	// All infos gathered and metadata checks out, request signing
+	return w.ledgerSignTypedMessage(path, domainHash, messageHash)
+}
+
 // ledgerVersion retrieves the current version of the Dnp wallet app running
 // on the Ledger wallet.
 //
@@ -365,6 +386,60 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// ledgerSignTypedMessage sends the EIP-712 hashes to the Ledger wallet, and waits
+// for the user to confirm or deny the signature.
+//
+// The typed message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc       | Le
+//   ----+-----+----+----+----------+---------
+//    E0 | 0C  | 00 | 00 | variable | variable
+//
+// Where the input is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Domain separator                                 | 32 bytes
+//   Message hash                                     | 32 bytes
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath []uint32, domainHash []byte, messageHash []byte) ([]byte, error) {
+	// Flatten the derivation path and the hashes into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath), 1+4*len(derivationPath)+64)
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	payload = append(payload, domainHash...)
+	payload = append(payload, messageHash...)
+
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpSignTypedMessage, 0, ledgerP2TypedMessageHashes, payload)
+	if err != nil {
+		return nil, err
+	}
+	// Extract the signature and convert it into the [R || S || V] format
+	if len(reply) != 65 {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	if signature[64] < 27 {
+		return nil, errors.New("reply has invalid signature recovery id")
+	}
+	signature[64] -= 27
+	return signature, nil
+}
+
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
 // message and retrieving the response.
 //
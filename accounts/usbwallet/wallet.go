@@ -29,6 +29,7 @@ import (
 	"github.com/xdn/go-xdn/accounts"
 	"github.com/xdn/go-xdn/common"
 	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/crypto"
 	"github.com/xdn/go-xdn/log"
 	"github.com/karalabe/hid"
 )
@@ -66,6 +67,11 @@ type driver interface {
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
 	// or deny the transaction.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
+
+	// SignTypedData sends the EIP-712 domain separator and message hash to the USB
+	// device and waits for the user to confirm or deny the signature. The returned
+	// signature is in the [R || S || V] format where V is 0 or 1.
+	SignTypedData(path accounts.DerivationPath, domainHash []byte, messageHash []byte) ([]byte, error)
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -547,6 +553,66 @@ func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	return signed, nil
 }
 
+// SignTypedData implements accounts.Wallet. It sends the domain separator and
+// message hash of the typed data over to the hardware wallet to request a
+// confirmation from the user. It returns either the signature or a failure if
+// the user denied signing or the device does not support typed data.
+func (w *wallet) SignTypedData(account accounts.Account, data *accounts.TypedData) ([]byte, error) {
+	// Hash the typed data up front to reject invalid messages without the device
+	domainHash, err := data.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	messageHash, err := data.HashStruct(data.PrimaryType, data.Message)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
+	defer w.stateLock.RUnlock()
+
+	// If the wallet is closed, abort
+	if w.device == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Make sure the requested account is contained within
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	// All infos gathered and metadata checks out, request signing
+	<-w.commsLock
+	defer func() { w.commsLock <- struct{}{} }()
+
+	// Ensure the device isn't screwed with while user confirmation is pending
+	// TODO(karalabe): remove if hotplug lands on Windows
+	w.hub.commsLock.Lock()
+	w.hub.commsPend++
+	w.hub.commsLock.Unlock()
+
+	defer func() {
+		w.hub.commsLock.Lock()
+		w.hub.commsPend--
+		w.hub.commsLock.Unlock()
+	}()
+	// Sign the typed data and verify the signer to avoid hardware fault surprises
+	signature, err := w.driver.SignTypedData(path, domainHash[:], messageHash[:])
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return nil, err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), signer.Hex())
+	}
+	return signature, nil
+}
+
 // SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
 // data is not supported for Ledger wallets, so this method will always return
 // an error.
@@ -560,3 +626,10 @@ func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase str
 func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
 	return w.SignTx(account, tx, chainID)
 }
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, attempting to sign the
+// given typed data with the given account using passphrase as extra authentication.
+// Since USB wallets don't rely on passphrases, these are silently ignored.
+func (w *wallet) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, data *accounts.TypedData) ([]byte, error) {
+	return w.SignTypedData(account, data)
+}
@@ -114,6 +114,10 @@ var (
 		Name:  "nostack",
 		Usage: "disable stack output",
 	}
+	ConstantinopleFlag = cli.BoolFlag{
+		Name:  "constantinople",
+		Usage: "enable the Byzantium and Constantinople rules (ignored with --prestate)",
+	}
 )
 
 func init() {
@@ -138,6 +142,7 @@ func init() {
 		ReceiverFlag,
 		DisableMemoryFlag,
 		DisableStackFlag,
+		ConstantinopleFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
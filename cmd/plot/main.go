@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"runtime"
 	//"io/ioutil"
 	"github.com/xdn/go-xdn/alecthomas/kingpin.v2"
@@ -17,18 +20,30 @@ var (
 	app = kingpin.New("dplot", "tool for wirte data for plot id")
 
 	write = app.Command("write", "wirte plot data for plot id")
-	dataPath = write.Flag("dataPath", "set the data path").Required().String()
+	dataPath = write.Flag("dataPath", "set the data path, comma separated to plot onto several drives").Required().String()
 	singSize = write.Flag("singSize", "set the singSize for sing file as MB GB TB").Required().String()
 	size = write.Flag("size", "set the total size for plot as MB GB TB").Required().String()
 	startNonce = write.Flag("startNonce", "set the start none").Default("314159").String()
 	plotID = write.Flag("plotID", "set the plot ID").Required().String()
+	directIO = write.Flag("directIO", "bypass the page cache with O_DIRECT when writing plot files").Bool()
+
+	merge = app.Command("merge", "merge contiguous plot files into files of a bigger size")
+	mergeFiles = merge.Flag("files", "comma separated plot files to merge").Required().String()
+	mergePath = merge.Flag("dataPath", "set the destination data path").Required().String()
+	mergeSize = merge.Flag("singSize", "set the singSize for merged files as MB GB TB, defaults to one file").String()
+
+	split = app.Command("split", "split a plot file into files of a smaller size")
+	splitFile = split.Flag("file", "the plot file to split").Required().String()
+	splitPath = split.Flag("dataPath", "set the destination data path").Required().String()
+	splitSize = split.Flag("singSize", "set the singSize for split files as MB GB TB").Required().String()
 
 	calc = app.Command("calc", "get plotID for given address")
 	addr = calc.Flag("addr", "given an address").Required().String()
 )
 
 type Param struct {
-	DataPath string
+	DataPaths []string
+	DirectIO bool
 	SingSize int64
 	SingCount int64
 	Count int64
@@ -62,7 +77,8 @@ func makeResult(err error, i int64, count int64, name string) string {
 
 func parseParam() (*Param, error) {
 	param := &Param{
-		DataPath: *dataPath,
+		DataPaths: make([]string, 0),
+		DirectIO: *directIO,
 		SingCount: 0,
 		Count: 0,
 		StartNonce: uint64(0),
@@ -85,6 +101,15 @@ func parseParam() (*Param, error) {
 	param.Count = int64(total) / int64(sing)
 	param.SingSize = param.SingCount * int64(cellSize)
 
+	for _, path := range strings.Split(*dataPath, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			param.DataPaths = append(param.DataPaths, path)
+		}
+	}
+	if len(param.DataPaths) == 0 {
+		return nil, errors.New("no data path given")
+	}
+
 	param.StartNonce, err = strconv.ParseUint(*startNonce, 10, 64)
 	if err != nil {
 		return nil, err
@@ -97,34 +122,21 @@ func parseParam() (*Param, error) {
 	return param, nil
 }
 
-func createFile(path string, name string, size int64) error {
-	f, err := os.Create(path + "/" + name)
+// parseSingCount converts a size as MB GB TB into the number of nonces a plot
+// file of that size holds.
+func parseSingCount(size string) (int64, error) {
+	cellSize, err := units.ParseBase2Bytes("256KB")
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer f.Close()
-
-	battleSize, err := units.ParseBase2Bytes("20MB")
+	sing, err := units.ParseBase2Bytes(size)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	battle := size / int64(battleSize)
-	b := make([]byte, int(battleSize))
-	for i := int64(0); i < battle; i++ {
-		_, err = f.Write(b)
-		if err != nil {
-			return err
-		}
+	if int64(sing) < int64(cellSize) {
+		return 0, fmt.Errorf("size %s is smaller than a single nonce", size)
 	}
-	dual := size % int64(battleSize)
-	if dual > int64(0) {
-		b = make([]byte, dual)
-		_, err = f.Write(b)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return int64(sing) / int64(cellSize), nil
 }
 
 func makeName(plotID uint64, nonce uint64, singCount int64) string {
@@ -137,117 +149,84 @@ type WaitWrite struct {
 }
 
 type ChanWrite struct {
-	F *os.File
+	F *plotFile
 	W []*WaitWrite
+	Seq int64
 	Close bool
 }
 
 func loop(param *Param, ch chan<- int) error {
-	//currentNonce := param.StartNonce
-	threadCount := 2 * runtime.NumCPU() // 设置默认线程数10个
-	writeCount := 100
+	threadCount := 2 * runtime.NumCPU() // 设置默认线程数
+	onceHandle := 256                   // 一次性写入256个Nonce的数据, 64*256 bytes per scoop keeps direct I/O aligned
 
-	chp := make(chan int, threadCount)
-	chw := make(chan *ChanWrite, writeCount)
+	// Every drive gets its own writer, plotting threads only hand over batches
+	writers := newWriteScheduler(param.DataPaths, threadCount, func(w *ChanWrite, err error) {
+		fmt.Printf("%v\r\n", makeResult(err, w.Seq, param.Count, w.F.name))
+	})
 
+	files := make(chan int64, param.Count)
+	for i := int64(0); i < param.Count; i++ {
+		files <- i
+	}
+	close(files)
+
+	var wg sync.WaitGroup
 	for m := 0; m < threadCount; m++ {
-		go func(ki int) error {
-			for i := int64(0); i < param.Count; i++ {
-				if(int(i) % threadCount == ki) {
-					//fmt.Printf("haha ki=%v, i=%v, threadCount=%v\r\n", ki, i, threadCount)
-					currentNonce := param.StartNonce + uint64(i) * uint64(param.SingCount)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range files {
+				currentNonce := param.StartNonce + uint64(i) * uint64(param.SingCount)
+				drive := param.DataPaths[int(i) % len(param.DataPaths)]
 
-					name := makeName(param.PlotID, currentNonce, param.SingCount)
-					err := createFile(param.DataPath, name, param.SingSize)
-					if err != nil {
-						fmt.Printf("%v\r\n", makeResult(err, i, param.Count, name))
-						return err
-					}
-					f, err := os.OpenFile(param.DataPath + "/" + name, os.O_RDWR, 0666)
-					if err != nil {
-						return err
+				name := makeName(param.PlotID, currentNonce, param.SingCount)
+				f, err := createPlotFile(drive, name, param.SingSize, param.DirectIO)
+				if err != nil {
+					fmt.Printf("%v\r\n", makeResult(err, i, param.Count, name))
+					continue
+				}
+				batches := (param.SingCount + int64(onceHandle) - 1) / int64(onceHandle)
+				for j := int64(0); j < batches; j++ {
+					once := onceHandle
+					if rem := param.SingCount - j * int64(onceHandle); rem < int64(onceHandle) {
+						once = int(rem)
 					}
-					onceHandle := 400    // 一次性写入400个Nonce的数据
-					for j := int64(0); j < param.SingCount / int64(onceHandle) + 1; j++ {
-						jf := j
-						once := onceHandle
-						closed := false
-						if j >= param.SingCount / int64(onceHandle) {
-							once = int(param.SingCount) % onceHandle
-							closed = true
-						}
-						if once == 0 {
-							break
-						}
 
-						cells := make([][]byte, once)
-						for p := 0 ; p < once; p++ {
-							cs := poc.GenCellForP(currentNonce, param.PlotID)
-							cells[p] = cs
-							currentNonce++
-						}
-
-						waits := make([]*WaitWrite, 4096)
-
-						//fmt.Printf("jf=%v,once=%v,onceHandle=%v,currentNonce=%v\r\n", jf, once, onceHandle, currentNonce)
-
-						for k := 0; k < 4096; k++ {
-							data := make([]byte, 64 * once)
-							index := int64(64) * param.SingCount * int64(k) + int64(64) * jf * int64(onceHandle)
-
-							for q := 0; q < once; q++ {
-								for r := 0; r < 32; r++ {
-									data[q * 64 + r] = cells[q][32 * (2 * k) + r]
-									data[q * 64 + 32 + r] = cells[q][(8192 - (2 * k + 1)) * 32 + r]
-								}
-							}
-
-							waits[k] = &WaitWrite{
-								Data: data,
-								Index: index,
-							}
+					cells := make([][]byte, once)
+					for p := 0 ; p < once; p++ {
+						cells[p] = poc.GenCellForP(currentNonce, param.PlotID)
+						currentNonce++
+					}
 
-							// chw <- &ChanWrite{
-							// 	F: f,
-							// 	Data: data,
-							// 	Index: index,
-							// 	Close: closed,
-							// }
+					// All scoops of the batch share one aligned buffer, so the
+					// writer can hand them to O_DIRECT without copying
+					size := 64 * once
+					buf := alignedBuffer(4096 * size)
+					waits := make([]*WaitWrite, 4096)
+					for k := 0; k < 4096; k++ {
+						data := buf[k * size : (k + 1) * size]
+						for q := 0; q < once; q++ {
+							copy(data[q * 64 : q * 64 + 32], cells[q][32 * (2 * k) : 32 * (2 * k) + 32])
+							copy(data[q * 64 + 32 : q * 64 + 64], cells[q][(8192 - (2 * k + 1)) * 32 : (8192 - (2 * k + 1)) * 32 + 32])
 						}
-						chw <- &ChanWrite{
-							F: f,
-							W: waits,
-							Close: closed,
+						waits[k] = &WaitWrite{
+							Data: data,
+							Index: int64(64) * param.SingCount * int64(k) + int64(64) * j * int64(onceHandle),
 						}
 					}
-
-					fmt.Printf("%v\r\n", makeResult(err, i, param.Count, name))
+					writers.Schedule(drive, &ChanWrite{
+						F: f,
+						W: waits,
+						Seq: i,
+						Close: j == batches - 1,
+					})
 				}
 			}
-			chp <- 1
-			return nil
-		}(m)
+		}()
 	}
+	wg.Wait()
+	writers.Wait()
 
-	complete := 0
-	for {
-		select {
-		case <-chp:
-			complete++
-			if(complete == threadCount) {
-				goto WAITFOR
-			}
-			//break
-		case w := <-chw:
-			for i := 0; i < len(w.W); i++ {
-				w.F.WriteAt(w.W[i].Data, w.W[i].Index)
-			}
-			if w.Close {
-				w.F.Close()
-			}
-		}
-	}
-WAITFOR:
 	return nil
 }
 
@@ -263,6 +242,38 @@ func main() {
 		ch := make(chan int)
 		loop(param, ch)
 
+	case merge.FullCommand():
+		srcs, err := parsePlotRanges(strings.Split(*mergeFiles, ","))
+		if err != nil {
+			fmt.Printf("err:%v\r\n", err)
+			return
+		}
+		count := int64(srcs[len(srcs)-1].End() - srcs[0].Start)
+		if *mergeSize != "" {
+			if count, err = parseSingCount(*mergeSize); err != nil {
+				fmt.Printf("err:%v\r\n", err)
+				return
+			}
+		}
+		if err := restripe(srcs, *mergePath, count); err != nil {
+			fmt.Printf("err:%v\r\n", err)
+		}
+
+	case split.FullCommand():
+		srcs, err := parsePlotRanges([]string{*splitFile})
+		if err != nil {
+			fmt.Printf("err:%v\r\n", err)
+			return
+		}
+		count, err := parseSingCount(*splitSize)
+		if err != nil {
+			fmt.Printf("err:%v\r\n", err)
+			return
+		}
+		if err := restripe(srcs, *splitPath, count); err != nil {
+			fmt.Printf("err:%v\r\n", err)
+		}
+
 	case calc.FullCommand():
 		address := common.HexToAddress(*addr)
 		plotID := poc.CalcPlotID(address)
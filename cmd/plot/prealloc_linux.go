@@ -0,0 +1,26 @@
+// +build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// directIOSupported reports whether plot files can be opened with O_DIRECT.
+const directIOSupported = true
+
+// preallocate reserves size bytes on disk for f without writing them. When the
+// filesystem does not implement fallocate, the file is extended sparsely.
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return f.Truncate(size)
+	}
+	return err
+}
+
+// openDirect opens an existing plot file for writing, bypassing the page cache.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_DIRECT, 0666)
+}
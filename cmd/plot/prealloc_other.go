@@ -0,0 +1,22 @@
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// directIOSupported reports whether plot files can be opened with O_DIRECT.
+const directIOSupported = false
+
+// preallocate extends f to size bytes. Platforms without fallocate get a sparse
+// file, the blocks are allocated as the scoops are written.
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
+
+// openDirect is unsupported outside linux, callers fall back to buffered writes.
+func openDirect(path string) (*os.File, error) {
+	return nil, errors.New("direct I/O not supported on this platform")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xdn/go-xdn/poc"
+)
+
+// scoopCount is the number of 64 byte scoops every nonce is split into.
+const scoopCount = 4096
+
+// verifySamples is the number of nonces per destination file that are
+// regenerated from scratch to make sure the source plots were sound.
+const verifySamples = 4
+
+// plotRange describes a plot file on disk by the nonce range it covers.
+type plotRange struct {
+	Path   string
+	PlotID uint64
+	Start  uint64
+	Count  int64
+
+	info os.FileInfo // File stats to detect aliasing paths of the same file
+}
+
+// End returns the first nonce after the range.
+func (p *plotRange) End() uint64 {
+	return p.Start + uint64(p.Count)
+}
+
+// parsePlotRange decodes a plot file path of the form plotID_startNonce_count.
+func parsePlotRange(path string) (*plotRange, error) {
+	ss := strings.Split(filepath.Base(path), "_")
+	if len(ss) != 3 {
+		return nil, fmt.Errorf("invalid plot file name: %s", path)
+	}
+	plotID, err := strconv.ParseUint(ss[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plot ID in %s: %v", path, err)
+	}
+	start, err := strconv.ParseUint(ss[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start nonce in %s: %v", path, err)
+	}
+	count, err := strconv.ParseInt(ss[2], 10, 64)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid nonce count in %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != 64*scoopCount*count {
+		return nil, fmt.Errorf("plot file %s has size %d, want %d", path, info.Size(), 64*scoopCount*count)
+	}
+	return &plotRange{Path: path, PlotID: plotID, Start: start, Count: count, info: info}, nil
+}
+
+// parsePlotRanges parses all source files, and ensures they belong to the same
+// plot ID and cover one contiguous nonce range without gaps or overlaps.
+func parsePlotRanges(paths []string) ([]*plotRange, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no source plot files given")
+	}
+	srcs := make([]*plotRange, 0, len(paths))
+	for _, path := range paths {
+		src, err := parsePlotRange(path)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+	sort.Slice(srcs, func(i, j int) bool { return srcs[i].Start < srcs[j].Start })
+
+	for i := 1; i < len(srcs); i++ {
+		if srcs[i].PlotID != srcs[0].PlotID {
+			return nil, fmt.Errorf("plot ID mismatch: %s vs %s", srcs[0].Path, srcs[i].Path)
+		}
+		if srcs[i].Start != srcs[i-1].End() {
+			return nil, fmt.Errorf("nonce ranges not contiguous: %s ends at %d, %s starts at %d",
+				srcs[i-1].Path, srcs[i-1].End(), srcs[i].Path, srcs[i].Start)
+		}
+	}
+	return srcs, nil
+}
+
+// restripe copies the nonces of the source plots into new files of singCount
+// nonces each inside dir. Every destination is verified before any source is
+// removed, so an interrupted run never loses plotted data.
+func restripe(srcs []*plotRange, dir string, singCount int64) error {
+	if singCount <= 0 {
+		return errors.New("destination nonce count must be positive")
+	}
+	var (
+		plotID = srcs[0].PlotID
+		start  = srcs[0].Start
+		end    = srcs[len(srcs)-1].End()
+	)
+	files := make(map[string]*os.File)
+	for _, src := range srcs {
+		f, err := os.Open(src.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files[src.Path] = f
+	}
+	// Write and verify every destination under a temporary name first
+	var (
+		done    []*plotRange
+		cleanup = func() {
+			for _, dst := range done {
+				os.Remove(dst.Path + ".tmp")
+			}
+		}
+	)
+	for nonce := start; nonce < end; nonce += uint64(singCount) {
+		count := singCount
+		if rem := int64(end - nonce); rem < count {
+			count = rem
+		}
+		dst := &plotRange{
+			Path:   filepath.Join(dir, makeName(plotID, nonce, count)),
+			PlotID: plotID,
+			Start:  nonce,
+			Count:  count,
+		}
+		// Paths may be spelled differently (relative, unclean, links), so compare
+		// the files themselves instead of their names
+		if info, err := os.Stat(dst.Path); err == nil {
+			for _, src := range srcs {
+				if os.SameFile(info, src.info) {
+					cleanup()
+					return fmt.Errorf("destination %s would overwrite its source %s", dst.Path, src.Path)
+				}
+			}
+		}
+		done = append(done, dst)
+
+		if err := copyRange(srcs, files, dst); err != nil {
+			cleanup()
+			return err
+		}
+		if err := verifyRange(srcs, files, dst); err != nil {
+			cleanup()
+			return err
+		}
+		fmt.Printf("%v\r\n", makeResult(nil, int64(len(done)), int64(end-start+uint64(singCount)-1)/singCount, dst.Path))
+	}
+	// All destinations are good, move them in place and drop the sources
+	for _, dst := range done {
+		if err := os.Rename(dst.Path+".tmp", dst.Path); err != nil {
+			return err
+		}
+	}
+	for _, src := range srcs {
+		files[src.Path].Close()
+		if err := os.Remove(src.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlap returns the part of src that falls into dst, as a nonce range.
+func overlap(src *plotRange, dst *plotRange) (uint64, uint64) {
+	from, to := src.Start, src.End()
+	if dst.Start > from {
+		from = dst.Start
+	}
+	if dst.End() < to {
+		to = dst.End()
+	}
+	return from, to
+}
+
+// copyRange streams every scoop of the destination's nonce range out of the
+// sources into a freshly allocated temporary file.
+func copyRange(srcs []*plotRange, files map[string]*os.File, dst *plotRange) error {
+	out, err := createPlotFile(filepath.Dir(dst.Path), filepath.Base(dst.Path)+".tmp", 64*scoopCount*dst.Count, false)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 64*dst.Count)
+	for k := int64(0); k < scoopCount; k++ {
+		for _, src := range srcs {
+			from, to := overlap(src, dst)
+			if from >= to {
+				continue
+			}
+			data := buf[:64*(to-from)]
+			if _, err := files[src.Path].ReadAt(data, 64*src.Count*k+64*int64(from-src.Start)); err != nil {
+				out.Close()
+				return fmt.Errorf("read %s: %v", src.Path, err)
+			}
+			if err := out.WriteAt(data, 64*dst.Count*k+64*int64(from-dst.Start)); err != nil {
+				out.Close()
+				return fmt.Errorf("write %s: %v", dst.Path, err)
+			}
+		}
+	}
+	return out.Close()
+}
+
+// verifyRange reads the temporary destination back and compares it scoop by
+// scoop against the sources, then regenerates a few random nonces to catch
+// sources which were already corrupt.
+func verifyRange(srcs []*plotRange, files map[string]*os.File, dst *plotRange) error {
+	out, err := os.Open(dst.Path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	want, have := make([]byte, 64*dst.Count), make([]byte, 64*dst.Count)
+	for k := int64(0); k < scoopCount; k++ {
+		for _, src := range srcs {
+			from, to := overlap(src, dst)
+			if from >= to {
+				continue
+			}
+			size := 64 * (to - from)
+			if _, err := files[src.Path].ReadAt(want[:size], 64*src.Count*k+64*int64(from-src.Start)); err != nil {
+				return err
+			}
+			if _, err := out.ReadAt(have[:size], 64*dst.Count*k+64*int64(from-dst.Start)); err != nil {
+				return err
+			}
+			if !bytes.Equal(want[:size], have[:size]) {
+				return fmt.Errorf("verification failed for %s at scoop %d", dst.Path, k)
+			}
+		}
+	}
+	for i := 0; i < verifySamples; i++ {
+		index := rand.Int63n(dst.Count)
+		cell := poc.GenCellForP(dst.Start+uint64(index), dst.PlotID)
+		for _, k := range []int64{0, rand.Int63n(scoopCount), scoopCount - 1} {
+			if _, err := out.ReadAt(have[:64], 64*dst.Count*k+64*index); err != nil {
+				return err
+			}
+			if !bytes.Equal(have[:32], cell[32*(2*k):32*(2*k)+32]) || !bytes.Equal(have[32:64], cell[(8192-(2*k+1))*32:(8192-(2*k+1))*32+32]) {
+				return fmt.Errorf("nonce %d in %s does not match its plot data", dst.Start+uint64(index), dst.Path)
+			}
+		}
+	}
+	return nil
+}
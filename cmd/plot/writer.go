@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+// alignSize is the block alignment O_DIRECT requires for buffers, offsets and
+// lengths. 4096 covers every sector size found on current drives.
+const alignSize = 4096
+
+// alignedBuffer returns a zeroed byte slice of the given size whose first
+// element sits on an alignSize boundary in memory.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+alignSize)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (alignSize - 1)); rem != 0 {
+		off = alignSize - rem
+	}
+	return buf[off : off+size]
+}
+
+// isAligned reports whether a write of data at offset can go through O_DIRECT.
+func isAligned(data []byte, offset int64) bool {
+	if len(data) == 0 || len(data)%alignSize != 0 || offset%alignSize != 0 {
+		return false
+	}
+	return uintptr(unsafe.Pointer(&data[0]))&(alignSize-1) == 0
+}
+
+// plotFile is a pre-allocated plot file on disk. Aligned writes go through the
+// direct handle (if any), everything else through the buffered one.
+type plotFile struct {
+	name     string
+	buffered *os.File
+	direct   *os.File
+}
+
+// createPlotFile creates the named plot file in dir, reserves size bytes for it
+// and optionally opens a second handle that bypasses the page cache.
+func createPlotFile(dir string, name string, size int64, directIO bool) (*plotFile, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := preallocate(f, size); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("preallocate %s: %v", path, err)
+	}
+	pf := &plotFile{name: name, buffered: f}
+	if directIO && directIOSupported {
+		if pf.direct, err = openDirect(path); err != nil {
+			// Not every filesystem supports O_DIRECT (tmpfs, some FUSE mounts),
+			// keep plotting through the page cache instead of failing.
+			fmt.Printf("direct I/O unavailable for %s, using buffered writes: %v\r\n", path, err)
+			pf.direct = nil
+		}
+	}
+	return pf, nil
+}
+
+// WriteAt writes data at offset, using the direct handle when the request is
+// properly aligned.
+func (p *plotFile) WriteAt(data []byte, offset int64) error {
+	f := p.buffered
+	if p.direct != nil && isAligned(data, offset) {
+		f = p.direct
+	}
+	_, err := f.WriteAt(data, offset)
+	return err
+}
+
+// Close flushes and closes both handles of the plot file.
+func (p *plotFile) Close() error {
+	var err error
+	if p.direct != nil {
+		err = p.direct.Close()
+	}
+	if serr := p.buffered.Sync(); serr != nil && err == nil {
+		err = serr
+	}
+	if cerr := p.buffered.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeScheduler owns one writer goroutine per drive, so that plotting threads
+// never block on a slow disk while another drive sits idle.
+type writeScheduler struct {
+	queues map[string]chan *ChanWrite
+	wg     sync.WaitGroup
+}
+
+// newWriteScheduler starts a writer for every drive, each buffering up to depth
+// pending batches.
+func newWriteScheduler(drives []string, depth int, report func(w *ChanWrite, err error)) *writeScheduler {
+	s := &writeScheduler{queues: make(map[string]chan *ChanWrite)}
+	for _, drive := range drives {
+		if _, ok := s.queues[drive]; ok {
+			continue
+		}
+		queue := make(chan *ChanWrite, depth)
+		s.queues[drive] = queue
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for w := range queue {
+				var err error
+				for _, wait := range w.W {
+					if err = w.F.WriteAt(wait.Data, wait.Index); err != nil {
+						break
+					}
+				}
+				if w.Close {
+					if cerr := w.F.Close(); err == nil {
+						err = cerr
+					}
+				}
+				if err != nil || w.Close {
+					report(w, err)
+				}
+			}
+		}()
+	}
+	return s
+}
+
+// Schedule queues a batch of scoop writes on the writer of the given drive.
+func (s *writeScheduler) Schedule(drive string, w *ChanWrite) {
+	s.queues[drive] <- w
+}
+
+// Wait closes all queues and blocks until every pending write hit the disk.
+func (s *writeScheduler) Wait() {
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of go-xdn.
+//
+// go-xdn is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-xdn is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-xdn. If not, see <http://www.gnu.org/licenses/>.
+
+// signer is a standalone signing service holding the account keys away from the
+// node, approving every request interactively or through a set of rules.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/xdn/go-xdn/cmd/utils"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/node"
+	"github.com/xdn/go-xdn/params"
+	"github.com/xdn/go-xdn/rpc"
+	"github.com/xdn/go-xdn/signer/core"
+	"github.com/xdn/go-xdn/signer/rules"
+	"github.com/xdn/go-xdn/signer/storage"
+)
+
+func main() {
+	var (
+		keystore    = flag.String("keystore", filepath.Join(node.DefaultDataDir(), "keystore"), "directory of the keystore holding the signing keys")
+		lightKDF    = flag.Bool("lightkdf", false, "reduce key-derivation RAM & CPU usage at some expense of KDF strength")
+		chainID     = flag.Int64("chainid", params.MainnetChainConfig.ChainId.Int64(), "chain id to sign transactions for")
+		ipcPath     = flag.String("ipcpath", filepath.Join(node.DefaultDataDir(), "signer.ipc"), "filename for the IPC socket/pipe (empty to disable)")
+		httpEnabled = flag.Bool("rpc", false, "enable the HTTP-RPC server (requires --rpcjwtsecret)")
+		httpAddr    = flag.String("rpcaddr", "localhost", "HTTP-RPC server listening interface")
+		httpPort    = flag.Int("rpcport", 8550, "HTTP-RPC server listening port")
+		httpSecret  = flag.String("rpcjwtsecret", "", "file holding the hex encoded HMAC secret HTTP-RPC clients authenticate with (JWT)")
+		httpVHosts  = flag.String("rpcvhosts", "localhost", "comma separated list of virtual hostnames accepted by the HTTP-RPC server (* = any)")
+		rulesFile   = flag.String("rules", "", "JavaScript ruleset to approve requests with (interactive approval if empty)")
+		ruleStore   = flag.String("rulestore", "", "JSON file persisting the ruleset's storage (in memory if empty)")
+		credentials = flag.String("credentials", "", "JSON file mapping lowercase addresses to passwords for rule approved requests")
+		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
+	)
+	flag.Parse()
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(*verbosity))
+	log.Root().SetHandler(glogger)
+
+	// Assemble the approval UI, interactive unless rules are given
+	var ui core.SignerUI = core.NewCommandlineUI()
+	if *rulesFile != "" {
+		source, err := ioutil.ReadFile(*rulesFile)
+		if err != nil {
+			utils.Fatalf("Failed to read ruleset: %v", err)
+		}
+		var jsStorage storage.Storage = storage.NewEphemeralStorage()
+		if *ruleStore != "" {
+			if jsStorage, err = storage.NewJSONStorage(*ruleStore); err != nil {
+				utils.Fatalf("Failed to open rule storage: %v", err)
+			}
+		}
+		var creds storage.Storage = storage.NewEphemeralStorage()
+		if *credentials != "" {
+			if info, err := os.Stat(*credentials); err == nil && info.Mode().Perm()&0077 != 0 {
+				log.Warn("Credentials file is accessible by other users", "file", *credentials, "mode", info.Mode())
+			}
+			if creds, err = storage.NewJSONStorage(*credentials); err != nil {
+				utils.Fatalf("Failed to load credentials: %v", err)
+			}
+		}
+		if ui, err = rules.NewRulesetUI(ui, jsStorage, creds, string(source)); err != nil {
+			utils.Fatalf("Failed to load ruleset: %v", err)
+		}
+		log.Info("Loaded signing ruleset", "file", *rulesFile)
+	}
+	// Create the signing API and expose it over the requested endpoints
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", core.NewSignerAPI(*chainID, *keystore, *lightKDF, ui)); err != nil {
+		utils.Fatalf("Failed to register signer API: %v", err)
+	}
+	if *ipcPath != "" {
+		listener, err := rpc.CreateIPCListener(*ipcPath)
+		if err != nil {
+			utils.Fatalf("Failed to start IPC endpoint: %v", err)
+		}
+		defer listener.Close()
+		go server.ServeListener(listener)
+
+		log.Info("IPC endpoint opened", "path", *ipcPath)
+	}
+	if *httpEnabled {
+		// Anyone reaching the endpoint may request signatures, always require
+		// authentication and reject foreign hostnames (DNS rebinding)
+		if *httpSecret == "" {
+			utils.Fatalf("HTTP endpoint requires a JWT secret (--rpcjwtsecret)")
+		}
+		secret, err := rpc.ReadJWTSecret(*httpSecret)
+		if err != nil {
+			utils.Fatalf("Failed to load HTTP JWT secret: %v", err)
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", *httpAddr, *httpPort))
+		if err != nil {
+			utils.Fatalf("Failed to start HTTP endpoint: %v", err)
+		}
+		defer listener.Close()
+
+		httpServer := rpc.NewHTTPServerWithAuth(nil, &rpc.AuthConfig{JWTSecret: secret}, server)
+		httpServer.Handler = newVHostHandler(strings.Split(*httpVHosts, ","), httpServer.Handler)
+		go httpServer.Serve(listener)
+
+		log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", listener.Addr()))
+	}
+	log.Info("Signer started", "keystore", *keystore, "chainid", *chainID)
+
+	// Wait until interrupted
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	<-sigc
+
+	log.Info("Signer shutting down")
+	server.Stop()
+}
+
+// newVHostHandler wraps an HTTP handler, rejecting all requests whose Host header
+// is not among the allowed virtual hostnames.
+func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool)
+	for _, vhost := range vhosts {
+		allowed[strings.ToLower(strings.TrimSpace(vhost))] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host // No port in the header
+		}
+		if !allowed["*"] && !allowed[strings.ToLower(host)] {
+			http.Error(w, "invalid host specified", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -214,3 +214,49 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	log.Info("Exported blockchain to", "file", fn)
 	return nil
 }
+
+// ExportState writes the state of the canonical block with the given number,
+// along with the header chain leading up to it, into the given file.
+func ExportState(blockchain *core.BlockChain, fn string, number uint64) error {
+	log.Info("Exporting state", "file", fn, "number", number)
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	if err := blockchain.ExportState(writer, number); err != nil {
+		return err
+	}
+	log.Info("Exported state", "file", fn)
+	return nil
+}
+
+// ImportState loads a state dump from the given file into an empty chain and
+// sets the dumped block as the new head.
+func ImportState(blockchain *core.BlockChain, fn string) error {
+	log.Info("Importing state", "file", fn)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	block, err := blockchain.ImportState(reader)
+	if err != nil {
+		return err
+	}
+	log.Info("Imported state", "file", fn, "number", block.Number(), "hash", block.Hash())
+	return nil
+}
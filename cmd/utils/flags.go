@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -47,6 +48,8 @@ import (
 	"github.com/xdn/go-xdn/les"
 	"github.com/xdn/go-xdn/log"
 	"github.com/xdn/go-xdn/metrics"
+	"github.com/xdn/go-xdn/metrics/influxdb"
+	"github.com/xdn/go-xdn/metrics/prometheus"
 	"github.com/xdn/go-xdn/node"
 	"github.com/xdn/go-xdn/p2p"
 	"github.com/xdn/go-xdn/p2p/discover"
@@ -55,6 +58,7 @@ import (
 	"github.com/xdn/go-xdn/p2p/netutil"
 	"github.com/xdn/go-xdn/params"
 	whisper "github.com/xdn/go-xdn/whisper/whisperv5"
+	gometrics "github.com/rcrowley/go-metrics"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -117,6 +121,10 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -125,6 +133,14 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (IPC path or url) to delegate transaction signing to",
+	}
+	ExternalSignerJWTSecretFlag = cli.StringFlag{
+		Name:  "signer.jwtsecret",
+		Usage: "Path to a hex encoded HMAC secret for authenticating to an HTTP external signer",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -170,6 +186,24 @@ var (
 		Value: &defaultSyncMode,
 	}
 
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
+	SnapshotFlag = cli.BoolTFlag{
+		Name:  "snapshot",
+		Usage: `Maintain the flat state snapshot for faster state access (use "--snapshot=false" to disable)`,
+	}
+	HistoryBodiesFlag = cli.Uint64Flag{
+		Name:  "history.bodies",
+		Usage: "Number of recent blocks to keep block bodies and receipts for (0 = entire chain)",
+	}
+	HistoryTxLookupFlag = cli.Uint64Flag{
+		Name:  "history.txlookup",
+		Usage: "Number of recent blocks to keep transaction lookup indices for (0 = entire chain)",
+	}
+
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -354,6 +388,41 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	MetricsHTTPFlag = cli.StringFlag{
+		Name:  metrics.MetricsHTTPFlag,
+		Usage: "Listening address of the Prometheus metrics HTTP server (implies --metrics)",
+		Value: "",
+	}
+	MetricsInfluxDBEndpointFlag = cli.StringFlag{
+		Name:  metrics.MetricsInfluxDBFlag,
+		Usage: "InfluxDB API endpoint to push metrics to (implies --metrics)",
+		Value: influxdb.DefaultConfig.Endpoint,
+	}
+	MetricsInfluxDBDatabaseFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.database",
+		Usage: "InfluxDB database name to push metrics into",
+		Value: influxdb.DefaultConfig.Database,
+	}
+	MetricsInfluxDBUsernameFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.username",
+		Usage: "Username to authenticate with the InfluxDB endpoint",
+		Value: influxdb.DefaultConfig.Username,
+	}
+	MetricsInfluxDBPasswordFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.password",
+		Usage: "Password to authenticate with the InfluxDB endpoint",
+		Value: influxdb.DefaultConfig.Password,
+	}
+	MetricsInfluxDBIntervalFlag = cli.DurationFlag{
+		Name:  "metrics.influxdb.interval",
+		Usage: "Time interval between two metrics pushes to InfluxDB",
+		Value: influxdb.DefaultConfig.Interval,
+	}
+	MetricsInfluxDBHostFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.host",
+		Usage: "Host tag attached to all pushed metrics (default = hostname)",
+		Value: influxdb.DefaultConfig.Host,
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -419,6 +488,56 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	RPCAllowMethodsFlag = cli.StringFlag{
+		Name:  "rpc.allowmethods",
+		Usage: "Comma separated list of methods (or namespace_* wildcards) callable over the HTTP-RPC interface",
+		Value: "",
+	}
+	RPCDenyMethodsFlag = cli.StringFlag{
+		Name:  "rpc.denymethods",
+		Usage: "Comma separated list of methods (or namespace_* wildcards) forbidden over the HTTP-RPC interface",
+		Value: "",
+	}
+	WSAllowMethodsFlag = cli.StringFlag{
+		Name:  "ws.allowmethods",
+		Usage: "Comma separated list of methods (or namespace_* wildcards) callable over the WS-RPC interface",
+		Value: "",
+	}
+	WSDenyMethodsFlag = cli.StringFlag{
+		Name:  "ws.denymethods",
+		Usage: "Comma separated list of methods (or namespace_* wildcards) forbidden over the WS-RPC interface",
+		Value: "",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Maximum number of HTTP and WS-RPC requests per second allowed per client (0 = unlimited)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpc.rateburst",
+		Usage: "Number of HTTP and WS-RPC requests a client may issue at once above the rate limit",
+	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of requests in an HTTP and WS-RPC batch, at most the rate burst if rate limited (0 = unlimited)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpc.responselimit",
+		Usage: "Maximum size in bytes of an HTTP and WS-RPC (batch) response (0 = unlimited)",
+	}
+	RPCSlowCallFlag = cli.DurationFlag{
+		Name:  "rpc.slowcall",
+		Usage: "Duration above which RPC method calls are logged as slow (0 = disabled)",
+	}
+	RPCJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a hex encoded HMAC secret for authenticating HTTP and WS-RPC requests with JWTs",
+		Value: "",
+	}
+	RPCAuthTokensFlag = cli.StringFlag{
+		Name:  "rpc.authtokens",
+		Usage: "Comma separated list of static bearer tokens accepted by the HTTP and WS-RPC interfaces",
+		Value: "",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -681,6 +800,12 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCApiFlag.Name) {
 		cfg.HTTPModules = splitAndTrim(ctx.GlobalString(RPCApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(RPCAllowMethodsFlag.Name) {
+		cfg.HTTPAccess.Allow = splitAndTrim(ctx.GlobalString(RPCAllowMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCDenyMethodsFlag.Name) {
+		cfg.HTTPAccess.Deny = splitAndTrim(ctx.GlobalString(RPCDenyMethodsFlag.Name))
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -702,6 +827,47 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(WSApiFlag.Name) {
 		cfg.WSModules = splitAndTrim(ctx.GlobalString(WSApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(WSAllowMethodsFlag.Name) {
+		cfg.WSAccess.Allow = splitAndTrim(ctx.GlobalString(WSAllowMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(WSDenyMethodsFlag.Name) {
+		cfg.WSAccess.Deny = splitAndTrim(ctx.GlobalString(WSDenyMethodsFlag.Name))
+	}
+}
+
+// setRPCLimits configures the resource limits of the HTTP and WebSocket RPC
+// interfaces from the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.RequestRate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCLimits.RequestBurst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCLimits.BatchItems = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+		cfg.RPCLimits.ResponseBytes = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSlowCallFlag.Name) {
+		cfg.RPCSlowCallThreshold = ctx.GlobalDuration(RPCSlowCallFlag.Name)
+	}
+}
+
+// setRPCAuth configures the authentication of the HTTP and WebSocket RPC
+// interfaces from the set command line flags.
+func setRPCAuth(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCJWTSecretFlag.Name) {
+		path, err := filepath.Abs(ctx.GlobalString(RPCJWTSecretFlag.Name))
+		if err != nil {
+			Fatalf("Invalid JWT secret path: %v", err)
+		}
+		cfg.JWTSecret = path
+	}
+	if ctx.GlobalIsSet(RPCAuthTokensFlag.Name) {
+		cfg.AuthTokens = splitAndTrim(ctx.GlobalString(RPCAuthTokensFlag.Name))
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -732,6 +898,20 @@ func makeDatabaseHandles() int {
 	return limit / 2 // Leave half for networking and other stuff
 }
 
+// makeNoPruning validates the --gcmode flag and returns whxdner trie pruning
+// is disabled, i.e. whxdner an archive node was requested.
+func makeNoPruning(ctx *cli.Context) bool {
+	switch gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode {
+	case "full":
+		return false
+	case "archive":
+		return true
+	default:
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	return false
+}
+
 // MakeAddress converts an account specified directly as a hex encoded string or
 // a key index in the key store to an internal account representation.
 func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error) {
@@ -842,6 +1022,8 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCAuth(ctx, cfg)
+	setRPCLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
@@ -864,6 +1046,16 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
+	if ctx.GlobalIsSet(ExternalSignerJWTSecretFlag.Name) {
+		path, err := filepath.Abs(ctx.GlobalString(ExternalSignerJWTSecretFlag.Name))
+		if err != nil {
+			Fatalf("Invalid signer JWT secret path: %v", err)
+		}
+		cfg.ExternalSignerJWTSecret = path
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -985,6 +1177,21 @@ func SetDnpConfig(ctx *cli.Context, stack *node.Node, cfg *xdn.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+
+	cfg.NoPruning = makeNoPruning(ctx)
+	if ctx.GlobalIsSet(SnapshotFlag.Name) {
+		cfg.NoSnapshot = !ctx.GlobalBoolT(SnapshotFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(HistoryBodiesFlag.Name) {
+		cfg.BodyRetention = ctx.GlobalUint64(HistoryBodiesFlag.Name)
+	}
+	if ctx.GlobalIsSet(HistoryTxLookupFlag.Name) {
+		cfg.TxLookupRetention = ctx.GlobalUint64(HistoryTxLookupFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
@@ -1053,6 +1260,34 @@ func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
 	cfg.Assets = ctx.GlobalString(DashboardAssetsFlag.Name)
 }
 
+// SetInfluxDBConfig applies InfluxDB reporter related command line flags to the config.
+func SetInfluxDBConfig(ctx *cli.Context, cfg *influxdb.Config) {
+	if ctx.GlobalIsSet(MetricsInfluxDBEndpointFlag.Name) {
+		cfg.Endpoint = ctx.GlobalString(MetricsInfluxDBEndpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBDatabaseFlag.Name) {
+		cfg.Database = ctx.GlobalString(MetricsInfluxDBDatabaseFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBUsernameFlag.Name) {
+		cfg.Username = ctx.GlobalString(MetricsInfluxDBUsernameFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBPasswordFlag.Name) {
+		cfg.Password = ctx.GlobalString(MetricsInfluxDBPasswordFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBIntervalFlag.Name) {
+		cfg.Interval = ctx.GlobalDuration(MetricsInfluxDBIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBHostFlag.Name) {
+		cfg.Host = ctx.GlobalString(MetricsInfluxDBHostFlag.Name)
+	}
+	// An endpoint may also come from the config file, which the metrics package
+	// can't see at init. Meters created before this point stay disabled.
+	if cfg.Endpoint != "" && !metrics.Enabled {
+		log.Info("Enabling metrics collection", "influxdb", cfg.Endpoint)
+		metrics.Enabled = true
+	}
+}
+
 // RegisterDnpService adds an Dnp client to the stack.
 func RegisterDnpService(stack *node.Node, cfg *xdn.Config) {
 	var err error
@@ -1082,6 +1317,15 @@ func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config) {
 	})
 }
 
+// RegisterInfluxDBService adds an InfluxDB metrics reporter to the stack.
+func RegisterInfluxDBService(stack *node.Node, cfg *influxdb.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return influxdb.New(*cfg, gometrics.DefaultRegistry)
+	}); err != nil {
+		Fatalf("Failed to register the InfluxDB metrics reporter: %v", err)
+	}
+}
+
 // RegisterShhService configures Whisper and adds it to the given node.
 func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
@@ -1114,8 +1358,26 @@ func SetupNetwork(ctx *cli.Context) {
 	params.TargetGasLimit = new(big.Int).SetUint64(ctx.GlobalUint64(TargetGasLimitFlag.Name))
 }
 
+// SetupMetrics starts the Prometheus metrics HTTP server if requested.
+func SetupMetrics(ctx *cli.Context) {
+	address := ctx.GlobalString(MetricsHTTPFlag.Name)
+	if address == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler(gometrics.DefaultRegistry))
+
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/metrics", address))
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Error("Failure in running metrics server", "err", err)
+		}
+	}()
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
-func MakeChainDatabase(ctx *cli.Context, stack *node.Node) xdndb.Database {
+// A readonly database does not freeze chain segments in the background.
+func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) xdndb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name)
 		handles = makeDatabaseHandles()
@@ -1128,7 +1390,22 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) xdndb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
-	return chainDb
+	// Light clients have no full blocks to freeze
+	dbdir := stack.ResolvePath(name)
+	if ctx.GlobalBool(LightModeFlag.Name) || dbdir == "" {
+		return chainDb
+	}
+	freezer := filepath.Join(dbdir, "ancient")
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		if freezer = ctx.GlobalString(AncientFlag.Name); !filepath.IsAbs(freezer) {
+			freezer = stack.ResolvePath(freezer)
+		}
+	}
+	frdb, err := core.NewDatabaseWithFreezer(chainDb, freezer, "xdn/db/"+name+"/ancient/", readonly)
+	if err != nil {
+		Fatalf("Could not open ancient database: %v", err)
+	}
+	return frdb
 }
 
 func MakeGenesis(ctx *cli.Context) *core.Genesis {
@@ -1147,7 +1424,7 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 // MakeChain creates a chain manager from set command line flags.
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb xdndb.Database) {
 	var err error
-	chainDb = MakeChainDatabase(ctx, stack)
+	chainDb = MakeChainDatabase(ctx, stack, false)
 
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
@@ -1165,8 +1442,19 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 			)
 		}
 	}
+	// Offline commands never maintain the state snapshot, a node regenerates it
+	// on startup if the chain moved on in the meantime
+	cache := &core.CacheConfig{
+		Disabled:         makeNoPruning(ctx),
+		SnapshotDisabled: true,
+		TrieNodeLimit:    xdn.DefaultConfig.TrieCache,
+		TrieTimeLimit:    xdn.DefaultConfig.TrieTimeout,
+
+		BodyRetention:     ctx.GlobalUint64(HistoryBodiesFlag.Name),
+		TxLookupRetention: ctx.GlobalUint64(HistoryTxLookupFlag.Name),
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
-	chain, err = core.NewBlockChain(chainDb, config, engine, vmcfg)
+	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
@@ -17,8 +17,12 @@
 package main
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/xdn/go-xdn/accounts"
 	"github.com/xdn/go-xdn/accounts/keystore"
@@ -65,6 +69,16 @@ passwordfile as argument containing the wallet password in plaintext.`,
 		},
 	}
 
+	exportDirFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "Directory to write the exported key files into",
+		Value: ".",
+	}
+	exportRawFlag = cli.BoolFlag{
+		Name:  "raw",
+		Usage: "Export the unencrypted private key instead of an encrypted key file",
+	}
+
 	accountCommand = cli.Command{
 		Name:     "account",
 		Usage:    "Manage accounts",
@@ -72,7 +86,8 @@ passwordfile as argument containing the wallet password in plaintext.`,
 		Description: `
 
 Manage accounts, list all existing accounts, import a private key into a new
-account, create a new account or update an existing account.
+account, create a new account, update an existing account or export the keys
+of existing accounts.
 
 It supports interactive mode, when you are prompted for password as well as
 non-interactive mode where passwords are supplied via a given password file.
@@ -82,7 +97,8 @@ safe environments.
 Make sure you remember the password you gave when creating a new account (with
 either new or import). Without it you are not able to unlock your account.
 
-Note that exporting your key in unencrypted format is NOT supported.
+Exporting your key in unencrypted format is only done on explicit request and
+always into a file readable by the current user alone, never to the terminal.
 
 Keys are stored under <DATADIR>/keystore.
 It is safe to transfer the entire directory or the individual keys therein
@@ -157,7 +173,7 @@ changing your password is only possible interactively.
 			},
 			{
 				Name:   "import",
-				Usage:  "Import a private key into a new account",
+				Usage:  "Import private keys into new accounts",
 				Action: utils.MigrateFlags(accountImport),
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
@@ -165,27 +181,64 @@ changing your password is only possible interactively.
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
 				},
-				ArgsUsage: "<keyFile>",
+				ArgsUsage: "<keyFile> [<keyFile>...]",
 				Description: `
-    gxdn account import <keyfile>
+    gxdn account import <keyfile> [<keyfile>...]
 
-Imports an unencrypted private key from <keyfile> and creates a new account.
-Prints the address.
+Imports the private key from each <keyfile> and creates a new account for it.
+Prints the addresses.
 
-The keyfile is assumed to contain an unencrypted private key in hexadecimal format.
+A keyfile either contains an unencrypted private key in hexadecimal format or
+is an encrypted JSON key file, as written by 'account export'. For the latter
+you are prompted for the passphrase the file was encrypted with.
 
-The account is saved in encrypted format, you are prompted for a passphrase.
+The accounts are saved in encrypted format, you are prompted for a passphrase.
 
 You must remember this passphrase to unlock your account in the future.
 
-For non-interactive use the passphrase can be specified with the -password flag:
+For non-interactive use the passphrases can be specified with the --password
+flag, one line per keyfile. Encrypted key files keep their passphrase:
 
-    gxdn account import [options] <keyfile>
+    gxdn account import [options] <keyfile> [<keyfile>...]
 
 Note:
 As you can directly copy your encrypted accounts to another xdn instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the keys of existing accounts",
+				Action:    utils.MigrateFlags(accountExport),
+				ArgsUsage: "<address> [<address>...]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					exportDirFlag,
+					exportRawFlag,
+				},
+				Description: `
+    gxdn account export [options] <address> [<address>...]
+
+Exports the keys of the given accounts into the directory given by --out.
+
+Each key is written as an encrypted JSON key file, you are prompted for the
+passphrase unlocking the account and another to encrypt the exported file with.
+
+With --raw the unencrypted private key is written in hexadecimal format instead,
+into a file named <address>.key. Anyone who can read that file controls the
+account, so move it somewhere safe and delete it as soon as possible.
+
+Exported files are only readable by the current user and existing files are
+never overwritten. Every export is logged.
+
+For non-interactive use the passphrases can be specified with the --password
+flag, one line per address. Encrypted key files keep the account passphrase:
+
+    gxdn account export [options] <address> [<address>...]
 `,
 			},
 		},
@@ -357,23 +410,115 @@ func importWallet(ctx *cli.Context) error {
 	return nil
 }
 
+// accountImport imports every given key file, either an unencrypted hex private
+// key or an encrypted JSON key file, into a new account of the keystore.
 func accountImport(ctx *cli.Context) error {
-	keyfile := ctx.Args().First()
-	if len(keyfile) == 0 {
+	if len(ctx.Args()) == 0 {
 		utils.Fatalf("keyfile must be given as argument")
 	}
-	key, err := crypto.LoadECDSA(keyfile)
-	if err != nil {
-		utils.Fatalf("Failed to load the private key: %v", err)
-	}
 	stack, _ := makeConfigNode(ctx)
-	passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	passwords := utils.MakePasswordList(ctx)
 
+	for i, keyfile := range ctx.Args() {
+		keyJSON, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			utils.Fatalf("Could not read key file: %v", err)
+		}
+		// Encrypted key files are JSON objects, anything else is taken as a hex key
+		var (
+			acct accounts.Account
+			raw  = json.Unmarshal(keyJSON, new(map[string]interface{})) != nil
+		)
+		if raw {
+			key, err := crypto.LoadECDSA(keyfile)
+			if err != nil {
+				utils.Fatalf("Failed to load the private key from %s: %v", keyfile, err)
+			}
+			passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, i, passwords)
+			if acct, err = ks.ImportECDSA(key, passphrase); err != nil {
+				utils.Fatalf("Could not create the account: %v", err)
+			}
+		} else {
+			passphrase := getPassPhrase(fmt.Sprintf("Unlocking key file %s", keyfile), false, i, passwords)
+			newPassphrase := passphrase
+			if len(passwords) == 0 {
+				newPassphrase = getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, i, nil)
+			}
+			if acct, err = ks.Import(keyJSON, passphrase, newPassphrase); err != nil {
+				utils.Fatalf("Could not import %s: %v", keyfile, err)
+			}
+		}
+		log.Info("Imported account key", "address", acct.Address.Hex(), "file", keyfile, "raw", raw)
+		fmt.Printf("Address: {%x}\n", acct.Address)
+	}
+	return nil
+}
+
+// accountExport writes the keys of the given accounts out of the keystore, either
+// re-encrypted as JSON key files or, on explicit request, as raw private keys.
+func accountExport(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("No accounts specified to export")
+	}
+	outdir, raw := ctx.String(exportDirFlag.Name), ctx.Bool(exportRawFlag.Name)
+	if err := os.MkdirAll(outdir, 0700); err != nil {
+		utils.Fatalf("Could not create export directory: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
 	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
-	acct, err := ks.ImportECDSA(key, passphrase)
-	if err != nil {
-		utils.Fatalf("Could not create the account: %v", err)
+	passwords := utils.MakePasswordList(ctx)
+
+	for i, addr := range ctx.Args() {
+		account, err := utils.MakeAddress(ks, addr)
+		if err != nil {
+			utils.Fatalf("Could not list accounts: %v", err)
+		}
+		if account, err = ks.Find(account); err != nil {
+			utils.Fatalf("Could not find account %s: %v", addr, err)
+		}
+		passphrase := getPassPhrase(fmt.Sprintf("Exporting account %s", account.Address.Hex()), false, i, passwords)
+
+		var (
+			path string
+			data []byte
+		)
+		if raw {
+			key, err := ks.ExportRaw(account, passphrase)
+			if err != nil {
+				utils.Fatalf("Could not export account %s: %v", account.Address.Hex(), err)
+			}
+			path, data = filepath.Join(outdir, fmt.Sprintf("%x.key", account.Address)), []byte(hex.EncodeToString(key))
+		} else {
+			newPassphrase := passphrase
+			if len(passwords) == 0 {
+				newPassphrase = getPassPhrase("Please give a password for the exported key file. Do not forget this password.", true, i, nil)
+			}
+			if data, err = ks.Export(account, passphrase, newPassphrase); err != nil {
+				utils.Fatalf("Could not export account %s: %v", account.Address.Hex(), err)
+			}
+			path = filepath.Join(outdir, filepath.Base(account.URL.Path))
+		}
+		if err := writeKeyFile(path, data); err != nil {
+			utils.Fatalf("Could not write exported key: %v", err)
+		}
+		log.Info("Exported account key", "address", account.Address.Hex(), "file", path, "raw", raw)
+		fmt.Printf("Address: {%x} => %s\n", account.Address, path)
 	}
-	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+// writeKeyFile writes exported key material into a new file only readable by
+// the current user, refusing to overwrite anything already present.
+func writeKeyFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
@@ -24,6 +24,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strconv"
 	"unicode"
 
 	cli "gopkg.in/urfave/cli.v1"
@@ -31,6 +32,7 @@ import (
 	"github.com/xdn/go-xdn/cmd/utils"
 	"github.com/xdn/go-xdn/contracts/release"
 	"github.com/xdn/go-xdn/dashboard"
+	"github.com/xdn/go-xdn/metrics/influxdb"
 	"github.com/xdn/go-xdn/xdn"
 	"github.com/xdn/go-xdn/node"
 	"github.com/xdn/go-xdn/params"
@@ -82,6 +84,7 @@ type gxdnConfig struct {
 	Node      node.Config
 	Dnpstats  xdnstatsConfig
 	Dashboard dashboard.Config
+	InfluxDB  influxdb.Config
 }
 
 func loadConfig(file string, cfg *gxdnConfig) error {
@@ -116,6 +119,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gxdnConfig) {
 		Shh:       whisper.DefaultConfig,
 		Node:      defaultNodeConfig(),
 		Dashboard: dashboard.DefaultConfig,
+		InfluxDB:  influxdb.DefaultConfig,
 	}
 
 	// Load config file.
@@ -138,6 +142,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gxdnConfig) {
 
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
+	utils.SetInfluxDBConfig(ctx, &cfg.InfluxDB)
 
 	return stack, cfg
 }
@@ -173,6 +178,14 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Add the InfluxDB metrics reporter if requested.
+	if cfg.InfluxDB.Endpoint != "" {
+		if cfg.InfluxDB.Network == "" {
+			cfg.InfluxDB.Network = strconv.FormatUint(cfg.Dnp.NetworkId, 10)
+		}
+		utils.RegisterInfluxDBService(stack, &cfg.InfluxDB)
+	}
+
 	// Add the Dnp Stats daemon if requested.
 	if cfg.Dnpstats.URL != "" {
 		utils.RegisterDnpStatsService(stack, cfg.Dnpstats.URL)
@@ -204,6 +217,10 @@ func dumpConfig(ctx *cli.Context) error {
 		cfg.Dnp.Genesis = nil
 		comment += "# Note: this config doesn't contain the genesis block.\n\n"
 	}
+	if cfg.InfluxDB.Password != "" {
+		cfg.InfluxDB.Password = ""
+		comment += "# Note: this config doesn't contain the InfluxDB password.\n\n"
+	}
 
 	out, err := tomlSettings.Marshal(&cfg)
 	if err != nil {
@@ -0,0 +1,242 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of go-xdn.
+//
+// go-xdn is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-xdn is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-xdn. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xdn/go-xdn/cmd/utils"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/console"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/xdndb"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			dbInspectCmd,
+			dbCompactCmd,
+			dbGetCmd,
+			dbDeletePrefixCmd,
+		},
+	}
+	dbInspectCmd = cli.Command{
+		Action:    utils.MigrateFlags(inspect),
+		Name:      "inspect",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Inspect the storage size for each type of data in the database",
+		Description: `
+This commands iterates the entire database and reports the number of entries and
+the storage size taken up by every kind of data: headers, bodies, receipts, tx
+lookups, bloombits, trie nodes, preimages and the ancient store.`,
+	}
+	dbCompactCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbCompact),
+		Name:      "compact",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Compact the entire leveldb database",
+		Description: `
+This command performs a database compaction.
+WARNING: This operation may take a very long time to finish, and may cause database
+corruption if it is aborted during execution!`,
+	}
+	dbGetCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbGet),
+		Name:      "get",
+		ArgsUsage: "<hex-encoded key>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage:       "Show the value of a database key",
+		Description: "This command looks up the specified database key from the database.",
+	}
+	dbDeletePrefixCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbDeletePrefix),
+		Name:      "delete-prefix",
+		ArgsUsage: "<hex-encoded prefix>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Delete every database key starting with a prefix",
+		Description: `
+This command deletes all the database keys starting with the specified prefix.
+WARNING: This is a low-level operation which may cause database corruption!`,
+	}
+)
+
+// openChainDatabase opens the chain database of the node, including its
+// ancient store. The freezer is not started, so no blocks are moved or deleted
+// while the maintenance commands run.
+func openChainDatabase(ctx *cli.Context) xdndb.Database {
+	stack, _ := makeConfigNode(ctx)
+	return utils.MakeChainDatabase(ctx, stack, true)
+}
+
+// openLeveldb opens the leveldb store backing the chain database directly,
+// without touching the ancient store.
+func openLeveldb(ctx *cli.Context) *xdndb.LDBDatabase {
+	stack, _ := makeConfigNode(ctx)
+
+	db, err := stack.OpenDatabase("chaindata", ctx.GlobalInt(utils.CacheFlag.Name), 256)
+	if err != nil {
+		utils.Fatalf("Could not open database: %v", err)
+	}
+	ldb, ok := db.(*xdndb.LDBDatabase)
+	if !ok {
+		utils.Fatalf("Database is not backed by leveldb")
+	}
+	return ldb
+}
+
+func inspect(ctx *cli.Context) error {
+	db := openChainDatabase(ctx)
+	defer db.Close()
+
+	return core.InspectDatabase(db, os.Stdout)
+}
+
+func dbCompact(ctx *cli.Context) error {
+	ldb := openLeveldb(ctx)
+	defer ldb.Close()
+
+	showLeveldbStats(ldb)
+
+	log.Info("Triggering compaction")
+	start := time.Now()
+	if err := ldb.LDB().CompactRange(util.Range{}); err != nil {
+		log.Error("Compact err", "err", err)
+		return err
+	}
+	log.Info("Compaction done", "elapsed", common.PrettyDuration(time.Since(start)))
+
+	showLeveldbStats(ldb)
+	return nil
+}
+
+// showLeveldbStats prints the internal leveldb statistics of the database.
+func showLeveldbStats(ldb *xdndb.LDBDatabase) {
+	if stats, err := ldb.LDB().GetProperty("leveldb.stats"); err != nil {
+		log.Warn("Failed to read database stats", "error", err)
+	} else {
+		fmt.Println(stats)
+	}
+}
+
+func dbGet(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires exactly one argument, the key")
+	}
+	key, err := hexutil.Decode(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Could not decode the key: %v", err)
+	}
+	ldb := openLeveldb(ctx)
+	defer ldb.Close()
+
+	data, err := ldb.Get(key)
+	if err != nil {
+		log.Info("Get operation failed", "key", ctx.Args().Get(0), "err", err)
+		return err
+	}
+	fmt.Printf("key %#x: %#x\n", key, data)
+	return nil
+}
+
+func dbDeletePrefix(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires exactly one argument, the prefix")
+	}
+	prefix, err := hexutil.Decode(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Could not decode the prefix: %v", err)
+	}
+	if len(prefix) == 0 {
+		utils.Fatalf("Refusing to delete the entire database, use removedb instead")
+	}
+	ldb := openLeveldb(ctx)
+	defer ldb.Close()
+
+	confirm, err := console.Stdin.PromptConfirm(fmt.Sprintf("Delete all keys starting with %#x?", prefix))
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Warn("Prefix deletion aborted")
+		return nil
+	}
+	var (
+		start   = time.Now()
+		deleted int
+		batch   = new(leveldb.Batch)
+	)
+	it := ldb.LDB().NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	for it.Next() {
+		batch.Delete(common.CopyBytes(it.Key()))
+		deleted++
+
+		if batch.Len() >= 10000 {
+			if err := ldb.LDB().Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := ldb.LDB().Write(batch, nil); err != nil {
+		return err
+	}
+	log.Info("Deleted keys with prefix", "prefix", fmt.Sprintf("%#x", prefix), "count", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -54,11 +54,14 @@ var (
 	nodeFlags = []cli.Flag{
 		utils.IdentityFlag,
 		utils.UnlockedAccountFlag,
+		utils.ExternalSignerFlag,
+		utils.ExternalSignerJWTSecretFlag,
 		utils.PasswordFileFlag,
 		utils.BootnodesFlag,
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -85,6 +88,10 @@ var (
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.GCModeFlag,
+		utils.SnapshotFlag,
+		utils.HistoryBodiesFlag,
+		utils.HistoryTxLookupFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -113,6 +120,13 @@ var (
 		utils.RPCCORSDomainFlag,
 		utils.DnpStatsURLFlag,
 		utils.MetricsEnabledFlag,
+		utils.MetricsHTTPFlag,
+		utils.MetricsInfluxDBEndpointFlag,
+		utils.MetricsInfluxDBDatabaseFlag,
+		utils.MetricsInfluxDBUsernameFlag,
+		utils.MetricsInfluxDBPasswordFlag,
+		utils.MetricsInfluxDBIntervalFlag,
+		utils.MetricsInfluxDBHostFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
@@ -131,6 +145,17 @@ var (
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.RPCAllowMethodsFlag,
+		utils.RPCDenyMethodsFlag,
+		utils.WSAllowMethodsFlag,
+		utils.WSDenyMethodsFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCBatchLimitFlag,
+		utils.RPCResponseLimitFlag,
+		utils.RPCSlowCallFlag,
+		utils.RPCJWTSecretFlag,
+		utils.RPCAuthTokensFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -154,6 +179,9 @@ func init() {
 		exportCommand,
 		copydbCommand,
 		removedbCommand,
+		dbCommand,
+		// See snapshotcmd.go:
+		snapshotCommand,
 		dumpCommand,
 		// See monitorcmd.go:
 		monitorCommand,
@@ -188,6 +216,7 @@ func init() {
 		}
 		// Start system runtime metrics collection
 		go metrics.CollectProcessMetrics(3 * time.Second)
+		utils.SetupMetrics(ctx)
 
 		utils.SetupNetwork(ctx)
 		return nil
@@ -0,0 +1,179 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of go-xdn.
+//
+// go-xdn is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-xdn is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-xdn. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xdn/go-xdn/cmd/utils"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/core/state/snapshot"
+	"github.com/xdn/go-xdn/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:      "snapshot",
+		Usage:     "A set of commands based on the state snapshot",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			snapshotVerifyCmd,
+			snapshotExportCmd,
+			snapshotImportCmd,
+		},
+	}
+	snapshotVerifyCmd = cli.Command{
+		Action:    utils.MigrateFlags(verifyState),
+		Name:      "verify-state",
+		ArgsUsage: "<root>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Recalculate the state root from the snapshot for verification",
+		Description: `
+xdn snapshot verify-state <state-root>
+will traverse the whole flat state snapshot and recompute the account and
+storage tries from it, checking the result against the given state root. If
+no root is specified, the state of the current head block is verified. The
+node needs to be stopped for the snapshot to be flattened onto the disk.`,
+	}
+	snapshotExportCmd = cli.Command{
+		Action:    utils.MigrateFlags(exportState),
+		Name:      "export",
+		ArgsUsage: "<blockNum> <filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.GCModeFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Export the state of a block into a file",
+		Description: `
+xdn snapshot export <blockNum> <filename>
+will write the header chain up to the given canonical block, the block itself
+and a chunked dump of its entire state trie into the file. The state of the
+block needs to be available, so exporting older blocks requires an archive
+node. If the file ends with .gz, the output will be gzipped.`,
+	}
+	snapshotImportCmd = cli.Command{
+		Action:    utils.MigrateFlags(importState),
+		Name:      "import",
+		ArgsUsage: "<filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.GCModeFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Usage: "Import a state dump and set its block as the chain head",
+		Description: `
+xdn snapshot import <filename>
+will load a state dump created by 'xdn snapshot export' into an empty chain.
+The headers are verified by the consensus engine and every state entry is
+checked against the state root of the dumped block before it is made the new
+head. The bodies and receipts of the preceding blocks are not contained in the
+dump and are treated as pruned.`,
+	}
+)
+
+// headStateRoot returns the state root of the current head block in the chain
+// database.
+func headStateRoot(db core.DatabaseReader) common.Hash {
+	hash := core.GetHeadBlockHash(db)
+	header := core.GetHeader(db, hash, core.GetBlockNumber(db, hash))
+	if header == nil {
+		utils.Fatalf("Failed to load head block %x", hash)
+	}
+	return header.Root
+}
+
+func verifyState(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		utils.Fatalf("This command accepts at most one argument, the state root")
+	}
+	db := openChainDatabase(ctx)
+	defer db.Close()
+
+	var root common.Hash
+	if ctx.NArg() == 1 {
+		blob, err := hexutil.Decode(ctx.Args().First())
+		if err != nil || len(blob) != common.HashLength {
+			utils.Fatalf("Invalid state root %q", ctx.Args().First())
+		}
+		root = common.BytesToHash(blob)
+	} else {
+		root = headStateRoot(db)
+	}
+	if err := snapshot.VerifyState(core.KeyValueStore(db), root); err != nil {
+		log.Error("Failed to verify state snapshot", "root", root, "err", err)
+		return err
+	}
+	log.Info("Verified the state snapshot", "root", root)
+	return nil
+}
+
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments, the block number and the file name.")
+	}
+	number, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		utils.Fatalf("Invalid block number %q: %v", ctx.Args().First(), err)
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := utils.ExportState(chain, ctx.Args().Get(1), number); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func importState(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument, the file name.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := utils.ImportState(chain, ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
@@ -68,12 +68,17 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.SnapshotFlag,
+			utils.HistoryBodiesFlag,
+			utils.HistoryTxLookupFlag,
 			utils.DnpStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -134,6 +139,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "ACCOUNT",
 		Flags: []cli.Flag{
 			utils.UnlockedAccountFlag,
+			utils.ExternalSignerFlag,
+			utils.ExternalSignerJWTSecretFlag,
 			utils.PasswordFileFlag,
 		},
 	},
@@ -149,6 +156,17 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.WSPortFlag,
 			utils.WSApiFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.RPCAllowMethodsFlag,
+			utils.RPCDenyMethodsFlag,
+			utils.WSAllowMethodsFlag,
+			utils.WSDenyMethodsFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
+			utils.RPCBatchLimitFlag,
+			utils.RPCResponseLimitFlag,
+			utils.RPCSlowCallFlag,
+			utils.RPCJWTSecretFlag,
+			utils.RPCAuthTokensFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
@@ -202,6 +220,13 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsHTTPFlag,
+			utils.MetricsInfluxDBEndpointFlag,
+			utils.MetricsInfluxDBDatabaseFlag,
+			utils.MetricsInfluxDBUsernameFlag,
+			utils.MetricsInfluxDBPasswordFlag,
+			utils.MetricsInfluxDBIntervalFlag,
+			utils.MetricsInfluxDBHostFlag,
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
 		}, debug.Flags...),
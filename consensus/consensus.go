@@ -18,6 +18,8 @@
 package consensus
 
 import (
+	"time"
+
 	"github.com/xdn/go-xdn/common"
 	"github.com/xdn/go-xdn/core/state"
 	"github.com/xdn/go-xdn/core/types"
@@ -90,6 +92,9 @@ type Engine interface {
 
 	// APIs returns the RPC APIs this consensus engine provides.
 	APIs(chain ChainReader) []rpc.API
+
+	// Close terminates any background threads maintained by the consensus engine.
+	Close() error
 }
 
 // PoW is a consensus engine based on proof-of-work.
@@ -99,3 +104,11 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// Clock is implemented by consensus engines whose block validity depends on
+// wall time. The chain uses it to decide how far in the future a block may be
+// before it is discarded instead of queued.
+type Clock interface {
+	// Now returns the current time as seen by the consensus engine.
+	Now() time.Time
+}
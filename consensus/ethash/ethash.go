@@ -586,6 +586,12 @@ func (ethash *Dnpash) Hashrate() float64 {
 	return ethash.hashrate.Rate1()
 }
 
+// Close implements consensus.Engine. It's a noop for ethash as there are no
+// background threads.
+func (ethash *Dnpash) Close() error {
+	return nil
+}
+
 // APIs implements consensus.Engine, returning the user facing RPC APIs. Currently
 // that is empty.
 func (ethash *Dnpash) APIs(chain consensus.ChainReader) []rpc.API {
@@ -0,0 +1,28 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdnoc
+
+// API is a user facing RPC API to inspect the state of the PoC consensus engine.
+type API struct {
+	dnpoc *Dnpoc
+}
+
+// ClockDrift retrieves the last measured drift of the local clock against NTP,
+// along with whether it is small enough to allow mining.
+func (api *API) ClockDrift() *ClockStatus {
+	return api.dnpoc.clock.Status()
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdnoc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/p2p/discover"
+)
+
+const (
+	driftTolerance         = 10 * time.Second // Maximum clock drift before the node refuses to mine
+	driftRecheckInterval   = 10 * time.Minute // Time between two NTP drift measurements
+	driftRetryInterval     = time.Minute      // Time before retrying a failed NTP measurement
+	allowedFutureBlockTime = 15 * time.Second // Max time from current time allowed for sealed blocks
+)
+
+// Clock is the time source of the PoC consensus engine. It periodically
+// measures the local clock against NTP and corrects the reported time by the
+// measured drift, so deadlines are compared against network time.
+type Clock struct {
+	tolerance time.Duration // Maximum drift before mining is refused
+	measure   func() (time.Duration, error)
+
+	drift   time.Duration // Last measured drift, positive if the local clock is ahead
+	checked time.Time     // Local time of the last successful measurement
+	err     error         // Error of the last measurement attempt
+
+	lock sync.RWMutex
+	quit chan struct{}
+}
+
+// ClockStatus is the drift measurement summary exposed over RPC.
+type ClockStatus struct {
+	Drift     string    `json:"drift"`
+	Tolerance string    `json:"tolerance"`
+	Checked   time.Time `json:"checked"`
+	Error     string    `json:"error,omitempty"`
+	Mining    bool      `json:"mining"`
+}
+
+// NewClock creates a consensus time source refusing to mine above the given
+// drift tolerance. The NTP measurements start with Start.
+func NewClock(tolerance time.Duration) *Clock {
+	return &Clock{
+		tolerance: tolerance,
+		measure:   discover.ClockDrift,
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start launches the background drift measurement loop.
+func (c *Clock) Start() {
+	go c.loop()
+}
+
+// Stop terminates the background drift measurement loop.
+func (c *Clock) Stop() {
+	close(c.quit)
+}
+
+// loop measures the clock drift on startup and then periodically afterwards.
+func (c *Clock) loop() {
+	for {
+		wait := driftRecheckInterval
+		if err := c.update(); err != nil {
+			wait = driftRetryInterval
+		}
+		select {
+		case <-time.After(wait):
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// update does a single drift measurement and stores the result.
+func (c *Clock) update() error {
+	drift, err := c.measure()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.err = err
+	if err != nil {
+		log.Debug("Failed to measure clock drift", "err", err)
+		return err
+	}
+	c.drift, c.checked = drift, time.Now()
+
+	if drift < -c.tolerance || drift > c.tolerance {
+		log.Warn(fmt.Sprintf("System clock seems off by %v, mining is suspended until it's fixed", drift))
+		log.Warn("Please enable network time synchronisation in system settings.")
+	} else {
+		log.Debug("Consensus clock drift measured", "drift", drift)
+	}
+	return nil
+}
+
+// Now returns the local time corrected by the last measured drift.
+func (c *Clock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return time.Now().Add(-c.drift)
+}
+
+// Drift returns the last measured clock drift.
+func (c *Clock) Drift() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.drift
+}
+
+// Check returns an error if the last measured drift exceeds the tolerance. A
+// failing NTP server is not considered an error, the local clock is trusted.
+func (c *Clock) Check() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.drift < -c.tolerance || c.drift > c.tolerance {
+		return fmt.Errorf("%v: %v > %v", errClockDrift, c.drift, c.tolerance)
+	}
+	return nil
+}
+
+// Status returns the current drift measurement summary.
+func (c *Clock) Status() *ClockStatus {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	status := &ClockStatus{
+		Drift:     c.drift.String(),
+		Tolerance: c.tolerance.String(),
+		Checked:   c.checked,
+		Mining:    c.drift >= -c.tolerance && c.drift <= c.tolerance,
+	}
+	if c.err != nil {
+		status.Error = c.err.Error()
+	}
+	return status
+}
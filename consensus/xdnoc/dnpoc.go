@@ -13,12 +13,9 @@ import (
 	//lru "github.com/hashicorp/golang-lru"
 	"github.com/xdn/go-xdn/params"
 	"time"
-	"io/ioutil"
-	"strings"
-	"strconv"
 	"math/big"
-	"os"
 	"runtime"
+	"sync"
 	"github.com/xdn/go-xdn/common/math"
 	set "gopkg.in/fatih/set.v0"
 )	
@@ -37,14 +34,39 @@ var (
 	errDuplicateUncle    = errors.New("duplicate uncle")
 	errUncleIsAncestor   = errors.New("uncle is ancestor")
 	errDanglingUncle     = errors.New("uncle's parent is not ancestor")
+	errClockDrift        = errors.New("clock drift exceeds tolerance")
 )
 
 type Dnpoc struct {
+	clock       *Clock       // NTP backed time source all deadlines are checked against
+	coordinator *coordinator // Mining round lifecycle manager
 
+	closeOnce sync.Once // Ensures the background goroutines are only stopped once
 }
 
 func New() *Dnpoc {
-	return &Dnpoc{}
+	clock := NewClock(driftTolerance)
+	clock.Start()
+
+	d := &Dnpoc{clock: clock}
+	d.coordinator = newCoordinator(d)
+	return d
+}
+
+// Close implements consensus.Engine, stopping the NTP drift measurements of the
+// consensus clock and any running mining round.
+func (d *Dnpoc) Close() error {
+	d.closeOnce.Do(func() {
+		d.clock.Stop()
+		d.coordinator.close()
+	})
+	return nil
+}
+
+// Now returns the current time according to the consensus clock, implementing
+// consensus.Clock.
+func (d *Dnpoc) Now() time.Time {
+	return d.clock.Now()
 }
 
 func (d *Dnpoc) Author(header *types.Header) (common.Address, error) {
@@ -155,7 +177,7 @@ func (d *Dnpoc) verifyHeader(chain consensus.ChainReader, header *types.Header,
 			return errLargeBlockTime
 		}
 	} else {
-		if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		if header.Time.Cmp(big.NewInt(d.clock.Now().Unix())) > 0 {
 			return consensus.ErrFutureBlock
 		}
 	}
@@ -264,19 +286,7 @@ func (d *Dnpoc) VerifySeal(chain consensus.ChainReader, header *types.Header) er
 		return errors.New("plotID mismatch")
 	}
 
-	genHash := poc.GenHash(gensig, number)
-	scoopID := poc.GetScoopID(genHash)
-
-	cells := poc.GenCell(nonce, plotID)
-	scoop_1 := cells[64 * scoopID : 64 * scoopID + 32]
-	scoop_2 := cells[64 * scoopID + 32 : 64 * scoopID + 64]
-
-	//fmt.Printf("haha scoop_1:%v,scoop_2:%v\r\n", scoop_1, scoop_2)
-
-	target := poc.CalcTarget(scoop_1, scoop_2, gensig)
-	ntarget := new(big.Int).SetBytes((target.Bytes())[24:])
-
-	deadline := poc.CalcDeadLine(ntarget, baseTarget)
+	deadline := poc.CalcNonceDeadLine(gensig, number, plotID, nonce, baseTarget)
 
 	//fmt.Printf("haha gensig=%v,plotID:%v,nonce:%v,scoopID:%v,target:%v,baseTarget:%v\r\n", gensig, plotID, nonce, scoopID, target, baseTarget)
 	//fmt.Printf("haha ntarget:%v\r\n", ntarget)
@@ -288,9 +298,11 @@ func (d *Dnpoc) VerifySeal(chain consensus.ChainReader, header *types.Header) er
 	if !(new(big.Int).Add(deadline, lastTime).Cmp(thisTime) < 0) {
 		return errors.New("deadline not satisfy")
 	}
-	now := time.Now().Unix()
-	if new(big.Int).Sub(thisTime, new(big.Int).SetUint64(uint64(15))).Cmp(new(big.Int).SetUint64(uint64(now))) > 0 {
-		return errors.New("time mismatch")
+	// Blocks slightly ahead are fine, anything further is queued by the chain
+	// until its time comes instead of being rejected outright
+	now := d.clock.Now().Add(allowedFutureBlockTime).Unix()
+	if thisTime.Cmp(new(big.Int).SetUint64(uint64(now))) > 0 {
+		return consensus.ErrFutureBlock
 	}
 
 	return nil
@@ -312,244 +324,15 @@ func (d *Dnpoc) Finalize(chain consensus.ChainReader, header *types.Header, stat
 	return types.NewBlock(header, txs, uncles, receipts), nil
 }
 
-func (d *Dnpoc) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
-	// header := block.Header()
-
-	// fmt.Printf("haha Seal\r\n")
-	// time.Sleep(30 * time.Second)
-	number := block.Number()
-	baseTarget := d.calcBaseTarget(chain, number.Uint64())
-
-	// return block.WithSeal(header), nil
-	abort := make(chan struct{})
-	found := make(chan *types.Block)
-
-	go func() {
-		d.mine(block, baseTarget, abort, found)
-	}()
-
-	var result *types.Block
-	select {
-	case <-stop:
-		// Outside abort, stop all miner threads
-		close(abort)
-	case result = <-found:
-		// One of the threads found a block, abort all others
-		close(abort)
-	}
-	// Wait for all miners to terminate and return the block
-	return result, nil
-}
-
-func (d *Dnpoc) mine(block *types.Block, bt *big.Int, abort chan struct{}, found chan *types.Block) {
-	// Extract some data from the header
-	var (
-		header = block.Header()
-		//hash   = header.HashNoNonce().Bytes()
-		//target = new(big.Int).Div(maxUint256, header.Difficulty)
-		number  = header.Number.Uint64()
-		lastTime = block.LastTime()
-		gensig  = header.GenSig
-
-		baseTarget = new(big.Int).Set(bt)
-
-		minDeadLine = new(big.Int).SetUint64(9999999999)
-		minPubID, minNonce uint64
-
-		addrPlotID = poc.CalcPlotID(header.Coinbase)
-	)
-	// Start generating random nonces until we abort or find a good one
-	// var (
-	// 	attempts = int64(0)
-	// 	nonce    = seed
-	// )
-
-	// 授权逻辑
-	node, err := GetNodeByPlotID(addrPlotID)
-	if err != nil {
-		fmt.Printf("get node by plotid error:%v\r\n", err)
-		return
-	}
-
-	genHash := poc.GenHash(gensig, number)
-	scoopID := poc.GetScoopID(genHash)
-
-	///data/gaom/ndp/test/P_DATA
-	dirBytes, err := ioutil.ReadFile("PLOT")
-	if err != nil {
-		fmt.Printf("read plot file err\r\n")
-		return
-	}
-
-
-	fmt.Printf("tmpnow=%v\r\n", time.Now().UnixNano() / 1e6)
-
-
-	dirs := strings.Split(string(dirBytes), ",")
-	for dx := 0; dx < len(dirs) - 1; dx++ {
-		dirname := dirs[dx]
-		fmt.Printf("dirs=%v, dirname=%v\r\n", dirs, dirname)
-		rd, err := ioutil.ReadDir(dirname)
-		if err != nil {
-			fmt.Printf("read dir err: %v,err:%v\r\n", dirname, err)
-			return
-		}
-		for _, fi := range rd {
-			total := 0
-			index := 0
-			var pubID, nonce uint64
-			var name string
-			var data []byte
-			LABEL_INNER_FOR:
-				for {
-					select {
-					case <- abort:
-						fmt.Printf("Dnpash nonce search aborted\r\n")
-						return
-					default:
-						if total == 0 {
-							name = fi.Name()
-							ss := strings.Split(name, "_")
-							if len(ss) != 3 {
-								break LABEL_INNER_FOR
-							} else {
-								pubID, err = strconv.ParseUint(ss[0], 10, 64)
-								if err != nil {
-									break LABEL_INNER_FOR
-								}
-								if pubID != addrPlotID {
-									fmt.Printf("plotID not same,pubID:%v, addrPlotID:%v\r\n", pubID, addrPlotID)
-									break LABEL_INNER_FOR
-								}
-								nonce, err = strconv.ParseUint(ss[1], 10, 64)
-								if err != nil {
-									break LABEL_INNER_FOR
-								}
-								total, err = strconv.Atoi(ss[2])
-								if err != nil {
-									break LABEL_INNER_FOR
-								}
-								index = 0
-							}
-
-							func () {
-								f, err := os.Open(dirname + "/" + name)
-								defer f.Close()
-								if err != nil {
-									return
-								}
-								data = make([]byte, 64 * total)   // total scoops
-								//offset := (4096 - scoopID) * 64 * total
-								offset := scoopID * 64 * total
-								_, _ = f.ReadAt(data, int64(offset))
-								//fmt.Printf("haha file data:%v\r\n", data[0: 300])
-							}()
-						} else {
-							//fmt.Printf("haha data:%v\r\n", data[64*index:64*index + 64])
-							// auth
-							if nonce + uint64(index) >= node.MinNonce && nonce + uint64(index) <= node.MaxNonce {
-
-								scoop_1 := make([]byte, 32)
-								scoop_2 := make([]byte, 32)
-								for k := 0; k < 32; k++ {
-									scoop_1[k] = data[64 * index + k]
-									scoop_2[k] = data[64 * index + 32 + k] 
-								}
-
-								//fmt.Printf("haha:scoop_1:%v,scoop_2:%v\r\n", scoop_1, scoop_2)
-								target := poc.CalcTarget(scoop_1, scoop_2, gensig)
-								ntarget := new(big.Int).SetBytes((target.Bytes())[24:])
-
-								//fmt.Printf("haha ntarget:%v\r\n", ntarget)
-
-								deadline := poc.CalcDeadLine(ntarget, baseTarget)
-								if deadline.Cmp(minDeadLine) < 0 {  // set the minimum deadline
-									minDeadLine.Set(deadline) 
-									minPubID = pubID
-									minNonce = nonce + uint64(index)
-								}
-
-								now := time.Now().Unix()
-
-								//fmt.Printf("haha scoopID:%v, baseTarget:%v, deadline:%v, minDeadLine:%v, minPubID:%v, minNonce:%v, lastTime:%v, now:%v\r\n", 
-								//	scoopID, baseTarget, deadline, minDeadLine, minPubID, minNonce, lastTime, now)
-
-								if new(big.Int).Add(minDeadLine, lastTime).Cmp(new(big.Int).SetUint64(uint64(now))) < 0 { // found
-									// Correct nonce found, create a new header with it
-									header = types.CopyHeader(header)
-									header.Nonce = types.EncodeNonce(minNonce)
-									header.PlotID = types.EncodeNonce(minPubID)
-									header.GenSig = gensig
-									header.Time = new(big.Int).SetUint64(uint64(now))
-									fmt.Printf("haha baseTarget:%v\r\n", baseTarget)
-									header.BaseTarget = new(big.Int).Set(baseTarget)
-									header.DeadLine = new(big.Int).Set(minDeadLine)
-
-									// Seal and return a block (if still needed)
-									select {
-									case found <- block.WithSeal(header):
-										fmt.Printf("Dnpash nonce found and reported,nonce:%v\r\n", minNonce)
-									case <-abort:
-										fmt.Printf("Dnpash nonce found but discarded,nonce:%v\r\n", minNonce)
-									}
-									return
-								}
-							}
-
-							//time.Sleep(time.Second)
-
-							index++
-							if index == total {
-								break LABEL_INNER_FOR
-							}
-						}
-					}
-				}
-		}
-	}
-
-
-	fmt.Printf("tmpnow111=%v\r\n", time.Now().UnixNano() / 1e6)
-
-
-	// if find over, wait for time ok
-	for {
-		select {
-			case <- abort:
-				fmt.Printf("Dnpash nonce search aborted\r\n")
-				return
-			default:
-				now := time.Now().Unix()
-				if new(big.Int).Add(minDeadLine, lastTime).Cmp(new(big.Int).SetUint64(uint64(now))) < 0 { // found
-					// Correct nonce found, create a new header with it
-					header = types.CopyHeader(header)
-					header.Nonce = types.EncodeNonce(minNonce)
-					header.PlotID = types.EncodeNonce(minPubID)
-					header.GenSig = gensig
-					header.Time = new(big.Int).SetUint64(uint64(now))
-					fmt.Printf("haha baseTarget:%v\r\n", baseTarget)
-					header.BaseTarget = new(big.Int).Set(baseTarget)
-					header.DeadLine = new(big.Int).Set(minDeadLine)
-
-					// Seal and return a block (if still needed)
-					select {
-					case found <- block.WithSeal(header):
-						fmt.Printf("Dnpash nonce found and reported,nonce:%v\r\n", minNonce)
-					case <-abort:
-						fmt.Printf("Dnpash nonce found but discarded,nonce:%v\r\n", minNonce)
-					}
-					return
-				}
-				time.Sleep(1 * time.Second)
-		}
-	}
-
-}
-
 // APIs implements consensus.Engine, returning the user facing RPC API to allow
-// controlling the signer voting.
+// inspecting the consensus clock.
 func (d *Dnpoc) APIs(chain consensus.ChainReader) []rpc.API {
-	return nil
+	return []rpc.API{{
+		Namespace: "xdnoc",
+		Version:   "1.0",
+		Service:   &API{dnpoc: d},
+		Public:    true,
+	}}
 }
 
 
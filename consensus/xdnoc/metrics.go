@@ -0,0 +1,35 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the PoC sealer.
+
+package xdnoc
+
+import (
+	"github.com/xdn/go-xdn/metrics"
+)
+
+var (
+	roundStartMeter = metrics.NewMeter("xdnoc/rounds/start")
+	roundAbortMeter = metrics.NewMeter("xdnoc/rounds/abort")
+	scanTimer       = metrics.NewTimer("xdnoc/rounds/scan")
+
+	plotReadMeter   = metrics.NewMeter("xdnoc/plots/read")
+	plotFailMeter   = metrics.NewMeter("xdnoc/plots/fail")
+	nonceMeter      = metrics.NewMeter("xdnoc/plots/nonces")
+	sealMeter       = metrics.NewMeter("xdnoc/seals")
+	noSolutionMeter = metrics.NewMeter("xdnoc/seals/nosolution")
+)
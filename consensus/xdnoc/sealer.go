@@ -0,0 +1,469 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdnoc
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/consensus"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/event"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/poc"
+)
+
+const (
+	// plotListFile is the file listing the comma separated plot directories.
+	plotListFile = "PLOT"
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// maxSealWait is the longest a sealer sleeps before rechecking its deadline,
+	// bounding the timers of solutions whose deadline lies far in the future.
+	maxSealWait = time.Minute
+)
+
+// chainHeadSubscriber is implemented by chains able to announce new heads,
+// allowing the coordinator to abort stale mining rounds early.
+type chainHeadSubscriber interface {
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// roundKey identifies the inputs a plot scan depends on. Blocks sharing it only
+// differ in their transactions, so the scan results can be reused.
+type roundKey struct {
+	parent     common.Hash
+	number     uint64
+	genSig     common.Hash
+	baseTarget string
+	plotID     uint64
+}
+
+// solution is the best nonce found by a mining round so far.
+type solution struct {
+	plotID   uint64
+	nonce    uint64
+	deadline *big.Int
+}
+
+// round is a single plot scan for a block height. The scan is paused once no
+// Seal call waits on it any more, but its results and progress are handed over
+// to the next round of the same height, so a resubmitted block with new
+// transactions never loses the deadline found for its predecessor, and the scan
+// continues with the plot files not read yet instead of starting over.
+type round struct {
+	key    roundKey
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	best    *solution           // Best solution found so far, nil if none
+	updated chan struct{}       // Closed and replaced every time best improves
+	done    bool                // Whether the scan of all plots finished
+	scanned map[string]struct{} // Plot files fully scanned already
+	lock    sync.Mutex
+
+	users int // Number of Seal calls waiting on the round (coordinator lock)
+}
+
+// newRound creates a mining round for the given key, derived from ctx.
+func newRound(ctx context.Context, key roundKey) *round {
+	r := &round{key: key, updated: make(chan struct{}), scanned: make(map[string]struct{})}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	return r
+}
+
+// status returns the best solution found so far, whether the scan finished and
+// a channel that is closed on the next change.
+func (r *round) status() (*solution, bool, <-chan struct{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.best, r.done, r.updated
+}
+
+// inherit takes over the results and progress of a paused round of the same key.
+func (r *round) inherit(prev *round) {
+	prev.lock.Lock()
+	defer prev.lock.Unlock()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.best, r.done = prev.best, prev.done
+	for path := range prev.scanned {
+		r.scanned[path] = struct{}{}
+	}
+}
+
+// isScanned returns whxdner the given plot file was already fully scanned.
+func (r *round) isScanned(path string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.scanned[path]
+	return ok
+}
+
+// markScanned records that all the deadlines of a plot file were submitted.
+func (r *round) markScanned(path string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.scanned[path] = struct{}{}
+}
+
+// submit records a deadline if it is better than the current best.
+func (r *round) submit(plotID uint64, nonce uint64, deadline *big.Int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.best != nil && r.best.deadline.Cmp(deadline) <= 0 {
+		return
+	}
+	r.best = &solution{plotID: plotID, nonce: nonce, deadline: new(big.Int).Set(deadline)}
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+// finish marks the scan complete.
+func (r *round) finish() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.done = true
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+// coordinator drives the lifecycle of mining rounds: it starts a plot scan per
+// block height, shares it across re-seals of the same height and aborts it as
+// soon as a new chain head makes it stale.
+type coordinator struct {
+	dnpoc   *Dnpoc
+	current *round
+	sub     event.Subscription
+	closed  bool
+	lock    sync.Mutex
+}
+
+// newCoordinator creates an idle mining coordinator.
+func newCoordinator(d *Dnpoc) *coordinator {
+	return &coordinator{dnpoc: d}
+}
+
+// subscribe starts following chain head events if the chain supports it and
+// the coordinator is not subscribed yet.
+func (c *coordinator) subscribe(chain consensus.ChainReader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.sub != nil || c.closed {
+		return
+	}
+	subscriber, ok := chain.(chainHeadSubscriber)
+	if !ok {
+		return
+	}
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	c.sub = subscriber.SubscribeChainHeadEvent(heads)
+
+	go c.loop(heads, c.sub)
+}
+
+// loop aborts the current round whenever the chain moves to a head that isn't
+// the parent the round is mining on.
+func (c *coordinator) loop(heads chan core.ChainHeadEvent, sub event.Subscription) {
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-heads:
+			c.lock.Lock()
+			if c.current != nil && c.current.key.parent != ev.Block.Hash() {
+				log.Debug("Aborting stale mining round", "number", c.current.key.number, "head", ev.Block.Number())
+				c.current.cancel()
+				c.current = nil
+				roundAbortMeter.Mark(1)
+			}
+			c.lock.Unlock()
+
+		case <-sub.Err():
+			c.lock.Lock()
+			if c.sub == sub {
+				c.sub = nil
+			}
+			c.lock.Unlock()
+			return
+		}
+	}
+}
+
+// close unsubscribes from chain head events and aborts the current round. The
+// coordinator refuses to subscribe again afterwards.
+func (c *coordinator) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+		c.sub = nil
+	}
+	if c.current != nil {
+		c.current.cancel()
+		c.current = nil
+	}
+}
+
+// round returns the mining round for the given key, reusing the running one if
+// only the transactions of the block changed, or starting a new scan otherwise.
+// Every returned round must be released once the caller stops waiting on it.
+func (c *coordinator) round(key roundKey, baseTarget *big.Int) *round {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.current != nil && c.current.key == key && c.current.ctx.Err() == nil {
+		c.current.users++
+		return c.current
+	}
+	// A round of the same height which was paused when its last sealer left
+	// hands its results and scan progress over to the new one
+	prev := c.current
+	if prev != nil {
+		prev.cancel()
+	}
+	c.current = newRound(context.Background(), key)
+	c.current.users++
+
+	if prev != nil && prev.key == key {
+		c.current.inherit(prev)
+		if _, done, _ := c.current.status(); done {
+			return c.current
+		}
+	}
+	roundStartMeter.Mark(1)
+	go c.dnpoc.scan(c.current, baseTarget)
+
+	return c.current
+}
+
+// release signals that a sealer stopped waiting on the round, pausing its scan
+// if nobody else is waiting on it any more.
+func (c *coordinator) release(r *round) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if r.users--; r.users == 0 {
+		r.cancel()
+	}
+}
+
+// Seal implements consensus.Engine, waiting for the best deadline found in the
+// local plots to pass and sealing the block with it.
+func (d *Dnpoc) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	// Refuse to mine with a skewed clock, our deadlines would be rejected
+	if err := d.clock.Check(); err != nil {
+		return nil, err
+	}
+	d.coordinator.subscribe(chain)
+
+	header := block.Header()
+	baseTarget := d.calcBaseTarget(chain, header.Number.Uint64())
+
+	r := d.coordinator.round(roundKey{
+		parent:     header.ParentHash,
+		number:     header.Number.Uint64(),
+		genSig:     header.GenSig,
+		baseTarget: baseTarget.String(),
+		plotID:     poc.CalcPlotID(header.Coinbase),
+	}, baseTarget)
+	defer d.coordinator.release(r)
+
+	for {
+		best, done, updated := r.status()
+
+		// Wait until either the deadline passes or the round changes
+		var timeout <-chan time.Time
+		if best != nil {
+			// Work on big ints, deadlines of poor solutions may not fit an int64
+			due := new(big.Int).Add(best.deadline, header.LastTime)
+			due.Add(due, common.Big1)
+
+			now := d.clock.Now().Unix()
+			if due.Cmp(big.NewInt(now)) <= 0 {
+				header.Nonce = types.EncodeNonce(best.nonce)
+				header.PlotID = types.EncodeNonce(best.plotID)
+				header.Time = new(big.Int).SetInt64(now)
+				header.BaseTarget = new(big.Int).Set(baseTarget)
+				header.DeadLine = new(big.Int).Set(best.deadline)
+
+				sealMeter.Mark(1)
+				log.Info("PoC deadline reached", "number", header.Number, "plotID", best.plotID, "nonce", best.nonce, "deadline", best.deadline)
+				return block.WithSeal(header), nil
+			}
+			wait := maxSealWait
+			if left := due.Sub(due, big.NewInt(now)); left.Cmp(big.NewInt(int64(maxSealWait/time.Second))) < 0 {
+				wait = time.Duration(left.Int64()) * time.Second
+			}
+			timeout = time.After(wait)
+		} else if done {
+			noSolutionMeter.Mark(1)
+			log.Debug("No PoC solution found in local plots", "number", header.Number)
+		}
+		select {
+		case <-stop:
+			// Re-sealing or mining stopped, a paused round keeps its results
+			return nil, nil
+		case <-r.ctx.Done():
+			return nil, nil
+		case <-updated:
+		case <-timeout:
+		}
+	}
+}
+
+// scan reads the round's scoop from every plot file listed in the PLOT file
+// and submits the deadlines found, until done or the round is aborted. Files
+// scanned by a paused predecessor of the round are skipped.
+func (d *Dnpoc) scan(r *round, baseTarget *big.Int) {
+	defer func() {
+		// Aborted scans are incomplete, they must not be reported as finished
+		if r.ctx.Err() == nil {
+			r.finish()
+		}
+	}()
+	defer scanTimer.UpdateSince(time.Now())
+
+	var (
+		start  = time.Now()
+		number = r.key.number
+		genSig = r.key.genSig
+	)
+	// Authorization limits the nonce range this plot ID may mine with
+	node, err := GetNodeByPlotID(r.key.plotID)
+	if err != nil {
+		log.Error("Failed to retrieve plot authorization", "plotID", r.key.plotID, "err", err)
+		return
+	}
+	scoopID := poc.GetScoopID(poc.GenHash(genSig, number))
+
+	dirBytes, err := ioutil.ReadFile(plotListFile)
+	if err != nil {
+		log.Error("Failed to read plot directory list", "err", err)
+		return
+	}
+	for _, dir := range strings.Split(string(dirBytes), ",") {
+		if dir = strings.TrimSpace(dir); dir == "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Error("Failed to list plot directory", "dir", dir, "err", err)
+			continue
+		}
+		for _, fi := range files {
+			if r.ctx.Err() != nil {
+				log.Debug("PoC plot scan aborted", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+				return
+			}
+			path := filepath.Join(dir, fi.Name())
+			if r.isScanned(path) {
+				continue
+			}
+			ss := strings.Split(fi.Name(), "_")
+			if len(ss) != 3 {
+				continue
+			}
+			plotID, err := strconv.ParseUint(ss[0], 10, 64)
+			if err != nil || plotID != r.key.plotID {
+				continue
+			}
+			nonce, err := strconv.ParseUint(ss[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			total, err := strconv.Atoi(ss[2])
+			if err != nil || total <= 0 {
+				continue
+			}
+			data, err := readScoops(r.ctx, path, scoopID, total)
+			if err != nil {
+				if r.ctx.Err() == nil {
+					plotFailMeter.Mark(1)
+					log.Warn("Failed to read plot scoops", "file", fi.Name(), "err", err)
+				}
+				continue
+			}
+			plotReadMeter.Mark(1)
+			nonceMeter.Mark(int64(total))
+			for index := 0; index < total; index++ {
+				if current := nonce + uint64(index); current < node.MinNonce || current > node.MaxNonce {
+					continue
+				}
+				target := poc.CalcTarget(data[64*index:64*index+32], data[64*index+32:64*index+64], genSig)
+				ntarget := new(big.Int).SetBytes((target.Bytes())[24:])
+
+				r.submit(plotID, nonce+uint64(index), poc.CalcDeadLine(ntarget, baseTarget))
+			}
+			r.markScanned(path)
+		}
+	}
+	log.Debug("PoC plot scan finished", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// scoopReadChunk is the amount of scoop data read from a plot file at once,
+// between two checks for round cancellation.
+const scoopReadChunk = 4 * 1024 * 1024
+
+// readScoops reads the given scoop of all nonces in a plot file, aborting
+// between chunks if the context is cancelled.
+func readScoops(ctx context.Context, path string, scoopID int, total int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, 64*total)
+	offset := int64(scoopID) * 64 * int64(total)
+	for read := 0; read < len(data); {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := read + scoopReadChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		n, err := f.ReadAt(data[read:end], offset+int64(read))
+		if err != nil {
+			return nil, err
+		}
+		read += n
+	}
+	return data, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/accounts/abi/bind"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/contracts/multisig/contract"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/internal/xdnapi"
+)
+
+// Wallet describes the on-chain state of a multisig wallet.
+type Wallet struct {
+	Address   common.Address   `json:"address"`
+	Owners    []common.Address `json:"owners"`
+	Threshold *hexutil.Big     `json:"threshold"`
+	Nonce     *hexutil.Big     `json:"nonce"`
+}
+
+// Deployment is the result of deploying a new multisig wallet.
+type Deployment struct {
+	Address     common.Address `json:"address"`
+	Transaction common.Hash    `json:"transactionHash"`
+}
+
+// PrivateMultisigAPI exposes the bundled multisig wallet contract over RPC. It
+// is private as proposals are signed and executed using keystore passphrases.
+type PrivateMultisigAPI struct {
+	b        xdnapi.Backend
+	contract bind.ContractBackend
+}
+
+// NewPrivateMultisigAPI creates a new multisig API operating on the chain of b
+// through the given contract backend.
+func NewPrivateMultisigAPI(b xdnapi.Backend, contract bind.ContractBackend) *PrivateMultisigAPI {
+	return &PrivateMultisigAPI{b: b, contract: contract}
+}
+
+// Deploy creates a new multisig wallet requiring threshold signatures of the
+// given owners, sending the deployment from a local account.
+func (api *PrivateMultisigAPI) Deploy(ctx context.Context, from common.Address, passwd string, owners []common.Address, threshold hexutil.Uint64) (*Deployment, error) {
+	opts, err := api.transactOpts(ctx, from, passwd)
+	if err != nil {
+		return nil, err
+	}
+	address, tx, _, err := contract.DeployMultisig(opts, api.contract, new(big.Int).SetUint64(uint64(threshold)), owners)
+	if err != nil {
+		return nil, err
+	}
+	return &Deployment{Address: address, Transaction: tx.Hash()}, nil
+}
+
+// Info retrieves the owners, threshold and nonce of a multisig wallet.
+func (api *PrivateMultisigAPI) Info(ctx context.Context, wallet common.Address) (*Wallet, error) {
+	ms, err := contract.NewMultisigCaller(wallet, api.contract)
+	if err != nil {
+		return nil, err
+	}
+	opts := &bind.CallOpts{Pending: true, Context: ctx}
+
+	owners, err := ms.GetOwners(opts)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := ms.Threshold(opts)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := ms.Nonce(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{
+		Address:   wallet,
+		Owners:    owners,
+		Threshold: (*hexutil.Big)(threshold),
+		Nonce:     (*hexutil.Big)(nonce),
+	}, nil
+}
+
+// Propose creates an unsigned proposal to send value and data from wallet to the
+// given destination. Unless explicitly given, the proposal is made for the next
+// nonce of the wallet.
+func (api *PrivateMultisigAPI) Propose(ctx context.Context, wallet common.Address, to common.Address, value *hexutil.Big, data hexutil.Bytes, nonce *hexutil.Big) (*Proposal, error) {
+	if value == nil {
+		value = new(hexutil.Big)
+	}
+	if nonce == nil {
+		ms, err := contract.NewMultisigCaller(wallet, api.contract)
+		if err != nil {
+			return nil, err
+		}
+		next, err := ms.Nonce(&bind.CallOpts{Pending: true, Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		nonce = (*hexutil.Big)(next)
+	}
+	return NewProposal(wallet, to, value.ToInt(), data, nonce.ToInt()), nil
+}
+
+// Sign approves a proposal with the given owner account, returning the proposal
+// with the signature added. Signing does not touch the chain, so proposals can
+// be passed around to owners on offline machines.
+func (api *PrivateMultisigAPI) Sign(proposal Proposal, owner common.Address, passwd string) (*Proposal, error) {
+	if err := proposal.Verify(); err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: owner}
+
+	wallet, err := api.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := wallet.SignHashWithPassphrase(account, passwd, proposal.Hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27 // Transform V from 0/1 to 27/28 as expected by ecrecover
+
+	if _, err := proposal.AddSignature(sig); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// Execute submits a sufficiently signed proposal to its wallet, sending the
+// transaction from a local account, and returns the transaction hash.
+func (api *PrivateMultisigAPI) Execute(ctx context.Context, proposal Proposal, from common.Address, passwd string) (common.Hash, error) {
+	if err := proposal.Verify(); err != nil {
+		return common.Hash{}, err
+	}
+	info, err := api.Info(ctx, proposal.Wallet)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if info.Nonce.ToInt().Cmp(proposal.Nonce.ToInt()) != 0 {
+		return common.Hash{}, fmt.Errorf("proposal nonce %v does not match wallet nonce %v", proposal.Nonce.ToInt(), info.Nonce.ToInt())
+	}
+	threshold := info.Threshold.ToInt()
+	if threshold.Cmp(big.NewInt(int64(len(info.Owners)))) > 0 {
+		return common.Hash{}, errors.New("wallet threshold exceeds its owners")
+	}
+	signatures, err := proposal.pack(info.Owners, int(threshold.Int64()))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	ms, err := contract.NewMultisigTransactor(proposal.Wallet, api.contract)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	opts, err := api.transactOpts(ctx, from, passwd)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx, err := ms.Execute(opts, proposal.To, proposal.Value.ToInt(), proposal.Data, signatures)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// transactOpts creates the options to send a contract transaction from a local
+// account, signed with the given passphrase.
+func (api *PrivateMultisigAPI) transactOpts(ctx context.Context, from common.Address, passwd string) (*bind.TransactOpts, error) {
+	account := accounts.Account{Address: from}
+
+	wallet, err := api.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(signer types.Signer, addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if addr != from {
+				return nil, errors.New("not authorized to sign this account")
+			}
+			var chainID *big.Int
+			if config := api.b.ChainConfig(); config.IsEIP155(api.b.CurrentBlock().Number()) {
+				chainID = config.ChainId
+			}
+			return wallet.SignTxWithPassphrase(account, passwd, tx, chainID)
+		},
+		Context: ctx,
+	}, nil
+}
@@ -0,0 +1,339 @@
+// This file follows the layout of the abigen bindings, but MultisigBin was not
+// compiled by solc: it was assembled by hand from multisig.sol. Run go generate
+// in the parent package with solc 0.4.18, the version pinned by the contract, to
+// replace it with the compiler output.
+
+package contract
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/xdn/go-xdn/accounts/abi"
+	"github.com/xdn/go-xdn/accounts/abi/bind"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/core/types"
+)
+
+// MultisigABI is the input ABI used to generate the binding from.
+const MultisigABI = "[{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"owners\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"isOwner\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"destination\",\"type\":\"address\"},{\"name\":\"value\",\"type\":\"uint256\"},{\"name\":\"data\",\"type\":\"bytes\"},{\"name\":\"signatures\",\"type\":\"bytes\"}],\"name\":\"execute\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"getOwners\",\"outputs\":[{\"name\":\"\",\"type\":\"address[]\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"threshold\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"destination\",\"type\":\"address\"},{\"name\":\"value\",\"type\":\"uint256\"},{\"name\":\"data\",\"type\":\"bytes\"},{\"name\":\"_nonce\",\"type\":\"uint256\"}],\"name\":\"transactionHash\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"nonce\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"_threshold\",\"type\":\"uint256\"},{\"name\":\"_owners\",\"type\":\"address[]\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"fallback\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"nonce\",\"type\":\"uint256\"},{\"indexed\":true,\"name\":\"destination\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Executed\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"sender\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Deposit\",\"type\":\"event\"}]"
+
+// MultisigBin is the compiled bytecode used for deploying new contracts.
+const MultisigBin = `0x346100b05761041838038061041860803960a051608001805180602002820160200183608001106100b05760805180156100b05781106100b057608051600155806003556003600052602060002060005b828110156100a2578060200284016020015173ffffffffffffffffffffffffffffffffffffffff1680156100b057806000526002602052604060002080546100b0576001905581830155600101610050565b610363806100b56000396000f35b600080fd60043610610078576000357c010000000000000000000000000000000000000000000000000000000090048063affed0e0146100ba57806342cde4e8146100c75780632f54bf6e146100d4578063025e7c2714610105578063a0e67e2b1461013e57806329a7e3ab14610199578063da0980c7146101fc575b34156100aa5734600052337fe1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c60206000a25b005b600080fd5b60005260206000f35b346100ac576000546100b1565b346100ac576001546100b1565b346100ac5760043573ffffffffffffffffffffffffffffffffffffffff1660005260026020526040600020546100b1565b346100ac576004356003548110156100ac5760036000526020600020015473ffffffffffffffffffffffffffffffffffffffff166100b1565b346100ac576003600052602060002060206000526003548060205260005b8181101561018e578083015473ffffffffffffffffffffffffffffffffffffffff16816020026040015260010161015c565b506020026040016000f35b346100ac576101cb60643560443560040160243560043573ffffffffffffffffffffffffffffffffffffffff166101d0565b6100b1565b608a5260aa523060765260196080538035808260200160ca379050818160ca0152606a01608020905090565b346100ac57606435600401803560015460410214156100ac5760200161024560005460443560040160243560043573ffffffffffffffffffffffffffffffffffffffff166101d0565b600060005b6001548110156102ce5782600052806041028401803560405280602001356060526040013560001a6020526000608052602060806080600060006001610bb8f1156100ac5760805173ffffffffffffffffffffffffffffffffffffffff16808310156100ac57806000526002602052604060002054156100ac57915060010161024a565b5050505060005480600101600055604435600401803580916020016000376000600082600060243560043573ffffffffffffffffffffffffffffffffffffffff166188b85a03f1156100ac575060243560005260043573ffffffffffffffffffffffffffffffffffffffff16907f12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa60206000a300`
+
+// DeployMultisig deploys a new Dnp contract, binding an instance of Multisig to it.
+func DeployMultisig(auth *bind.TransactOpts, backend bind.ContractBackend, _threshold *big.Int, _owners []common.Address) (common.Address, *types.Transaction, *Multisig, error) {
+	parsed, err := abi.JSON(strings.NewReader(MultisigABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(MultisigBin), backend, _threshold, _owners)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Multisig{MultisigCaller: MultisigCaller{contract: contract}, MultisigTransactor: MultisigTransactor{contract: contract}}, nil
+}
+
+// Multisig is an auto generated Go binding around an Dnp contract.
+type Multisig struct {
+	MultisigCaller     // Read-only binding to the contract
+	MultisigTransactor // Write-only binding to the contract
+}
+
+// MultisigCaller is an auto generated read-only Go binding around an Dnp contract.
+type MultisigCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// MultisigTransactor is an auto generated write-only Go binding around an Dnp contract.
+type MultisigTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// MultisigSession is an auto generated Go binding around an Dnp contract,
+// with pre-set call and transact options.
+type MultisigSession struct {
+	Contract     *Multisig         // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// MultisigCallerSession is an auto generated read-only Go binding around an Dnp contract,
+// with pre-set call options.
+type MultisigCallerSession struct {
+	Contract *MultisigCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts   // Call options to use throughout this session
+}
+
+// MultisigTransactorSession is an auto generated write-only Go binding around an Dnp contract,
+// with pre-set transact options.
+type MultisigTransactorSession struct {
+	Contract     *MultisigTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts   // Transaction auth options to use throughout this session
+}
+
+// MultisigRaw is an auto generated low-level Go binding around an Dnp contract.
+type MultisigRaw struct {
+	Contract *Multisig // Generic contract binding to access the raw methods on
+}
+
+// MultisigCallerRaw is an auto generated low-level read-only Go binding around an Dnp contract.
+type MultisigCallerRaw struct {
+	Contract *MultisigCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// MultisigTransactorRaw is an auto generated low-level write-only Go binding around an Dnp contract.
+type MultisigTransactorRaw struct {
+	Contract *MultisigTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewMultisig creates a new instance of Multisig, bound to a specific deployed contract.
+func NewMultisig(address common.Address, backend bind.ContractBackend) (*Multisig, error) {
+	contract, err := bindMultisig(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Multisig{MultisigCaller: MultisigCaller{contract: contract}, MultisigTransactor: MultisigTransactor{contract: contract}}, nil
+}
+
+// NewMultisigCaller creates a new read-only instance of Multisig, bound to a specific deployed contract.
+func NewMultisigCaller(address common.Address, caller bind.ContractCaller) (*MultisigCaller, error) {
+	contract, err := bindMultisig(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigCaller{contract: contract}, nil
+}
+
+// NewMultisigTransactor creates a new write-only instance of Multisig, bound to a specific deployed contract.
+func NewMultisigTransactor(address common.Address, transactor bind.ContractTransactor) (*MultisigTransactor, error) {
+	contract, err := bindMultisig(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigTransactor{contract: contract}, nil
+}
+
+// bindMultisig binds a generic wrapper to an already deployed contract.
+func bindMultisig(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(MultisigABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Multisig *MultisigRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _Multisig.Contract.MultisigCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Multisig *MultisigRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Multisig.Contract.MultisigTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Multisig *MultisigRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Multisig.Contract.MultisigTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Multisig *MultisigCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _Multisig.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Multisig *MultisigTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Multisig.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Multisig *MultisigTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Multisig.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_Multisig *MultisigCaller) GetOwners(opts *bind.CallOpts) ([]common.Address, error) {
+	var (
+		ret0 = new([]common.Address)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "getOwners")
+	return *ret0, err
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_Multisig *MultisigSession) GetOwners() ([]common.Address, error) {
+	return _Multisig.Contract.GetOwners(&_Multisig.CallOpts)
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_Multisig *MultisigCallerSession) GetOwners() ([]common.Address, error) {
+	return _Multisig.Contract.GetOwners(&_Multisig.CallOpts)
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner( address) constant returns(bool)
+func (_Multisig *MultisigCaller) IsOwner(opts *bind.CallOpts, arg0 common.Address) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "isOwner", arg0)
+	return *ret0, err
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner( address) constant returns(bool)
+func (_Multisig *MultisigSession) IsOwner(arg0 common.Address) (bool, error) {
+	return _Multisig.Contract.IsOwner(&_Multisig.CallOpts, arg0)
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner( address) constant returns(bool)
+func (_Multisig *MultisigCallerSession) IsOwner(arg0 common.Address) (bool, error) {
+	return _Multisig.Contract.IsOwner(&_Multisig.CallOpts, arg0)
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_Multisig *MultisigCaller) Nonce(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "nonce")
+	return *ret0, err
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_Multisig *MultisigSession) Nonce() (*big.Int, error) {
+	return _Multisig.Contract.Nonce(&_Multisig.CallOpts)
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_Multisig *MultisigCallerSession) Nonce() (*big.Int, error) {
+	return _Multisig.Contract.Nonce(&_Multisig.CallOpts)
+}
+
+// Owners is a free data retrieval call binding the contract method 0x025e7c27.
+//
+// Solidity: function owners( uint256) constant returns(address)
+func (_Multisig *MultisigCaller) Owners(opts *bind.CallOpts, arg0 *big.Int) (common.Address, error) {
+	var (
+		ret0 = new(common.Address)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "owners", arg0)
+	return *ret0, err
+}
+
+// Owners is a free data retrieval call binding the contract method 0x025e7c27.
+//
+// Solidity: function owners( uint256) constant returns(address)
+func (_Multisig *MultisigSession) Owners(arg0 *big.Int) (common.Address, error) {
+	return _Multisig.Contract.Owners(&_Multisig.CallOpts, arg0)
+}
+
+// Owners is a free data retrieval call binding the contract method 0x025e7c27.
+//
+// Solidity: function owners( uint256) constant returns(address)
+func (_Multisig *MultisigCallerSession) Owners(arg0 *big.Int) (common.Address, error) {
+	return _Multisig.Contract.Owners(&_Multisig.CallOpts, arg0)
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_Multisig *MultisigCaller) Threshold(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "threshold")
+	return *ret0, err
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_Multisig *MultisigSession) Threshold() (*big.Int, error) {
+	return _Multisig.Contract.Threshold(&_Multisig.CallOpts)
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_Multisig *MultisigCallerSession) Threshold() (*big.Int, error) {
+	return _Multisig.Contract.Threshold(&_Multisig.CallOpts)
+}
+
+// TransactionHash is a free data retrieval call binding the contract method 0x29a7e3ab.
+//
+// Solidity: function transactionHash(destination address, value uint256, data bytes, _nonce uint256) constant returns(bytes32)
+func (_Multisig *MultisigCaller) TransactionHash(opts *bind.CallOpts, destination common.Address, value *big.Int, data []byte, _nonce *big.Int) ([32]byte, error) {
+	var (
+		ret0 = new([32]byte)
+	)
+	out := ret0
+	err := _Multisig.contract.Call(opts, out, "transactionHash", destination, value, data, _nonce)
+	return *ret0, err
+}
+
+// TransactionHash is a free data retrieval call binding the contract method 0x29a7e3ab.
+//
+// Solidity: function transactionHash(destination address, value uint256, data bytes, _nonce uint256) constant returns(bytes32)
+func (_Multisig *MultisigSession) TransactionHash(destination common.Address, value *big.Int, data []byte, _nonce *big.Int) ([32]byte, error) {
+	return _Multisig.Contract.TransactionHash(&_Multisig.CallOpts, destination, value, data, _nonce)
+}
+
+// TransactionHash is a free data retrieval call binding the contract method 0x29a7e3ab.
+//
+// Solidity: function transactionHash(destination address, value uint256, data bytes, _nonce uint256) constant returns(bytes32)
+func (_Multisig *MultisigCallerSession) TransactionHash(destination common.Address, value *big.Int, data []byte, _nonce *big.Int) ([32]byte, error) {
+	return _Multisig.Contract.TransactionHash(&_Multisig.CallOpts, destination, value, data, _nonce)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(destination address, value uint256, data bytes, signatures bytes) returns()
+func (_Multisig *MultisigTransactor) Execute(opts *bind.TransactOpts, destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _Multisig.contract.Transact(opts, "execute", destination, value, data, signatures)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(destination address, value uint256, data bytes, signatures bytes) returns()
+func (_Multisig *MultisigSession) Execute(destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _Multisig.Contract.Execute(&_Multisig.TransactOpts, destination, value, data, signatures)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(destination address, value uint256, data bytes, signatures bytes) returns()
+func (_Multisig *MultisigTransactorSession) Execute(destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _Multisig.Contract.Execute(&_Multisig.TransactOpts, destination, value, data, signatures)
+}
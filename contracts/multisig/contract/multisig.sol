@@ -0,0 +1,85 @@
+pragma solidity 0.4.18;
+
+/// @title Multisig wallet executing transactions approved offline by M of N owners
+contract multisig {
+    // Number of transactions executed so far, part of every approved hash
+    uint256 public nonce;
+    // Number of owner signatures required to execute a transaction
+    uint256 public threshold;
+    // Owner lookup used during signature verification
+    mapping (address => bool) public isOwner;
+    // Owners of the wallet in the order given at deployment
+    address[] public owners;
+
+    /// @notice Executed event
+    event Executed(uint256 indexed nonce, address indexed destination, uint256 value);
+
+    /// @notice Deposit event
+    event Deposit(address indexed sender, uint256 value);
+
+    /// @param _threshold number of owner signatures required per transaction
+    /// @param _owners owner addresses, without duplicates
+    function multisig(uint256 _threshold, address[] _owners) public {
+        require(_threshold > 0 && _threshold <= _owners.length);
+        for (uint256 i = 0; i < _owners.length; i++) {
+            require(_owners[i] != address(0) && !isOwner[_owners[i]]);
+            isOwner[_owners[i]] = true;
+        }
+        owners = _owners;
+        threshold = _threshold;
+    }
+
+    /// @notice Accept deposits
+    function () public payable {
+        if (msg.value > 0) {
+            Deposit(msg.sender, msg.value);
+        }
+    }
+
+    /// @notice List the owners
+    function getOwners() public view returns (address[]) {
+        return owners;
+    }
+
+    /// @notice Hash the owners sign to approve a transaction
+    ///
+    /// @param destination recipient of the transaction
+    /// @param value amount in wei to send along
+    /// @param data call data of the transaction
+    /// @param _nonce wallet nonce the transaction is executed at
+    function transactionHash(address destination, uint256 value, bytes data, uint256 _nonce) public view returns (bytes32) {
+        return keccak256(byte(0x19), byte(0), this, destination, value, data, _nonce);
+    }
+
+    /// @notice Execute a transaction approved by threshold owners
+    ///
+    /// @param destination recipient of the transaction
+    /// @param value amount in wei to send along
+    /// @param data call data of the transaction
+    /// @param signatures threshold concatenated [R || S || V] signatures of the
+    /// transaction hash, sorted by strictly increasing signer address
+    function execute(address destination, uint256 value, bytes data, bytes signatures) public {
+        require(signatures.length == threshold * 65);
+        bytes32 hash = transactionHash(destination, value, data, nonce);
+
+        address last = address(0);
+        for (uint256 i = 0; i < threshold; i++) {
+            bytes32 r;
+            bytes32 s;
+            uint8 v;
+            assembly {
+                r := mload(add(signatures, add(32, mul(i, 65))))
+                s := mload(add(signatures, add(64, mul(i, 65))))
+                v := byte(0, mload(add(signatures, add(96, mul(i, 65)))))
+            }
+            address signer = ecrecover(hash, v, r, s);
+            require(signer > last && isOwner[signer]);
+            last = signer;
+        }
+        // Bump the nonce before calling out, the hash can never be replayed
+        uint256 executed = nonce;
+        nonce = executed + 1;
+        require(destination.call.value(value)(data));
+        Executed(executed, destination, value);
+    }
+}
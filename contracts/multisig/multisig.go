@@ -0,0 +1,155 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package multisig wraps the bundled M-of-N multisig wallet contract. Wallet
+// transactions are approved by owner signatures collected offline and passed
+// around as proposals until enough of them are gathered to execute.
+package multisig
+
+// The contract pins solc 0.4.18, the binding must be generated with exactly that
+// compiler version.
+//go:generate abigen --sol contract/multisig.sol --pkg contract --out contract/multisig.go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/common/math"
+	"github.com/xdn/go-xdn/crypto"
+)
+
+var (
+	errHashMismatch  = errors.New("proposal hash does not match its contents")
+	errNotEnoughSigs = errors.New("not enough owner signatures")
+)
+
+// Proposal is a wallet transaction together with the owner signatures collected
+// for it so far. It is exchanged between the owners as plain JSON.
+type Proposal struct {
+	Wallet     common.Address  `json:"wallet"`
+	To         common.Address  `json:"to"`
+	Value      *hexutil.Big    `json:"value"`
+	Data       hexutil.Bytes   `json:"data"`
+	Nonce      *hexutil.Big    `json:"nonce"`
+	Hash       common.Hash     `json:"hash"`
+	Signatures []hexutil.Bytes `json:"signatures"`
+}
+
+// NewProposal creates an unsigned proposal to send value and data from wallet
+// to the given destination at the given wallet nonce.
+func NewProposal(wallet, to common.Address, value *big.Int, data []byte, nonce *big.Int) *Proposal {
+	return &Proposal{
+		Wallet:     wallet,
+		To:         to,
+		Value:      (*hexutil.Big)(value),
+		Data:       data,
+		Nonce:      (*hexutil.Big)(nonce),
+		Hash:       TransactionHash(wallet, to, value, data, nonce),
+		Signatures: []hexutil.Bytes{},
+	}
+}
+
+// TransactionHash returns the hash the owners of wallet sign to approve sending
+// value and data to the given destination at the given wallet nonce:
+//
+//   keccak256(0x19 || 0x00 || wallet || to || value || data || nonce)
+func TransactionHash(wallet, to common.Address, value *big.Int, data []byte, nonce *big.Int) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, 0x00}, wallet[:], to[:], math.PaddedBigBytes(value, 32), data, math.PaddedBigBytes(nonce, 32))
+}
+
+// Verify checks that the proposal is complete and its hash matches its contents.
+func (p *Proposal) Verify() error {
+	if p.Value == nil || p.Nonce == nil {
+		return errors.New("proposal value and nonce are required")
+	}
+	if TransactionHash(p.Wallet, p.To, p.Value.ToInt(), p.Data, p.Nonce.ToInt()) != p.Hash {
+		return errHashMismatch
+	}
+	return nil
+}
+
+// Signer returns the owner address that produced the given signature of the
+// proposal hash. The V value of the signature must be 27 or 28.
+func (p *Proposal) Signer(sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, errors.New("signature must be 65 bytes long")
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		return common.Address{}, fmt.Errorf("invalid signature recovery id %d", sig[64])
+	}
+	pubKey, err := crypto.SigToPub(p.Hash[:], append(common.CopyBytes(sig[:64]), sig[64]-27))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// AddSignature attaches sig to the proposal, replacing any earlier signature of
+// the same signer, and returns the signer's address.
+func (p *Proposal) AddSignature(sig []byte) (common.Address, error) {
+	signer, err := p.Signer(sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sigs := []hexutil.Bytes{sig}
+	for _, old := range p.Signatures {
+		if addr, err := p.Signer(old); err == nil && addr != signer {
+			sigs = append(sigs, old)
+		}
+	}
+	p.Signatures = sigs
+	return signer, nil
+}
+
+// pack orders the signatures of the given owners by increasing signer address
+// as required by the contract and concatenates the first threshold of them.
+func (p *Proposal) pack(owners []common.Address, threshold int) ([]byte, error) {
+	isOwner := make(map[common.Address]bool)
+	for _, owner := range owners {
+		isOwner[owner] = true
+	}
+	type signed struct {
+		signer common.Address
+		sig    []byte
+	}
+	var sigs []signed
+	for _, sig := range p.Signatures {
+		signer, err := p.Signer(sig)
+		if err != nil {
+			return nil, err
+		}
+		if isOwner[signer] {
+			sigs = append(sigs, signed{signer, sig})
+			delete(isOwner, signer)
+		}
+	}
+	if len(sigs) < threshold {
+		return nil, fmt.Errorf("%v: have %d, want %d", errNotEnoughSigs, len(sigs), threshold)
+	}
+	sort.Slice(sigs, func(i, j int) bool {
+		return bytes.Compare(sigs[i].signer[:], sigs[j].signer[:]) < 0
+	})
+	packed := make([]byte, 0, 65*threshold)
+	for _, s := range sigs[:threshold] {
+		packed = append(packed, s.sig...)
+	}
+	return packed, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/accounts/abi/bind/backends"
+	"github.com/xdn/go-xdn/accounts/keystore"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/contracts/multisig/contract"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/crypto"
+	"github.com/xdn/go-xdn/internal/xdnapi"
+	"github.com/xdn/go-xdn/params"
+)
+
+// testBackend is the subset of the API backend the multisig API uses, backed by
+// a keystore. Calling any other backend method panics.
+type testBackend struct {
+	xdnapi.Backend
+	am *accounts.Manager
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager { return b.am }
+
+// The simulated backend only accepts homestead signed transactions
+func (b *testBackend) ChainConfig() *params.ChainConfig { return new(params.ChainConfig) }
+
+func (b *testBackend) CurrentBlock() *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int)})
+}
+
+// Tests that a 2-of-3 wallet executes proposals signed by its owners, agrees on
+// the approved hash with the Go side, and rejects replayed, unsorted or
+// duplicate signatures.
+func TestMultisig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multisig-keystore-")
+	if err != nil {
+		t.Fatalf("failed to create keystore dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a funded deployer and three owners in a keystore
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+
+	alloc := make(core.GenesisAlloc)
+	addrs := make([]common.Address, 4)
+	for i := range addrs {
+		key, _ := crypto.GenerateKey()
+		if _, err := ks.ImportECDSA(key, ""); err != nil {
+			t.Fatalf("failed to import key %d: %v", i, err)
+		}
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+		alloc[addrs[i]] = core.GenesisAccount{Balance: big.NewInt(params.Dnper)}
+	}
+	deployer, owners := addrs[0], addrs[1:]
+
+	sim := backends.NewSimulatedBackend(alloc)
+	api := NewPrivateMultisigAPI(&testBackend{am: accounts.NewManager(ks)}, sim)
+	ctx := context.Background()
+
+	// Deploy the wallet and fund it through its fallback function
+	deployment, err := api.Deploy(ctx, deployer, "", owners, 2)
+	if err != nil {
+		t.Fatalf("failed to deploy wallet: %v", err)
+	}
+	sim.Commit()
+
+	wallet := deployment.Address
+	opts, err := api.transactOpts(ctx, deployer, "")
+	if err != nil {
+		t.Fatalf("failed to create deployer transactor: %v", err)
+	}
+	ms, err := contract.NewMultisig(wallet, sim)
+	if err != nil {
+		t.Fatalf("failed to bind wallet: %v", err)
+	}
+	fund := *opts
+	fund.Value = big.NewInt(params.Dnper / 2)
+	if _, err := (&contract.MultisigRaw{Contract: ms}).Transfer(&fund); err != nil {
+		t.Fatalf("failed to fund wallet: %v", err)
+	}
+	sim.Commit()
+
+	// Propose a transfer and check the hash against the contract
+	dest := common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	value := big.NewInt(params.Dnper / 10)
+
+	proposal, err := api.Propose(ctx, wallet, dest, (*hexutil.Big)(value), hexutil.Bytes{0xca, 0xfe}, nil)
+	if err != nil {
+		t.Fatalf("failed to propose transfer: %v", err)
+	}
+	if proposal.Nonce.ToInt().Sign() != 0 {
+		t.Fatalf("proposal nonce mismatch: have %v, want 0", proposal.Nonce.ToInt())
+	}
+	hash, err := ms.TransactionHash(nil, dest, value, []byte{0xca, 0xfe}, common.Big0)
+	if err != nil {
+		t.Fatalf("failed to retrieve contract hash: %v", err)
+	}
+	if proposal.Hash != common.Hash(hash) {
+		t.Fatalf("transaction hash mismatch: have %x, want %x", proposal.Hash, hash)
+	}
+	// A single signature is not enough, two of them execute the transfer
+	if proposal, err = api.Sign(*proposal, owners[2], ""); err != nil {
+		t.Fatalf("failed to sign proposal: %v", err)
+	}
+	if _, err := api.Execute(ctx, *proposal, deployer, ""); err == nil {
+		t.Fatalf("executed proposal with a single signature")
+	}
+	if proposal, err = api.Sign(*proposal, owners[0], ""); err != nil {
+		t.Fatalf("failed to sign proposal: %v", err)
+	}
+	if _, err := api.Execute(ctx, *proposal, deployer, ""); err != nil {
+		t.Fatalf("failed to execute proposal: %v", err)
+	}
+	sim.Commit()
+
+	if balance, _ := sim.BalanceAt(ctx, dest, nil); balance.Cmp(value) != 0 {
+		t.Fatalf("destination balance mismatch: have %v, want %v", balance, value)
+	}
+	if nonce, _ := ms.Nonce(nil); nonce.Int64() != 1 {
+		t.Fatalf("wallet nonce mismatch: have %v, want 1", nonce)
+	}
+	// Replaying the executed proposal at its old nonce must fail on chain too
+	signatures, err := proposal.pack(owners, 2)
+	if err != nil {
+		t.Fatalf("failed to pack signatures: %v", err)
+	}
+	if _, err := api.Execute(ctx, *proposal, deployer, ""); err == nil {
+		t.Fatalf("replayed proposal at an old nonce")
+	}
+	if _, err := ms.Execute(opts, dest, value, []byte{0xca, 0xfe}, signatures); err == nil {
+		t.Fatalf("contract accepted replayed signatures")
+	}
+	// Unsorted or duplicate signatures of a valid proposal must be rejected
+	if proposal, err = api.Propose(ctx, wallet, dest, (*hexutil.Big)(value), nil, nil); err != nil {
+		t.Fatalf("failed to propose transfer: %v", err)
+	}
+	for _, owner := range owners[:2] {
+		if proposal, err = api.Sign(*proposal, owner, ""); err != nil {
+			t.Fatalf("failed to sign proposal: %v", err)
+		}
+	}
+	if signatures, err = proposal.pack(owners, 2); err != nil {
+		t.Fatalf("failed to pack signatures: %v", err)
+	}
+	unsorted := append(append([]byte{}, signatures[65:]...), signatures[:65]...)
+	if _, err := ms.Execute(opts, dest, value, nil, unsorted); err == nil {
+		t.Fatalf("contract accepted unsorted signatures")
+	}
+	duplicate := append(append([]byte{}, signatures[:65]...), signatures[:65]...)
+	if _, err := ms.Execute(opts, dest, value, nil, duplicate); err == nil {
+		t.Fatalf("contract accepted duplicate signatures")
+	}
+	if _, err := ms.Execute(opts, dest, value, nil, signatures); err != nil {
+		t.Fatalf("failed to execute sorted signatures: %v", err)
+	}
+	sim.Commit()
+
+	if balance, _ := sim.BalanceAt(ctx, dest, nil); balance.Cmp(new(big.Int).Mul(value, big.NewInt(2))) != 0 {
+		t.Fatalf("destination balance mismatch: have %v, want %v", balance, 2*value.Int64())
+	}
+}
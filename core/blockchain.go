@@ -33,6 +33,7 @@ import (
 	"github.com/xdn/go-xdn/common/mclock"
 	"github.com/xdn/go-xdn/consensus"
 	"github.com/xdn/go-xdn/core/state"
+	"github.com/xdn/go-xdn/core/state/snapshot"
 	"github.com/xdn/go-xdn/core/types"
 	"github.com/xdn/go-xdn/core/vm"
 	"github.com/xdn/go-xdn/crypto"
@@ -44,6 +45,7 @@ import (
 	"github.com/xdn/go-xdn/rlp"
 	"github.com/xdn/go-xdn/trie"
 	"github.com/hashicorp/golang-lru"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
@@ -58,11 +60,25 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
 
+// CacheConfig contains the configuration values for the trie caching/pruning
+// that's resident in a blockchain.
+type CacheConfig struct {
+	Disabled      bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	SnapshotDisabled bool // Whether to skip maintaining the flat state snapshot
+
+	BodyRetention     uint64 // Number of recent blocks to keep the bodies and receipts of (0 = all)
+	TxLookupRetention uint64 // Number of recent blocks to keep the transaction lookups of (0 = all)
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -78,7 +94,8 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	config *params.ChainConfig // chain & network configuration
+	config      *params.ChainConfig // chain & network configuration
+	cacheConfig *CacheConfig        // Cache configuration for pruning
 
 	hc            *HeaderChain
 	chainDb       xdndb.Database
@@ -99,6 +116,12 @@ type BlockChain struct {
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat snapshot of the recent states for fast reads
+	triegc       *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	gcproc       time.Duration  // Accumulates canonical block processing for trie dumping
+	lastWrite    uint64         // Number of the last block whose state was flushed to disk
+	bodyTail     uint64         // Oldest block with retained body and receipts (atomic access)
+	lookupTail   uint64         // Oldest block with retained transaction lookups (atomic access)
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -121,7 +144,13 @@ type BlockChain struct {
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Dnp Validator and
 // Processor.
-func NewBlockChain(chainDb xdndb.Database, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+func NewBlockChain(chainDb xdndb.Database, cacheConfig *CacheConfig, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = &CacheConfig{
+			TrieNodeLimit: 256,
+			TrieTimeLimit: 5 * time.Minute,
+		}
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -130,8 +159,10 @@ func NewBlockChain(chainDb xdndb.Database, config *params.ChainConfig, engine co
 
 	bc := &BlockChain{
 		config:       config,
+		cacheConfig:  cacheConfig,
 		chainDb:      chainDb,
 		stateCache:   state.NewDatabase(chainDb),
+		triegc:       prque.New(),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -140,6 +171,8 @@ func NewBlockChain(chainDb xdndb.Database, config *params.ChainConfig, engine co
 		engine:       engine,
 		vmConfig:     vmConfig,
 		badBlocks:    badBlocks,
+		bodyTail:     GetBodyTail(chainDb),
+		lookupTail:   GetTxLookupTail(chainDb),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc, engine))
@@ -156,6 +189,12 @@ func NewBlockChain(chainDb xdndb.Database, config *params.ChainConfig, engine co
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	// Load any existing snapshot, regenerating it if loading failed
+	if !cacheConfig.SnapshotDisabled {
+		if bc.snaps, err = snapshot.New(KeyValueStore(chainDb), bc.stateCache.TrieDB(), bc.CurrentBlock().Root()); err != nil {
+			log.Warn("State snapshot unavailable", "err", err)
+		}
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -169,6 +208,25 @@ func NewBlockChain(chainDb xdndb.Database, config *params.ChainConfig, engine co
 			}
 		}
 	}
+	// Start pruning the history outside of the retention windows, if requested
+	if cacheConfig.BodyRetention > 0 || cacheConfig.TxLookupRetention > 0 {
+		if retention := cacheConfig.BodyRetention; retention > 0 && retention < minHistoryRetention {
+			log.Warn("Body retention too low, increasing", "provided", retention, "updated", minHistoryRetention)
+			cacheConfig.BodyRetention = minHistoryRetention
+		}
+		if retention := cacheConfig.TxLookupRetention; retention > 0 && retention < minHistoryRetention {
+			log.Warn("Transaction lookup retention too low, increasing", "provided", retention, "updated", minHistoryRetention)
+			cacheConfig.TxLookupRetention = minHistoryRetention
+		}
+		// The transactions to drop the lookups of are found in the bodies, so if both
+		// are pruned, the lookups can't outlive the bodies
+		if body, lookup := cacheConfig.BodyRetention, cacheConfig.TxLookupRetention; body > 0 && lookup > body {
+			log.Warn("Transaction lookup retention exceeds body retention, decreasing", "provided", lookup, "updated", body)
+			cacheConfig.TxLookupRetention = body
+		}
+		bc.wg.Add(1)
+		go bc.pruneHistoryLoop()
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -197,9 +255,11 @@ func (bc *BlockChain) loadLastState() error {
 	}
 	// Make sure the state associated with the block is available
 	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
-		// Dangling block without a state associated, init from scratch
-		log.Warn("Head state missing, resetting chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
-		return bc.Reset()
+		// Dangling block without a state associated, rewind to one that has it
+		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		if err := bc.repair(&currentBlock); err != nil {
+			return err
+		}
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock = currentBlock
@@ -233,6 +293,29 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// repair tries to repair the current blockchain by rolling back the current block
+// until one with associated state is found. This is needed to fix incomplete db
+// writes caused either by crashes/power outages, or simply non-committed tries
+// of a pruning node.
+//
+// This method only rolls back the current block. The current header and current
+// fast block are left intact.
+func (bc *BlockChain) repair(head **types.Block) error {
+	for {
+		// Abort if we've rewound to a head block that does have associated state
+		if _, err := state.New((*head).Root(), bc.stateCache); err == nil {
+			log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
+			return WriteHeadBlockHash(bc.chainDb, (*head).Hash())
+		}
+		// Otherwise rewind one block and recheck state availability there
+		parent := bc.GetBlock((*head).ParentHash(), (*head).NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("missing block %d [%x]", (*head).NumberU64()-1, (*head).ParentHash())
+		}
+		*head = parent
+	}
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -250,6 +333,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Discard any frozen blocks above the new head, they're not immutable after all
+	if ancients, ok := bc.chainDb.(AncientWriter); ok {
+		if err := ancients.TruncateAncients(head + 1); err != nil {
+			log.Crit("Failed to truncate ancient store", "head", head, "err", err)
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -262,8 +352,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	}
 	if bc.currentBlock != nil {
 		if _, err := state.New(bc.currentBlock.Root(), bc.stateCache); err != nil {
-			// Rewound state missing, rolled back to before pivot, reset to genesis
-			bc.currentBlock = nil
+			// Rewound state missing, rolled back to the last block with state
+			if err := bc.repair(&bc.currentBlock); err != nil {
+				bc.currentBlock = nil
+			}
 		}
 	}
 	// Rewind the fast block in a simpleton way to the target head
@@ -283,9 +375,22 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
+	bc.rebuildSnapshot(bc.currentBlock.Root())
 	return bc.loadLastState()
 }
 
+// rebuildSnapshot discards all the snapshot layers and restarts the snapshot
+// generation from the given state, needed whenever the head jumps to a state
+// the snapshot tree doesn't know about.
+func (bc *BlockChain) rebuildSnapshot(root common.Hash) {
+	if bc.snaps == nil {
+		return
+	}
+	if err := bc.snaps.Rebuild(root); err != nil {
+		log.Error("Failed to rebuild state snapshot", "err", err)
+	}
+}
+
 // FastSyncCommitHead sets the current head block to the one defined by the hash
 // irrelevant what the chain contents were prior.
 func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
@@ -302,6 +407,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	bc.currentBlock = block
 	bc.mu.Unlock()
 
+	bc.rebuildSnapshot(block.Root())
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
 	return nil
 }
@@ -384,7 +490,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -415,6 +521,7 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	bc.hc.SetGenesis(bc.genesisBlock.Header())
 	bc.hc.SetCurrentHeader(bc.genesisBlock.Header())
 	bc.currentFastBlock = bc.genesisBlock
+	bc.rebuildSnapshot(bc.genesisBlock.Root())
 
 	return nil
 }
@@ -521,8 +628,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.chainDb, hash, number)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -625,6 +731,38 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Flatten the snapshot layers into the disk layer of the head state, which
+	// is persisted below, so that the snapshot can be reused on restart.
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		bc.snaps.Release()
+	}
+	// Ensure the state of a recent block is also stored to disk before exiting.
+	// It is fine if this state does not exist (fast start/stop cycle), but it is
+	// advisable to leave an N block gap from the head so on a restart we do the
+	// rather expensive state regeneration on a small section only.
+	if !bc.cacheConfig.Disabled {
+		triedb := bc.stateCache.TrieDB()
+		for _, offset := range []uint64{0, triesInMemory - 1} {
+			if number := bc.CurrentBlock().NumberU64(); number > offset {
+				recent := bc.GetBlockByNumber(number - offset)
+
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if err := triedb.Commit(recent.Root(), true); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
+				}
+			}
+		}
+		for !bc.triegc.Empty() {
+			triedb.Dereference(bc.triegc.PopItem().(common.Hash), common.Hash{})
+		}
+		if size, _ := triedb.Size(); size != 0 {
+			log.Error("Dangling trie nodes after full cleanup")
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -645,6 +783,15 @@ func (bc *BlockChain) procFutureBlocks() {
 	}
 }
 
+// now returns the current time, as defined by the consensus engine if it keeps
+// its own clock.
+func (bc *BlockChain) now() time.Time {
+	if clock, ok := bc.engine.(consensus.Clock); ok {
+		return clock.Now()
+	}
+	return time.Now()
+}
+
 // WriteStatus status of write
 type WriteStatus byte
 
@@ -828,9 +975,67 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := state.CommitTo(batch, bc.config.IsEIP158(block.Number())); err != nil {
+	root, err := state.CommitTo(bc.stateCache.TrieDB(), bc.config.IsEIP158(block.Number()))
+	if err != nil {
 		return NonStatTy, err
 	}
+	triedb := bc.stateCache.TrieDB()
+
+	// If we're running an archive node, always flush
+	if bc.cacheConfig.Disabled {
+		if err := triedb.Commit(root, false); err != nil {
+			return NonStatTy, err
+		}
+	} else {
+		// Full but not archive node, do proper garbage collection
+		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
+		bc.triegc.Push(root, -float32(block.NumberU64()))
+
+		if current := block.NumberU64(); current > triesInMemory {
+			// Find the next state trie we need to commit
+			header := bc.GetHeaderByNumber(current - triesInMemory)
+			chosen := current - triesInMemory
+
+			// Only write to disk if we exceeded our memory allowance *and* also have at
+			// least a given number of tries gapped.
+			var (
+				size, _ = triedb.Size()
+				limit   = common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+			)
+			if size > limit || bc.gcproc > bc.cacheConfig.TrieTimeLimit {
+				// If we're exceeding limits but haven't reached a large enough memory gap,
+				// warn the user that the system is becoming unstable.
+				if header == nil {
+					// The canonical chain is behind, we're reorging a low diff sidechain
+					log.Warn("Reorg in progress, trie commit postponed", "number", chosen)
+				} else {
+					if chosen < bc.lastWrite+triesInMemory {
+						switch {
+						case size >= 2*limit:
+							log.Warn("State memory usage too high, committing", "size", size, "limit", limit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+						case bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit:
+							log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+						}
+					}
+					// Flush an entire trie and restart the counters
+					if err := triedb.Commit(header.Root, true); err != nil {
+						return NonStatTy, err
+					}
+					bc.lastWrite = chosen
+					bc.gcproc = 0
+				}
+			}
+			// Garbage collect anything below our required write retention
+			for !bc.triegc.Empty() {
+				root, number := bc.triegc.Pop()
+				if uint64(-number) > chosen {
+					bc.triegc.Push(root, number)
+					break
+				}
+				triedb.Dereference(root.(common.Hash), common.Hash{})
+			}
+		}
+	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
 		return NonStatTy, err
 	}
@@ -957,7 +1162,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 				// Allow up to MaxFuture second in the future blocks. If this limit
 				// is exceeded the chain is discarded and processed at a later time
 				// if given.
-				max := big.NewInt(time.Now().Unix() + maxTimeFutureBlocks)
+				max := big.NewInt(bc.now().Unix() + maxTimeFutureBlocks)
 				if block.Time().Cmp(max) > 0 {
 					return i, events, coalescedLogs, fmt.Errorf("future block: %v > %v", block.Time(), max)
 				}
@@ -983,7 +1188,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -999,6 +1204,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		proctime := time.Since(bstart)
+
 		// Write the block to the chain and get the status.
 		status, err := bc.WriteBlockAndState(block, receipts, state)
 		if err != nil {
@@ -1014,6 +1221,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			events = append(events, ChainEvent{block, block.Hash(), logs})
 			lastCanon = block
 
+			// Only count canonical blocks for GC processing time
+			bc.gcproc += proctime
+
 		case SideStatTy:
 			log.Info("Inserted forked block", "number", block.Number(), "hash", block.Hash(), "elapsed",
 				common.PrettyDuration(time.Since(bstart)), "txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()))
@@ -1237,17 +1447,26 @@ type BadBlockArgs struct {
 func (bc *BlockChain) BadBlocks() ([]BadBlockArgs, error) {
 	headers := make([]BadBlockArgs, 0, bc.badBlocks.Len())
 	for _, hash := range bc.badBlocks.Keys() {
-		if hdr, exist := bc.badBlocks.Peek(hash); exist {
-			header := hdr.(*types.Header)
+		if blk, exist := bc.badBlocks.Peek(hash); exist {
+			header := blk.(*types.Block).Header()
 			headers = append(headers, BadBlockArgs{header.Hash(), header})
 		}
 	}
 	return headers, nil
 }
 
+// BadBlock retrieves a bad block seen on the network by hash, or nil if it's
+// not in the bad-block cache.
+func (bc *BlockChain) BadBlock(hash common.Hash) *types.Block {
+	if blk, exist := bc.badBlocks.Peek(hash); exist {
+		return blk.(*types.Block)
+	}
+	return nil
+}
+
 // addBadBlock adds a bad block to the bad-block LRU cache
 func (bc *BlockChain) addBadBlock(block *types.Block) {
-	bc.badBlocks.Add(block.Header().Hash(), block.Header())
+	bc.badBlocks.Add(block.Hash(), block)
 }
 
 // reportBlock logs a bad block error.
@@ -1380,6 +1599,10 @@ func (bc *BlockChain) Config() *params.ChainConfig { return bc.config }
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+// Recent state tries of non-archive nodes only live in its trie database.
+func (bc *BlockChain) StateCache() state.Database { return bc.stateCache }
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
@@ -0,0 +1,164 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+const (
+	// historyPruneInterval is the time between two runs of the history pruner.
+	historyPruneInterval = time.Minute
+
+	// minHistoryRetention is the minimum number of recent blocks to retain the
+	// bodies and receipts of, so that chain reorganisations can be processed.
+	minHistoryRetention = triesInMemory
+)
+
+// HistoryTail returns the number of the oldest block whose body and receipts
+// are retained. The data of every block below it, except the genesis, has been
+// pruned by the history retention policy.
+func (bc *BlockChain) HistoryTail() uint64 {
+	return atomic.LoadUint64(&bc.bodyTail)
+}
+
+// TxLookupTail returns the number of the oldest block whose transactions can
+// be looked up by hash.
+func (bc *BlockChain) TxLookupTail() uint64 {
+	return atomic.LoadUint64(&bc.lookupTail)
+}
+
+// CheckHistory returns an error wrapping ErrHistoryPruned if the body and
+// receipts of the block with the given number were removed from the database
+// by the history retention policy.
+func CheckHistory(db DatabaseReader, number uint64) error {
+	if tail := GetBodyTail(db); number > 0 && number < tail {
+		return fmt.Errorf("%v: block #%d is below the retention tail #%d", ErrHistoryPruned, number, tail)
+	}
+	return nil
+}
+
+// pruneHistoryLoop periodically prunes the history outside of the retention
+// windows, until the blockchain is stopped.
+func (bc *BlockChain) pruneHistoryLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.pruneHistory()
+
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// pruneHistory deletes the bodies, receipts and transaction lookup entries of
+// the canonical blocks that fell out of the configured retention windows.
+// Headers are always kept.
+func (bc *BlockChain) pruneHistory() {
+	var (
+		head        = bc.CurrentBlock().NumberU64()
+		bodyLimit   uint64
+		lookupLimit uint64
+	)
+	if retention := bc.cacheConfig.BodyRetention; retention > 0 && head >= retention {
+		bodyLimit = head - retention + 1
+	}
+	if retention := bc.cacheConfig.TxLookupRetention; retention > 0 && head >= retention {
+		lookupLimit = head - retention + 1
+	}
+	// Prune the lookup entries first, as they need the bodies still around
+	bc.pruneRange("lookups", &bc.lookupTail, lookupLimit, WriteTxLookupTail, func(batch xdndb.Batch, hash common.Hash, number uint64) {
+		if body := GetBody(bc.chainDb, hash, number); body != nil {
+			for _, tx := range body.Transactions {
+				DeleteTxLookupEntry(batch, tx.Hash())
+			}
+		}
+	})
+	bc.pruneRange("bodies", &bc.bodyTail, bodyLimit, WriteBodyTail, func(batch xdndb.Batch, hash common.Hash, number uint64) {
+		DeleteBody(batch, hash, number)
+		DeleteBlockReceipts(batch, hash, number)
+	})
+}
+
+// pruneRange runs the prune callback on every canonical block from the given
+// tail up to (but excluding) the limit, advancing the tail as it goes. The
+// genesis block is never pruned.
+func (bc *BlockChain) pruneRange(kind string, tail *uint64, limit uint64, writeTail func(xdndb.Putter, uint64) error, prune func(xdndb.Batch, common.Hash, uint64)) {
+	from := atomic.LoadUint64(tail)
+	if from == 0 {
+		from = 1
+	}
+	if from >= limit {
+		return
+	}
+	var (
+		batch  = bc.chainDb.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+		number = from
+	)
+	// flush writes out the pending deletions along with the new tail
+	flush := func(next uint64) bool {
+		writeTail(batch, next)
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to prune chain history", "kind", kind, "number", next, "err", err)
+			return false
+		}
+		batch.Reset()
+		atomic.StoreUint64(tail, next)
+		return true
+	}
+	for ; number < limit; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		prune(batch, hash, number)
+
+		if batch.ValueSize() > xdndb.IdealBatchSize {
+			if !flush(number + 1) {
+				return
+			}
+
+			// Bail out if the chain is shutting down, the rest is pruned on restart
+			select {
+			case <-bc.quit:
+				return
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Pruning chain history", "kind", kind, "number", number, "limit", limit, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+	}
+	if flush(number) {
+		log.Debug("Pruned chain history", "kind", kind, "from", from, "to", number, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
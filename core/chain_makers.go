@@ -235,7 +235,7 @@ func newCanonical(n int, full bool) (xdndb.Database, *BlockChain, error) {
 	db, _ := xdndb.NewMemDatabase()
 	genesis := gspec.MustCommit(db)
 
-	blockchain, _ := NewBlockChain(db, params.AllDnpashProtocolChanges, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, &CacheConfig{Disabled: true}, params.AllDnpashProtocolChanges, ethash.NewFaker(), vm.Config{})
 	// Create and inject the requested chain
 	if n == 0 {
 		return db, blockchain, nil
@@ -0,0 +1,202 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/core/state/snapshot"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+// errNotIterable is returned if the key-value store of a chain database can't
+// be walked over, i.e. it isn't backed by leveldb.
+var errNotIterable = errors.New("database doesn't support iteration")
+
+// dbStat is the number of entries and their total size in a single category of
+// the database keyspace.
+type dbStat struct {
+	name  string
+	count uint64
+	size  common.StorageSize
+}
+
+// add accounts a single database entry of the given size to the category.
+func (s *dbStat) add(size int) {
+	s.count++
+	s.size += common.StorageSize(size)
+}
+
+// lightPrefixes are the key prefixes of the light client tables, see
+// light/postprocess.go.
+var lightPrefixes = [][]byte{
+	[]byte("cht-"), []byte("chtRoot-"), []byte("chtIndex-"),
+	[]byte("blt-"), []byte("bltRoot-"), []byte("bltIndex-"),
+}
+
+// metadataKeys are the single value keys tracking the chain and database
+// progress, too small to warrant a category of their own each.
+var metadataKeys = [][]byte{
+	headHeaderKey, headBlockKey, headFastKey, bodyTailKey, txLookupTailKey,
+	[]byte("BlockchainVersion"),
+	[]byte("dbUpgrade_20170714deduplicateData"),
+	snapshot.SnapshotRootKey,
+}
+
+// hasAnyPrefix reports whether the key starts with any of the given prefixes.
+func hasAnyPrefix(key []byte, prefixes [][]byte) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMetadataKey reports whether the key is one of the metadata keys.
+func isMetadataKey(key []byte) bool {
+	for _, meta := range metadataKeys {
+		if bytes.Equal(key, meta) {
+			return true
+		}
+	}
+	return false
+}
+
+// InspectDatabase walks the entire key-value store of a chain database and
+// prints the number of entries and the storage size taken up by each kind of
+// data, followed by the size of the ancient store if there is one.
+func InspectDatabase(db xdndb.Database, w io.Writer) error {
+	ldb, ok := KeyValueStore(db).(*xdndb.LDBDatabase)
+	if !ok {
+		return errNotIterable
+	}
+	var (
+		headers      = dbStat{name: "Headers"}
+		tds          = dbStat{name: "Total difficulties"}
+		canonical    = dbStat{name: "Canonical hashes"}
+		numbers      = dbStat{name: "Block hash->number mappings"}
+		bodies       = dbStat{name: "Bodies"}
+		receipts     = dbStat{name: "Receipts"}
+		lookups      = dbStat{name: "Transaction lookups"}
+		bloomBits    = dbStat{name: "Bloombits"}
+		bloomIndex   = dbStat{name: "Bloombits index"}
+		tries        = dbStat{name: "Trie nodes and contract code"}
+		preimages    = dbStat{name: "Trie preimages"}
+		accountSnaps = dbStat{name: "Account snapshot"}
+		storageSnaps = dbStat{name: "Storage snapshot"}
+		configs      = dbStat{name: "Chain configs"}
+		lightTries   = dbStat{name: "Light client CHT/bloom tries"}
+		legacy       = dbStat{name: "Legacy receipts and tx metadata"}
+		metadata     = dbStat{name: "Metadata"}
+		unaccounted  = dbStat{name: "Unaccounted"}
+		total        common.StorageSize
+		entries      uint64
+		start        = time.Now()
+		logged       = time.Now()
+		preimageBase = []byte(preimagePrefix)
+	)
+	it := ldb.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		var (
+			key  = it.Key()
+			size = len(key) + len(it.Value())
+		)
+		total += common.StorageSize(size)
+		entries++
+
+		switch {
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+			headers.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength+len(tdSuffix) && bytes.HasSuffix(key, tdSuffix):
+			tds.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(numSuffix) && bytes.HasSuffix(key, numSuffix):
+			canonical.add(size)
+		case bytes.HasPrefix(key, blockHashPrefix) && len(key) == len(blockHashPrefix)+common.HashLength:
+			numbers.add(size)
+		case bytes.HasPrefix(key, bodyPrefix) && len(key) == len(bodyPrefix)+8+common.HashLength:
+			bodies.add(size)
+		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+			receipts.add(size)
+		case bytes.HasPrefix(key, lookupPrefix) && len(key) == len(lookupPrefix)+common.HashLength:
+			lookups.add(size)
+		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+10+common.HashLength:
+			bloomBits.add(size)
+		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
+			bloomIndex.add(size)
+		case bytes.HasPrefix(key, preimageBase) && len(key) == len(preimageBase)+common.HashLength:
+			preimages.add(size)
+		case bytes.HasPrefix(key, snapshot.AccountPrefix) && len(key) == len(snapshot.AccountPrefix)+common.HashLength:
+			accountSnaps.add(size)
+		case bytes.HasPrefix(key, snapshot.StoragePrefix) && len(key) == len(snapshot.StoragePrefix)+2*common.HashLength:
+			storageSnaps.add(size)
+		case bytes.HasPrefix(key, configPrefix) && len(key) == len(configPrefix)+common.HashLength:
+			configs.add(size)
+		case len(key) == common.HashLength:
+			tries.add(size)
+		case bytes.HasPrefix(key, oldReceiptsPrefix) || (len(key) == common.HashLength+len(oldTxMetaSuffix) && bytes.HasSuffix(key, oldTxMetaSuffix)):
+			legacy.add(size)
+		case hasAnyPrefix(key, lightPrefixes):
+			lightTries.add(size)
+		case isMetadataKey(key):
+			metadata.add(size)
+		default:
+			unaccounted.add(size)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "entries", entries, "size", total, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	stats := []dbStat{
+		headers, tds, canonical, numbers, bodies, receipts, lookups, bloomBits, bloomIndex,
+		tries, preimages, accountSnaps, storageSnaps, configs, lightTries, legacy, metadata, unaccounted,
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tCATEGORY\tITEMS\tSIZE")
+	for _, stat := range stats {
+		fmt.Fprintf(tw, "Key-Value store\t%s\t%d\t%v\n", stat.name, stat.count, stat.size)
+	}
+	fmt.Fprintf(tw, "Key-Value store\tTotal\t\t%v\n", total)
+
+	// Report the ancient store tables too, if there is one
+	if frdb, ok := db.(*freezerdb); ok {
+		var ancientTotal common.StorageSize
+		for _, name := range []string{freezerHashTable, freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerDifficultyTable} {
+			size, err := frdb.tables[name].size()
+			if err != nil {
+				return err
+			}
+			ancientTotal += size
+			fmt.Fprintf(tw, "Ancient store\t%s\t%d\t%v\n", name, frdb.frozen, size)
+		}
+		fmt.Fprintf(tw, "Ancient store\tTotal\t\t%v\n", ancientTotal)
+	}
+	return tw.Flush()
+}
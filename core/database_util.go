@@ -48,6 +48,10 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 
+	// History retention markers, tracking the oldest block whose data is kept.
+	bodyTailKey     = []byte("BodyTail")
+	txLookupTailKey = []byte("TxLookupTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	tdSuffix            = []byte("t") // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
@@ -89,9 +93,32 @@ func encodeBlockNumber(number uint64) []byte {
 	return enc
 }
 
+// readAncient retrieves a frozen item from the ancient store of the database,
+// or nil if the database has no ancient store or the item isn't frozen.
+func readAncient(db DatabaseReader, kind string, number uint64) []byte {
+	ancients, ok := db.(AncientReader)
+	if !ok {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
+	return data
+}
+
+// readAncientBlock retrieves a frozen item belonging to the block with the given
+// hash, or nil if the block isn't the frozen canonical one at its height.
+func readAncientBlock(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if frozen := readAncient(db, freezerHashTable, number); len(frozen) == 0 || common.BytesToHash(frozen) != hash {
+		return nil
+	}
+	return readAncient(db, kind, number)
+}
+
 // GetCanonicalHash retrieves a hash assigned to a canonical block number.
 func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
-	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
+	data := readAncient(db, freezerHashTable, number)
+	if len(data) == 0 {
+		data, _ = db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
@@ -146,13 +173,46 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetBodyTail retrieves the number of the oldest block whose body and receipts
+// are retained. The data of every block below it, except the genesis, has been
+// pruned by the history retention policy.
+func GetBodyTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(bodyTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// GetTxLookupTail retrieves the number of the oldest block whose transaction
+// lookup entries are retained.
+func GetTxLookupTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(txLookupTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := readAncientBlock(db, freezerHeaderTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(headerKey(hash, number))
 	return data
 }
 
+// HasHeader checks if a block header is present in the database or not.
+func HasHeader(db xdndb.Database, hash common.Hash, number uint64) bool {
+	if len(readAncientBlock(db, freezerHashTable, hash, number)) > 0 {
+		return true
+	}
+	ok, _ := db.Has(headerKey(hash, number))
+	return ok
+}
+
 // GetHeader retrieves the block header corresponding to the hash, nil if none
 // found.
 func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header {
@@ -170,10 +230,22 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := readAncientBlock(db, freezerBodiesTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(blockBodyKey(hash, number))
 	return data
 }
 
+// HasBody checks if a block body is present in the database or not.
+func HasBody(db xdndb.Database, hash common.Hash, number uint64) bool {
+	if len(readAncientBlock(db, freezerBodiesTable, hash, number)) > 0 {
+		return true
+	}
+	ok, _ := db.Has(blockBodyKey(hash, number))
+	return ok
+}
+
 func headerKey(hash common.Hash, number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
@@ -182,6 +254,14 @@ func blockBodyKey(hash common.Hash, number uint64) []byte {
 	return append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func tdKey(hash common.Hash, number uint64) []byte {
+	return append(headerKey(hash, number), tdSuffix...)
+}
+
+func blockReceiptsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash, nil if none found.
 func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
@@ -200,7 +280,10 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data := readAncientBlock(db, freezerDifficultyTable, hash, number)
+	if len(data) == 0 {
+		data, _ = db.Get(tdKey(hash, number))
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -235,7 +318,10 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data := readAncientBlock(db, freezerReceiptTable, hash, number)
+	if len(data) == 0 {
+		data, _ = db.Get(blockReceiptsKey(hash, number))
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -276,6 +362,9 @@ func GetTransaction(db DatabaseReader, hash common.Hash) (*types.Transaction, co
 
 	if blockHash != (common.Hash{}) {
 		body := GetBody(db, blockHash, blockNumber)
+		if body == nil && CheckHistory(db, blockNumber) != nil {
+			return nil, common.Hash{}, 0, 0
+		}
 		if body == nil || len(body.Transactions) <= int(txIndex) {
 			log.Error("Transaction referenced missing", "number", blockNumber, "hash", blockHash, "index", txIndex)
 			return nil, common.Hash{}, 0, 0
@@ -311,6 +400,9 @@ func GetReceipt(db DatabaseReader, hash common.Hash) (*types.Receipt, common.Has
 
 	if blockHash != (common.Hash{}) {
 		receipts := GetBlockReceipts(db, blockHash, blockNumber)
+		if len(receipts) == 0 && CheckHistory(db, blockNumber) != nil {
+			return nil, common.Hash{}, 0, 0
+		}
 		if len(receipts) <= int(receiptIndex) {
 			log.Error("Receipt refereced missing", "number", blockNumber, "hash", blockHash, "index", receiptIndex)
 			return nil, common.Hash{}, 0, 0
@@ -374,6 +466,24 @@ func WriteHeadFastBlockHash(db xdndb.Putter, hash common.Hash) error {
 	return nil
 }
 
+// WriteBodyTail stores the number of the oldest block whose body and receipts
+// are retained.
+func WriteBodyTail(db xdndb.Putter, number uint64) error {
+	if err := db.Put(bodyTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store body tail", "err", err)
+	}
+	return nil
+}
+
+// WriteTxLookupTail stores the number of the oldest block whose transaction
+// lookup entries are retained.
+func WriteTxLookupTail(db xdndb.Putter, number uint64) error {
+	if err := db.Put(txLookupTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction lookup tail", "err", err)
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db xdndb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
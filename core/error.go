@@ -32,4 +32,8 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrHistoryPruned is returned if the body or receipts of a block were
+	// removed by the history retention policy of the node.
+	ErrHistoryPruned = errors.New("history pruned")
 )
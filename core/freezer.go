@@ -0,0 +1,530 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/params"
+	"github.com/xdn/go-xdn/xdndb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+)
+
+const (
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient
+// tables. Hashes and difficulties don't compress well.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:       true,
+	freezerHeaderTable:     false,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// AncientReader is implemented by chain databases keeping the old, immutable
+// segments of the chain in an append-only freezer next to the key-value store.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks held in the ancient store.
+	Ancients() (uint64, error)
+}
+
+// AncientWriter is implemented by chain databases able to discard the most
+// recent part of their ancient store, e.g. to rewind the chain into it.
+type AncientWriter interface {
+	// TruncateAncients discards all but the first n ancient data from the ancient store.
+	TruncateAncients(n uint64) error
+}
+
+// freezer is an append-only database to store immutable chain data into flat
+// files. The append only nature ensures that disk writes are minimized, and the
+// data is split into a table per kind of chain data, each made of bounded size
+// files, so it can be moved to a cheaper disk than the state.
+type freezer struct {
+	frozen uint64     // Number of blocks already frozen (atomic)
+	lock   sync.Mutex // Serializes freezing batches and truncations
+
+	tables map[string]*freezerTable // Data tables for storing everything
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers.
+func newFreezer(datadir string, namespace string) (*freezer, error) {
+	freezer := &freezer{
+		tables: make(map[string]*freezerTable),
+		quit:   make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		readMeter, writeMeter := newFreezerMeters(namespace, name)
+
+		table, err := newTable(datadir, name, readMeter, writeMeter, disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *freezer) Close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files.
+//
+// Notably, this function is lock free but kind of thread-safe. All out-of-order
+// injection will be rejected. But if two injections with same number happen at
+// the same time, we can get into the trouble.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	// Rollback all inserted data if any insertion below failed to ensure
+	// the tables won't out of sync.
+	defer func() {
+		if err != nil {
+			rerr := f.repair()
+			if rerr != nil {
+				log.Crit("Failed to repair freezer", "err", rerr)
+			}
+			log.Info("Append ancient failed", "number", number, "err", err)
+		}
+	}()
+	if frozen := atomic.LoadUint64(&f.frozen); number != frozen {
+		return fmt.Errorf("%v: have %d want %d", errOutOrderInsertion, number, frozen)
+	}
+	// Inject all the components into the relevant data tables
+	blobs := []struct {
+		table string
+		blob  []byte
+	}{
+		{freezerHashTable, hash},
+		{freezerHeaderTable, header},
+		{freezerBodiesTable, body},
+		{freezerReceiptTable, receipts},
+		{freezerDifficultyTable, td},
+	}
+	for _, item := range blobs {
+		if err := f.tables[item.table].Append(number, item.blob); err != nil {
+			log.Error("Failed to append ancient data", "table", item.table, "number", number, "hash", common.BytesToHash(hash), "err", err)
+			return err
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// sync flushes all data tables to disk.
+func (f *freezer) sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// repair truncates all data tables to the same length.
+func (f *freezer) repair() error {
+	min := uint64(math.MaxUint64)
+	for _, table := range f.tables {
+		items := atomic.LoadUint64(&table.items)
+		if min > items {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+// The given database is the bare key-value store, so all reads bypass the freezer.
+//
+// This functionality is deliberately broken off from block importing to avoid
+// incurring additional data shuffling delays on block propagation.
+func (f *freezer) freeze(db xdndb.Database) {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case <-f.quit:
+			log.Info("Freezer shutting down")
+			return
+		default:
+		}
+		// Retrieve the freezing threshold
+		hash := GetHeadBlockHash(db)
+		if hash == (common.Hash{}) {
+			log.Debug("Current full block hash unavailable") // new chain, empty database
+			f.wait(freezerRecheckInterval)
+			continue
+		}
+		number := GetBlockNumber(db, hash)
+		frozen := atomic.LoadUint64(&f.frozen)
+		switch {
+		case number == missingNumber:
+			log.Error("Current full block number unavailable", "hash", hash)
+			f.wait(freezerRecheckInterval)
+			continue
+
+		case number < params.ImmutabilityThreshold:
+			log.Debug("Current full block not old enough", "number", number, "hash", hash, "delay", params.ImmutabilityThreshold)
+			f.wait(freezerRecheckInterval)
+			continue
+
+		case number-params.ImmutabilityThreshold <= frozen:
+			log.Debug("Ancient blocks frozen already", "number", number, "hash", hash, "frozen", frozen)
+			f.wait(freezerRecheckInterval)
+			continue
+		}
+		// Seems we have data ready to be frozen, process in usable batches
+		if !f.freezeBatch(db, number-params.ImmutabilityThreshold) {
+			f.wait(freezerRecheckInterval)
+		}
+	}
+}
+
+// freezeBatch moves a batch of canonical blocks below limit from the key-value
+// store into the freezer, returning whxdner a full batch was frozen. The batch
+// is done under the freezer lock so a concurrent truncation can't shift the
+// frozen count underneath it.
+func (f *freezer) freezeBatch(db xdndb.Database, limit uint64) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Reload the frozen count now that truncations are locked out
+	frozen := atomic.LoadUint64(&f.frozen)
+	if limit <= frozen {
+		return false
+	}
+	if limit-frozen > freezerBatchLimit {
+		limit = frozen + freezerBatchLimit
+	}
+	var (
+		start    = time.Now()
+		first    = frozen
+		ancients = make([]common.Hash, 0, limit-frozen)
+		tail     = GetBodyTail(db)
+	)
+	for ; frozen < limit; frozen++ {
+		// Retrieves all the components of the canonical block
+		hash := GetCanonicalHash(db, frozen)
+		if hash == (common.Hash{}) {
+			log.Error("Canonical hash missing, can't freeze", "number", frozen)
+			break
+		}
+		header := GetHeaderRLP(db, hash, frozen)
+		if len(header) == 0 {
+			log.Error("Block header missing, can't freeze", "number", frozen, "hash", hash)
+			break
+		}
+		// The body and receipts of blocks below the history tail were pruned,
+		// freeze empty placeholders for them
+		pruned := frozen > 0 && frozen < tail
+
+		body := GetBodyRLP(db, hash, frozen)
+		if len(body) == 0 && !pruned {
+			log.Error("Block body missing, can't freeze", "number", frozen, "hash", hash)
+			break
+		}
+		receipts, _ := db.Get(blockReceiptsKey(hash, frozen))
+		if len(receipts) == 0 && !pruned {
+			log.Error("Block receipts missing, can't freeze", "number", frozen, "hash", hash)
+			break
+		}
+		td, _ := db.Get(tdKey(hash, frozen))
+		if len(td) == 0 {
+			log.Error("Total difficulty missing, can't freeze", "number", frozen, "hash", hash)
+			break
+		}
+		log.Trace("Deep froze ancient block", "number", frozen, "hash", hash)
+
+		// Inject all the components into the relevant data tables
+		if err := f.AppendAncient(frozen, hash[:], header, body, receipts, td); err != nil {
+			break
+		}
+		ancients = append(ancients, hash)
+	}
+	// Batch of blocks have been frozen, flush them before wiping from leveldb
+	if err := f.sync(); err != nil {
+		log.Crit("Failed to flush frozen tables", "err", err)
+	}
+	// Wipe out all data from the active database
+	for i, hash := range ancients {
+		// Always keep the genesis block in the active database
+		number := first + uint64(i)
+		if number == 0 {
+			continue
+		}
+		deleteAncientBlock(db, hash, number)
+	}
+	// Wipe out side chain also
+	for i := range ancients {
+		// Always keep the genesis block in the active database
+		number := first + uint64(i)
+		if number == 0 {
+			continue
+		}
+		for _, hash := range sideHashes(db, number, ancients[i]) {
+			log.Trace("Deleting side chain", "number", number, "hash", hash)
+			DeleteBlock(db, hash, number)
+		}
+	}
+	// Log something friendly for the user
+	context := []interface{}{
+		"blocks", len(ancients), "elapsed", common.PrettyDuration(time.Since(start)), "number", first + uint64(len(ancients)) - 1,
+	}
+	if n := len(ancients); n > 0 {
+		context = append(context, []interface{}{"hash", ancients[n-1]}...)
+	}
+	log.Info("Deep froze chain segment", context...)
+
+	// Avoid database thrashing with tiny writes
+	return len(ancients) >= freezerBatchLimit
+}
+
+// wait sleeps for the given duration, returning early if the freezer is closed.
+func (f *freezer) wait(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-f.quit:
+	}
+}
+
+// deleteAncientBlock removes a frozen canonical block from the key-value store,
+// keeping only its hash to number mapping.
+func deleteAncientBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
+	DeleteCanonicalHash(db, number)
+	db.Delete(headerKey(hash, number))
+	DeleteBody(db, hash, number)
+	DeleteBlockReceipts(db, hash, number)
+	DeleteTd(db, hash, number)
+}
+
+// sideHashes returns the hashes of all the non-canonical headers stored in the
+// key-value store at the given height. Only leveldb databases can be iterated,
+// side chains are left in place for anything else.
+func sideHashes(db xdndb.Database, number uint64, canonical common.Hash) []common.Hash {
+	ldb, ok := db.(*xdndb.LDBDatabase)
+	if !ok {
+		return nil
+	}
+	prefix := append(headerPrefix, encodeBlockNumber(number)...)
+
+	it := ldb.LDB().NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		// Only header keys have a hash right after the number
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			if hash := common.BytesToHash(key[len(prefix):]); hash != canonical {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes
+}
+
+// freezerdb is a database wrapper that enabled freezer data retrievals.
+type freezerdb struct {
+	xdndb.Database
+	*freezer
+}
+
+// Close implements xdndb.Database, closing both the freezer and the key-value
+// store.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into flat
+// files at the given directory.
+//
+// If readonly is set, the background freezing is not started, so maintenance
+// tools may operate on the database without chain data moving underneath them.
+func NewDatabaseWithFreezer(db xdndb.Database, freezerDir string, namespace string, readonly bool) (xdndb.Database, error) {
+	frdb, err := newFreezer(freezerDir, namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer can be stored separately from the user's key-value
+	// database, there's a fairly high probability that the user requests
+	// invalid combinations of them. Make sure they belong together.
+	if kvgenesis := GetCanonicalHash(db, 0); kvgenesis != (common.Hash{}) {
+		if frgenesis, err := frdb.Ancient(freezerHashTable, 0); err == nil && common.BytesToHash(frgenesis) != kvgenesis {
+			frdb.Close()
+			return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+		}
+		// The key-value store must continue right where the freezer ends (or at
+		// block #1 if nothing was frozen yet), otherwise chain segments are missing
+		next, _ := frdb.Ancients()
+		if next == 0 {
+			next = 1
+		}
+		if GetCanonicalHash(db, next) == (common.Hash{}) {
+			if head := GetBlockNumber(db, GetHeadHeaderHash(db)); head != missingNumber && head >= next {
+				frdb.Close()
+				if next == 1 {
+					return nil, errors.New("ancient chain segments already extracted, please set --datadir.ancient to the correct path")
+				}
+				return nil, fmt.Errorf("gap (#%d) in the chain between ancients and leveldb", next)
+			}
+		}
+	}
+	if !readonly {
+		frdb.wg.Add(1)
+		go frdb.freeze(db)
+	}
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
+
+// KeyValueStore returns the key-value store underlying a chain database,
+// stripping away the ancient store if it has one.
+func KeyValueStore(db xdndb.Database) xdndb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
@@ -0,0 +1,477 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/metrics"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+var (
+	// errClosed is returned if an operation attempts to read from or write to the
+	// freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+
+	// errOutOfBounds is returned if the item requested is not contained within the
+	// freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+// freezerTableSize defines the maximum size of a freezer data file.
+const freezerTableSize = 2 * 1000 * 1000 * 1000
+
+// indexEntrySize is the size of a single serialized index entry.
+const indexEntrySize = 8
+
+// indexEntry contains the number/id of the file that the data resides in, as
+// well as the offset within the file to the end of the data.
+type indexEntry struct {
+	filenum uint32 // data file holding the item
+	offset  uint32 // end offset of the item within the data file
+}
+
+// unmarshalBinary deserializes binary b into the index entry.
+func (i *indexEntry) unmarshalBinary(b []byte) {
+	i.filenum = binary.BigEndian.Uint32(b[:4])
+	i.offset = binary.BigEndian.Uint32(b[4:8])
+}
+
+// marshalBinary serializes the index entry into binary.
+func (i *indexEntry) marshalBinary() []byte {
+	b := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint32(b[:4], i.filenum)
+	binary.BigEndian.PutUint32(b[4:8], i.offset)
+	return b
+}
+
+// freezerTable is an append-only flat file store of a single kind of chain data
+// (e.g. headers). Items are addressed by their position, which for chain data is
+// the block number. The data is split into multiple files of a bounded size and
+// an index file maps every item to its end position.
+type freezerTable struct {
+	items uint64 // Number of items stored in the table (atomic)
+
+	noCompression bool   // Whether snappy compression is disabled for the table
+	maxFileSize   uint32 // Max file size for data files
+	name          string
+	path          string
+
+	index   *os.File            // File descriptor for the index of item end positions
+	files   map[uint32]*os.File // Open data files, indexed by their number
+	head    *os.File            // File descriptor the next item is appended to
+	headID  uint32              // Number of the currently active head file
+	headLen uint32              // Bytes already written to the head file
+
+	readMeter  gometrics.Meter // Meter for measuring the effective amount of data read
+	writeMeter gometrics.Meter // Meter for measuring the effective amount of data written
+
+	logger log.Logger // Logger with database path and table name embedded
+	lock   sync.RWMutex
+}
+
+// newTable opens a freezer table with default settings - 2G files.
+func newTable(path string, name string, readMeter, writeMeter gometrics.Meter, disableSnappy bool) (*freezerTable, error) {
+	return newCustomTable(path, name, readMeter, writeMeter, freezerTableSize, disableSnappy)
+}
+
+// newCustomTable opens a freezer table, creating the data and index files if
+// they don't exist yet and repairing any inconsistency left by a crash.
+func newCustomTable(path string, name string, readMeter, writeMeter gometrics.Meter, maxFilesize uint32, noCompression bool) (*freezerTable, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	idxName := fmt.Sprintf("%s.ridx", name)
+	if !noCompression {
+		idxName = fmt.Sprintf("%s.cidx", name)
+	}
+	index, err := os.OpenFile(filepath.Join(path, idxName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	tab := &freezerTable{
+		index:         index,
+		files:         make(map[uint32]*os.File),
+		readMeter:     readMeter,
+		writeMeter:    writeMeter,
+		name:          name,
+		path:          path,
+		logger:        log.New("database", path, "table", name),
+		noCompression: noCompression,
+		maxFileSize:   maxFilesize,
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the head and the index file and truncates them to be in
+// sync with each other after a potential crash / data loss.
+func (t *freezerTable) repair() error {
+	buffer := make([]byte, indexEntrySize)
+
+	// If we've just created the files, initialize the index with the 0 entry
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() == 0 {
+		if _, err := t.index.Write(buffer); err != nil {
+			return err
+		}
+	}
+	// Ensure the index is a multiple of indexEntrySize bytes
+	if overflow := stat.Size() % indexEntrySize; overflow != 0 {
+		t.index.Truncate(stat.Size() - overflow) // New file can't trigger this path
+	}
+	// Retrieve the file sizes and prepare for truncation
+	if stat, err = t.index.Stat(); err != nil {
+		return err
+	}
+	offsetsSize := stat.Size()
+
+	// Open the head file
+	var lastIndex indexEntry
+	t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
+	lastIndex.unmarshalBinary(buffer)
+
+	if t.head, err = t.openFile(lastIndex.filenum, os.O_RDWR|os.O_CREATE); err != nil {
+		return err
+	}
+	if stat, err = t.head.Stat(); err != nil {
+		return err
+	}
+	contentSize := stat.Size()
+
+	// Keep truncating both files until they come in sync
+	contentExp := int64(lastIndex.offset)
+
+	for contentExp != contentSize {
+		// Truncate the head file to the last offset pointer
+		if contentExp < contentSize {
+			t.logger.Warn("Truncating dangling head", "indexed", contentExp, "stored", contentSize)
+			if err := t.head.Truncate(contentExp); err != nil {
+				return err
+			}
+			contentSize = contentExp
+		}
+		// Truncate the index to point within the head file
+		if contentExp > contentSize {
+			t.logger.Warn("Truncating dangling indexes", "indexed", contentExp, "stored", contentSize)
+			if err := t.index.Truncate(offsetsSize - indexEntrySize); err != nil {
+				return err
+			}
+			offsetsSize -= indexEntrySize
+			t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
+
+			var newLastIndex indexEntry
+			newLastIndex.unmarshalBinary(buffer)
+
+			// We might have slipped back into an earlier head-file here
+			if newLastIndex.filenum != lastIndex.filenum {
+				if t.head, err = t.openFile(newLastIndex.filenum, os.O_RDWR); err != nil {
+					return err
+				}
+				if stat, err = t.head.Stat(); err != nil {
+					return err
+				}
+				contentSize = stat.Size()
+			}
+			lastIndex = newLastIndex
+			contentExp = int64(lastIndex.offset)
+		}
+	}
+	// Ensure all reparation changes have been written to disk
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	if err := t.head.Sync(); err != nil {
+		return err
+	}
+	// Update the item and byte counters and return
+	t.items = uint64(offsetsSize/indexEntrySize - 1)
+	t.headID = lastIndex.filenum
+	t.headLen = uint32(contentSize)
+
+	// Close opened files and preopen all older ones for reads
+	t.releaseFilesAfter(t.headID, false)
+	for i := uint32(0); i < t.headID; i++ {
+		if _, err := t.openFile(i, os.O_RDONLY); err != nil {
+			return err
+		}
+	}
+	t.logger.Debug("Chain freezer table opened", "items", t.items, "size", contentSize)
+	return nil
+}
+
+// truncate discards any recent data above the provided threshold number.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// If our item count is correct, don't do anything
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	// Something's out of sync, truncate the table's offset index
+	t.logger.Warn("Truncating freezer table", "items", t.items, "limit", items)
+	if err := t.index.Truncate(int64(items+1) * indexEntrySize); err != nil {
+		return err
+	}
+	// Calculate the new expected size of the data file and truncate it
+	buffer := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+		return err
+	}
+	var expected indexEntry
+	expected.unmarshalBinary(buffer)
+
+	// We might need to truncate back to older files
+	if expected.filenum != t.headID {
+		newHead, err := t.openFile(expected.filenum, os.O_RDWR)
+		if err != nil {
+			return err
+		}
+		// Release any files after the current head, including the previous head
+		t.releaseFilesAfter(expected.filenum, true)
+		t.head = newHead
+		t.headID = expected.filenum
+	}
+	if err := t.head.Truncate(int64(expected.offset)); err != nil {
+		return err
+	}
+	// All data files truncated, set internal counters and return
+	t.headLen = expected.offset
+	atomic.StoreUint64(&t.items, items)
+
+	return nil
+}
+
+// Close closes all opened files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	if t.index != nil {
+		if err := t.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.index = nil
+	}
+	for _, f := range t.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.files, t.head = nil, nil
+
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// openFile assumes that the write-lock is held by the caller.
+func (t *freezerTable) openFile(num uint32, flag int) (*os.File, error) {
+	if f, ok := t.files[num]; ok {
+		return f, nil
+	}
+	name := fmt.Sprintf("%s.%04d.rdat", t.name, num)
+	if !t.noCompression {
+		name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
+	}
+	f, err := os.OpenFile(filepath.Join(t.path, name), flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t.files[num] = f
+	return f, nil
+}
+
+// releaseFilesAfter closes all open files with a higher number, and optionally
+// also deletes the files.
+func (t *freezerTable) releaseFilesAfter(num uint32, remove bool) {
+	for fnum, f := range t.files {
+		if fnum > num {
+			delete(t.files, fnum)
+			f.Close()
+			if remove {
+				os.Remove(f.Name())
+			}
+		}
+	}
+}
+
+// Append injects a binary blob at the end of the freezer table. The item number
+// is a precautionary parameter to ensure data correctness, but the table will
+// reject already existing data.
+//
+// Note, this method will *not* flush any data to disk so be sure to explicitly
+// fsync before irreversibly deleting data from the database.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Ensure the table is still accessible
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	// Ensure only the next item can be written, nothing else
+	if atomic.LoadUint64(&t.items) != item {
+		return fmt.Errorf("%v: have %d want %d", errOutOrderInsertion, t.items, item)
+	}
+	// Encode the blob and write it into the data file
+	if !t.noCompression {
+		blob = snappy.Encode(nil, blob)
+	}
+	bLen := uint32(len(blob))
+	if t.headLen+bLen < bLen || t.headLen+bLen > t.maxFileSize {
+		// The head file would exceed its limit, open the next one
+		nextID := t.headID + 1
+		newHead, err := t.openFile(nextID, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		// Flush the previous head, it is never written again
+		t.head.Sync()
+		t.head = newHead
+		t.headLen = 0
+		t.headID = nextID
+	}
+	if _, err := t.head.WriteAt(blob, int64(t.headLen)); err != nil {
+		return err
+	}
+	t.headLen += bLen
+
+	// Write the end position of the item into the index
+	idx := indexEntry{filenum: t.headID, offset: t.headLen}
+	if _, err := t.index.WriteAt(idx.marshalBinary(), int64(item+1)*indexEntrySize); err != nil {
+		return err
+	}
+	t.writeMeter.Mark(int64(bLen + indexEntrySize))
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the data offset of an item with the given number and
+// retrieves the raw binary blob from the data file.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	// Ensure the table and the item is accessible
+	if t.index == nil || t.head == nil {
+		return nil, errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= item {
+		return nil, errOutOfBounds
+	}
+	// Retrieve the boundaries of the item from the index
+	buffer := make([]byte, 2*indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(item*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	var start, end indexEntry
+	start.unmarshalBinary(buffer[:indexEntrySize])
+	end.unmarshalBinary(buffer[indexEntrySize:])
+
+	// Items crossing into a new file start at its beginning
+	if start.filenum != end.filenum {
+		start.offset = 0
+	}
+	dataFile, ok := t.files[end.filenum]
+	if !ok {
+		return nil, fmt.Errorf("missing data file %d", end.filenum)
+	}
+	blob := make([]byte, end.offset-start.offset)
+	if _, err := dataFile.ReadAt(blob, int64(start.offset)); err != nil {
+		return nil, err
+	}
+	t.readMeter.Mark(int64(len(blob) + 2*indexEntrySize))
+
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
+
+// has returns an indicator whether the specified number data exists in the
+// freezer table.
+func (t *freezerTable) has(number uint64) bool {
+	return atomic.LoadUint64(&t.items) > number
+}
+
+// size returns the total data size of the freezer table, including the index.
+func (t *freezerTable) size() (common.StorageSize, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return 0, errClosed
+	}
+	stat, err := t.index.Stat()
+	if err != nil {
+		return 0, err
+	}
+	total := common.StorageSize(stat.Size())
+	for _, f := range t.files {
+		stat, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		total += common.StorageSize(stat.Size())
+	}
+	return total, nil
+}
+
+// Sync pushes any pending data from memory out to disk. This is an expensive
+// operation, so use it with care.
+func (t *freezerTable) Sync() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	return t.head.Sync()
+}
+
+// newFreezerMeters creates the read and write meters of a freezer table.
+func newFreezerMeters(namespace, name string) (gometrics.Meter, gometrics.Meter) {
+	return metrics.NewMeter(namespace + name + "/read"), metrics.NewMeter(namespace + name + "/write")
+}
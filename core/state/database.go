@@ -17,10 +17,12 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
 	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/rlp"
 	"github.com/xdn/go-xdn/xdndb"
 	"github.com/xdn/go-xdn/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -50,6 +52,9 @@ type Database interface {
 	ContractCodeSize(addrHash, codeHash common.Hash) (int, error)
 	// CopyTrie returns an independent copy of the given trie.
 	CopyTrie(Trie) Trie
+	// TrieDB retrieves the in-memory trie node database the state is committed
+	// into before being flushed to disk.
+	TrieDB() *trie.NodeDatabase
 }
 
 // Trie is a Dnp Merkle Trie.
@@ -67,11 +72,31 @@ type Trie interface {
 // concurrent use and retains cached trie nodes in memory.
 func NewDatabase(db xdndb.Database) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	return &cachingDB{
+		db:            trie.NewNodeDatabase(db, accountRefs),
+		codeSizeCache: csc,
+	}
+}
+
+// accountRefs extracts the storage root and code hash referenced by an account
+// stored in the state trie, so the trie database keeps them alive with it.
+func accountRefs(leaf []byte) []common.Hash {
+	var account Account
+	if err := rlp.DecodeBytes(leaf, &account); err != nil {
+		return nil
+	}
+	var refs []common.Hash
+	if account.Root != (common.Hash{}) {
+		refs = append(refs, account.Root)
+	}
+	if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+		refs = append(refs, common.BytesToHash(account.CodeHash))
+	}
+	return refs
 }
 
 type cachingDB struct {
-	db            xdndb.Database
+	db            *trie.NodeDatabase
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
@@ -120,6 +145,10 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	}
 }
 
+func (db *cachingDB) TrieDB() *trie.NodeDatabase {
+	return db.db
+}
+
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
 	code, err := db.db.Get(codeHash[:])
 	if err == nil {
@@ -0,0 +1,173 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/xdndb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	// SnapshotRootKey tracks the state root the persisted snapshot data was
+	// last flattened to. It is missing while the snapshot is being generated
+	// or updated, invalidating the whole snapshot on a crash.
+	SnapshotRootKey = []byte("SnapshotRoot")
+
+	// AccountPrefix + account hash -> slim account RLP
+	AccountPrefix = []byte("sa")
+
+	// StoragePrefix + account hash + storage hash -> storage slot RLP
+	StoragePrefix = []byte("ss")
+)
+
+// errNotIterable is returned if the snapshot data can't be walked over because
+// the database isn't backed by leveldb or an in-memory store.
+var errNotIterable = errors.New("database doesn't support iteration")
+
+// accountKey = AccountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, AccountPrefix...), hash[:]...)
+}
+
+// storageKey = StoragePrefix + account hash + storage hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	return append(storagePrefix(accountHash), storageHash[:]...)
+}
+
+// storagePrefix = StoragePrefix + account hash
+func storagePrefix(accountHash common.Hash) []byte {
+	return append(append([]byte{}, StoragePrefix...), accountHash[:]...)
+}
+
+// ReadSnapshotRoot retrieves the root of the state the persisted snapshot
+// belongs to, or the empty hash if there is no complete snapshot on disk.
+func ReadSnapshotRoot(db xdndb.Database) common.Hash {
+	data, _ := db.Get(SnapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// writeSnapshotRoot stores the root of the state the persisted snapshot belongs to.
+func writeSnapshotRoot(db xdndb.Putter, root common.Hash) {
+	if err := db.Put(SnapshotRootKey, root[:]); err != nil {
+		log.Crit("Failed to store snapshot root", "err", err)
+	}
+}
+
+// deleteSnapshotRoot removes the snapshot root marker, invalidating the data on disk.
+func deleteSnapshotRoot(db xdndb.Database) {
+	if err := db.Delete(SnapshotRootKey); err != nil {
+		log.Crit("Failed to remove snapshot root", "err", err)
+	}
+}
+
+// iterateKeys calls fn, in key order, for every entry in the database whose
+// key starts with prefix and has exactly keylen bytes. The key and value slices
+// are only valid until fn returns.
+func iterateKeys(db xdndb.Database, prefix []byte, keylen int, fn func(key, value []byte) error) error {
+	switch db := db.(type) {
+	case *xdndb.LDBDatabase:
+		it := db.LDB().NewIterator(util.BytesPrefix(prefix), nil)
+		defer it.Release()
+
+		for it.Next() {
+			if len(it.Key()) != keylen {
+				continue
+			}
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		return it.Error()
+
+	case *xdndb.MemDatabase:
+		var keys [][]byte
+		for _, key := range db.Keys() {
+			if len(key) == keylen && bytes.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		for _, key := range keys {
+			value, err := db.Get(key)
+			if err != nil {
+				continue
+			}
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return errNotIterable
+	}
+}
+
+// wipeStorage adds the deletion of every storage slot of an account to batch,
+// flushing the batch whenever it grows too large.
+func wipeStorage(db xdndb.Database, batch xdndb.Batch, accountHash common.Hash) error {
+	return iterateKeys(db, storagePrefix(accountHash), len(StoragePrefix)+2*common.HashLength, func(key, value []byte) error {
+		batch.Delete(common.CopyBytes(key))
+		if batch.ValueSize() > xdndb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		return nil
+	})
+}
+
+// wipeSnapshot deletes all the account and storage snapshot data and the
+// snapshot root marker from the database.
+func wipeSnapshot(db xdndb.Database) error {
+	deleteSnapshotRoot(db)
+
+	batch := db.NewBatch()
+	for _, table := range []struct {
+		prefix []byte
+		keylen int
+	}{
+		{AccountPrefix, len(AccountPrefix) + common.HashLength},
+		{StoragePrefix, len(StoragePrefix) + 2*common.HashLength},
+	} {
+		err := iterateKeys(db, table.prefix, table.keylen, func(key, value []byte) error {
+			batch.Delete(common.CopyBytes(key))
+			if batch.ValueSize() > xdndb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/trie"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb xdndb.Database     // Key-value store containing the base snapshot
+	triedb *trie.NodeDatabase // Trie node database to reconstruct the snapshot from
+	root   common.Hash        // Root hash of the base snapshot
+	stale  bool               // Signals that the layer became stale (state progressed)
+
+	genMarker  []byte                    // Marker for the state that's indexed during initial layer generation
+	genPending chan struct{}             // Notification channel when generation is done
+	genAbort   chan chan *generatorStats // Notification channel to abort generating the snapshot in this layer
+
+	lock sync.RWMutex
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whether this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// covered reports whether the generator already indexed the given key. The
+// caller must hold the read lock.
+func (dl *diskLayer) covered(key []byte) bool {
+	return dl.genMarker == nil || bytes.Compare(key, dl.genMarker) <= 0
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot, in the consensus format.
+func (dl *diskLayer) Account(hash common.Hash) (*Account, error) {
+	data, err := dl.AccountRLP(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 { // can be both nil and []byte{}
+		return nil, nil
+	}
+	account, err := FullAccount(data)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot slim data format.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested hash has already been
+	// covered by the generator.
+	if !dl.covered(hash[:]) {
+		return nil, ErrNotCoveredYet
+	}
+	blob, _ := dl.diskdb.Get(accountKey(hash))
+	return blob, nil
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// The generator indexes entire accounts at once, so the storage is available
+	// as soon as the owning account is.
+	if !dl.covered(accountHash[:]) {
+		return nil, ErrNotCoveredYet
+	}
+	blob, _ := dl.diskdb.Get(storageKey(accountHash, storageHash))
+	return blob, nil
+}
+
+// abortGeneration stops the generator running on top of the layer, if any, and
+// returns its statistics. The progress made is retained in the marker.
+func (dl *diskLayer) abortGeneration() *generatorStats {
+	if dl.genAbort == nil {
+		return nil
+	}
+	abort := make(chan *generatorStats)
+	dl.genAbort <- abort
+	dl.genAbort = nil
+	return <-abort
+}
+
+// diffToDisk merges a bottom-most diff into the persistent disk layer underneath
+// it, returning a new disk layer. The old disk layer is marked stale. If the
+// snapshot is still being generated, only the already indexed part of the
+// diff is written and the generator is restarted on top of the new root.
+func diffToDisk(bottom *diffLayer) *diskLayer {
+	var (
+		base  = bottom.Parent().(*diskLayer)
+		batch = base.diskdb.NewBatch()
+	)
+	// If the disk layer is running a snapshot generator, abort it
+	stats := base.abortGeneration()
+
+	// Start by temporarily deleting the current snapshot root marker. This
+	// ensures that in the case of a crash, the entire snapshot is invalidated.
+	deleteSnapshotRoot(base.diskdb)
+
+	// Mark the original base as stale as we're going to create a new wrapper
+	base.lock.Lock()
+	if base.stale {
+		panic("parent disk layer is stale") // we've committed into the same base from two children, boo
+	}
+	base.stale = true
+	base.lock.Unlock()
+
+	// flush writes out the batch if it grew too large
+	flush := func() {
+		if batch.ValueSize() > xdndb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write snapshot data", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	// Destroy all the destructed accounts along with their storage
+	for hash := range bottom.destructSet {
+		if !base.covered(hash[:]) {
+			continue
+		}
+		batch.Delete(accountKey(hash))
+		if err := wipeStorage(base.diskdb, batch, hash); err != nil {
+			log.Crit("Failed to wipe destructed storage", "account", hash, "err", err)
+		}
+		flush()
+	}
+	// Push all updated accounts into the database
+	for hash, data := range bottom.accountData {
+		if !base.covered(hash[:]) {
+			continue
+		}
+		if len(data) > 0 {
+			batch.Put(accountKey(hash), data)
+		} else {
+			batch.Delete(accountKey(hash))
+		}
+		flush()
+	}
+	// Push all the storage slots into the database
+	for accountHash, storage := range bottom.storageData {
+		if !base.covered(accountHash[:]) {
+			continue
+		}
+		for storageHash, data := range storage {
+			if len(data) > 0 {
+				batch.Put(storageKey(accountHash, storageHash), data)
+			} else {
+				batch.Delete(storageKey(accountHash, storageHash))
+			}
+		}
+		flush()
+	}
+	// Update the snapshot root marker and write any remainder data, unless the
+	// snapshot is still incomplete
+	if base.genMarker == nil {
+		writeSnapshotRoot(batch, bottom.root)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot data", "err", err)
+	}
+	res := &diskLayer{
+		diskdb:     base.diskdb,
+		triedb:     base.triedb,
+		root:       bottom.root,
+		genMarker:  base.genMarker,
+		genPending: base.genPending,
+	}
+	// If snapshot generation hasn't finished yet, port over all the stats and
+	// continue where the previous round left off
+	if base.genMarker != nil {
+		if stats == nil {
+			stats = &generatorStats{origin: time.Now(), logged: time.Now()}
+		}
+		res.genAbort = make(chan chan *generatorStats)
+		go res.generate(stats)
+	}
+	return res
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rlp"
+	"github.com/xdn/go-xdn/trie"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+// generatorStats is a collection of statistics gathered by the snapshot generator
+// for logging purposes.
+type generatorStats struct {
+	origin   time.Time          // Time when the generation started
+	accounts uint64             // Number of accounts indexed
+	slots    uint64             // Number of storage slots indexed
+	storage  common.StorageSize // Account and storage slot size
+	logged   time.Time          // Time of the last progress report
+}
+
+// log prints the generation progress of the snapshot.
+func (gs *generatorStats) log(msg string, root common.Hash, marker []byte) {
+	ctx := []interface{}{
+		"root", root, "accounts", gs.accounts, "slots", gs.slots,
+		"storage", gs.storage, "elapsed", common.PrettyDuration(time.Since(gs.origin)),
+	}
+	if len(marker) > 0 {
+		ctx = append(ctx, "at", common.BytesToHash(marker))
+	}
+	log.Info(msg, ctx...)
+	gs.logged = time.Now()
+}
+
+// generateSnapshot regenerates a brand new snapshot based on an existing state
+// database and head block. Any previous snapshot data is wiped and a background
+// generator is started, the returned disk layer serving the indexed part of the
+// state in the mean time.
+func generateSnapshot(diskdb xdndb.Database, triedb *trie.NodeDatabase, root common.Hash) (*diskLayer, error) {
+	log.Info("Wiping previous state snapshot")
+	if err := wipeSnapshot(diskdb); err != nil {
+		return nil, err
+	}
+	base := &diskLayer{
+		diskdb:     diskdb,
+		triedb:     triedb,
+		root:       root,
+		genMarker:  []byte{}, // Initialized but empty!
+		genPending: make(chan struct{}),
+		genAbort:   make(chan chan *generatorStats),
+	}
+	go base.generate(&generatorStats{origin: time.Now(), logged: time.Now()})
+	return base, nil
+}
+
+// nextKey returns the next 32 byte key after marker in the trie key space, or
+// nil if there is none. An empty marker means nothing was iterated yet.
+func nextKey(marker []byte) []byte {
+	if len(marker) == 0 {
+		return nil
+	}
+	next := common.CopyBytes(marker)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
+
+// generate is a background thread that iterates over the state and storage tries,
+// constructing the state snapshot. All the arguments are purely for statistics
+// gathering and logging, since the method surfs the blocks as they arrive, often
+// being restarted.
+func (dl *diskLayer) generate(stats *generatorStats) {
+	// If the generator reached the end of the key space in a previous round,
+	// there's nothing left to index
+	start := nextKey(dl.genMarker)
+	if len(dl.genMarker) > 0 && start == nil {
+		dl.finish(stats)
+		return
+	}
+	var (
+		batch = dl.diskdb.NewBatch()
+		last  []byte // Last account fully added to the batch
+	)
+	// flush writes out the pending batch and advances the generator marker past
+	// every account in it, making them available to readers
+	flush := func() {
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write snapshot data", "err", err)
+		}
+		batch.Reset()
+
+		if last != nil {
+			dl.lock.Lock()
+			dl.genMarker = last
+			dl.lock.Unlock()
+		}
+	}
+	// pause persists the progress made and waits for the layer to be flattened
+	// into, the generation continuing on top of the new root. This happens if the
+	// tries of the current root are missing or got garbage collected meanwhile.
+	pause := func(partial *common.Hash, err error) {
+		// Drop the half indexed account, it will be redone in the next round
+		if partial != nil {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write snapshot data", "err", err)
+			}
+			batch.Reset()
+
+			batch.Delete(accountKey(*partial))
+			if err := wipeStorage(dl.diskdb, batch, *partial); err != nil {
+				log.Crit("Failed to wipe partial snapshot storage", "err", err)
+			}
+		}
+		flush()
+		stats.log("Trie missing, state snapshotting paused", dl.root, dl.genMarker)
+		log.Debug("Snapshot generation interrupted", "err", err)
+
+		abort := <-dl.genAbort
+		abort <- stats
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		pause(nil, err)
+		return
+	}
+	stats.log("Resuming state snapshot generation", dl.root, dl.genMarker)
+
+	it := trie.NewIterator(accTrie.NodeIterator(start))
+	for it.Next() {
+		// If the generator was aborted, persist the progress and bail out
+		select {
+		case abort := <-dl.genAbort:
+			flush()
+			abort <- stats
+			return
+		default:
+		}
+		// Retrieve the current account and flatten it into the internal format
+		var acc Account
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			log.Crit("Invalid account encountered during snapshot creation", "err", err)
+		}
+		var (
+			accountHash = common.BytesToHash(it.Key)
+			data        = SlimAccountRLP(acc.Nonce, acc.Balance, common.BytesToHash(acc.Root), acc.CodeHash)
+		)
+		batch.Put(accountKey(accountHash), data)
+		stats.storage += common.StorageSize(len(AccountPrefix) + common.HashLength + len(data))
+		stats.accounts++
+
+		// Index the entire storage of the account too
+		if storageRoot := common.BytesToHash(acc.Root); storageRoot != emptyRoot {
+			storeTrie, err := trie.NewSecure(storageRoot, dl.triedb, 0)
+			if err != nil {
+				pause(&accountHash, err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				batch.Put(storageKey(accountHash, common.BytesToHash(storeIt.Key)), storeIt.Value)
+				stats.storage += common.StorageSize(len(StoragePrefix) + 2*common.HashLength + len(storeIt.Value))
+				stats.slots++
+
+				// Large contracts are written out in pieces, but the account only
+				// becomes visible once all of its storage is indexed
+				if batch.ValueSize() > xdndb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						log.Crit("Failed to write snapshot data", "err", err)
+					}
+					batch.Reset()
+				}
+			}
+			if storeIt.Err != nil {
+				pause(&accountHash, storeIt.Err)
+				return
+			}
+		}
+		last = accountHash[:]
+		if batch.ValueSize() > xdndb.IdealBatchSize {
+			flush()
+		}
+		if time.Since(stats.logged) > 8*time.Second {
+			stats.log("Generating state snapshot", dl.root, last)
+		}
+	}
+	if it.Err != nil {
+		pause(nil, it.Err)
+		return
+	}
+	flush()
+	dl.finish(stats)
+}
+
+// finish marks the snapshot generation complete, persists the snapshot root
+// and waits for the layer to be flattened into.
+func (dl *diskLayer) finish(stats *generatorStats) {
+	writeSnapshotRoot(dl.diskdb, dl.root)
+	stats.log("Generated state snapshot", dl.root, nil)
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	// Someone will be looking for us, wait it out
+	abort := <-dl.genAbort
+	abort <- nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat, layered dump of the state for fast reads.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/trie"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// Account directly retrieves the account associated with a particular hash in
+	// the snapshot, in the consensus format.
+	Account(hash common.Hash) (*Account, error)
+
+	// AccountRLP directly retrieves the account RLP associated with a particular
+	// hash in the snapshot slim data format.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the storage data associated with a particular hash,
+	// within a particular account.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Stale return whether this layer has become stale (was flattened across) or
+	// if it's still live.
+	Stale() bool
+}
+
+// Tree is an Dnp state snapshot tree. It consists of one persistent base
+// layer backed by a key-value store, on top of which arbitrarily many in-memory
+// diff layers are topped. The memory diffs can form a tree with branching, but
+// the disk layer is singleton and common to all. If a reorg goes deeper than the
+// disk layer, everything needs to be deleted.
+//
+// The goal of a state snapshot is to allow direct access to account and storage
+// data to avoid expensive multi-level trie lookups.
+type Tree struct {
+	diskdb xdndb.Database           // Persistent database to store the snapshot
+	triedb *trie.NodeDatabase       // In-memory cache to access the trie through
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store. If the snapshot is missing or belongs to a different state than the
+// given root, the entire snapshot is wiped and regenerated in the background.
+//
+// The key-value store needs to support iteration, i.e. it must be backed by
+// leveldb or be an in-memory database.
+func New(diskdb xdndb.Database, triedb *trie.NodeDatabase, root common.Hash) (*Tree, error) {
+	switch diskdb.(type) {
+	case *xdndb.LDBDatabase, *xdndb.MemDatabase:
+	default:
+		return nil, errNotIterable
+	}
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	if ReadSnapshotRoot(diskdb) == root {
+		log.Info("Loaded state snapshot", "root", root)
+		snap.layers[root] = &diskLayer{
+			diskdb: diskdb,
+			triedb: triedb,
+			root:   root,
+		}
+		return snap, nil
+	}
+	base, err := generateSnapshot(diskdb, triedb, root)
+	if err != nil {
+		return nil, err
+	}
+	snap.layers[root] = base
+	return snap, nil
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if snap, ok := t.layers[blockRoot]; ok {
+		return snap
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This happens
+	// whenever a block leaves the state root unchanged, e.g. an empty block on a
+	// network without block rewards.
+	//
+	// Although we could silently ignore this internally, it should be the caller's
+	// responsibility to avoid even attempting to insert such a snapshot.
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	// Generate a new snapshot on top of the parent
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[blockRoot] = newDiffLayer(parent, blockRoot, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer. Layers branching off the
+// flattened ones are discarded.
+//
+// Capping with zero layers flattens the whole chain up to the given root into
+// the disk layer and discards every other layer. Capping a disk layer is a
+// noop.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	// Retrieve the head snapshot to cap from
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Find the lowest diff layer to keep and the topmost one to flatten
+	var (
+		keep   *diffLayer
+		bottom = diff
+	)
+	for i := 0; i < layers; i++ {
+		parent, ok := bottom.Parent().(*diffLayer)
+		if !ok {
+			return nil // Not enough layers to flatten anything
+		}
+		keep, bottom = bottom, parent
+	}
+	// Collect the layers to flatten and push them into the disk one by one,
+	// starting from the oldest
+	var flatten []*diffLayer
+	for layer := bottom; layer != nil; {
+		flatten = append(flatten, layer)
+		layer, _ = layer.Parent().(*diffLayer)
+	}
+	var base *diskLayer
+	for i := len(flatten) - 1; i >= 0; i-- {
+		if base != nil {
+			flatten[i].setParent(base)
+		}
+		flatten[i].markStale()
+		base = diffToDisk(flatten[i])
+	}
+	// If nothing is kept, the new disk layer is the only remaining one
+	if keep == nil {
+		for _, layer := range t.layers {
+			if diff, ok := layer.(*diffLayer); ok {
+				diff.markStale()
+			}
+		}
+		t.layers = map[common.Hash]snapshot{base.root: base}
+		return nil
+	}
+	keep.setParent(base)
+
+	// Remove any layer that is stale or links into a stale layer, reparenting
+	// the children of the flattened head onto the new disk layer
+	children := make(map[common.Hash][]*diffLayer)
+	for _, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok && !diff.Stale() {
+			parent := diff.Parent()
+			if parent.Stale() && parent.Root() != base.root {
+				continue
+			}
+			children[parent.Root()] = append(children[parent.Root()], diff)
+		}
+	}
+	remaining := map[common.Hash]snapshot{base.root: base}
+	queue := []common.Hash{base.root}
+	for len(queue) > 0 {
+		root := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[root] {
+			if root == base.root {
+				child.setParent(base)
+			}
+			remaining[child.root] = child
+			queue = append(queue, child.root)
+		}
+	}
+	for root, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok && remaining[root] != layer {
+			diff.markStale()
+		}
+	}
+	t.layers = remaining
+	return nil
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discard all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Abort the generator of the disk layer and invalidate all the layers
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			layer.abortGeneration()
+			layer.lock.Lock()
+			layer.stale = true
+			layer.lock.Unlock()
+
+		case *diffLayer:
+			layer.markStale()
+		}
+	}
+	base, err := generateSnapshot(t.diskdb, t.triedb, root)
+	if err != nil {
+		t.layers = make(map[common.Hash]snapshot)
+		return err
+	}
+	t.layers = map[common.Hash]snapshot{root: base}
+	return nil
+}
+
+// Release stops the background snapshot generation, if one is running, so that
+// the database can be closed. The tree must not be updated afterwards.
+func (t *Tree) Release() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.abortGeneration()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rlp"
+	"github.com/xdn/go-xdn/trie"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+// VerifyState recomputes the state root from the flat account and storage data
+// of the persisted snapshot, checking the storage root of every account along
+// the way, and returns an error if the snapshot doesn't match the given state
+// root. The snapshot needs to be fully generated and flattened into the disk,
+// so the verification is meant to be run on the database of a stopped node.
+//
+// The tries are rebuilt in memory, so verifying a large state needs a lot of it.
+func VerifyState(diskdb xdndb.Database, root common.Hash) error {
+	if have := ReadSnapshotRoot(diskdb); have != root {
+		if have == (common.Hash{}) {
+			return errors.New("no complete snapshot in the database")
+		}
+		return fmt.Errorf("snapshot belongs to state %x, not %x", have, root)
+	}
+	var (
+		accTrie, _ = trie.New(common.Hash{}, nil)
+		accounts   uint64
+		slots      uint64
+		start      = time.Now()
+		logged     = time.Now()
+	)
+	err := iterateKeys(diskdb, AccountPrefix, len(AccountPrefix)+common.HashLength, func(key, value []byte) error {
+		accountHash := common.BytesToHash(key[len(AccountPrefix):])
+
+		account, err := FullAccount(value)
+		if err != nil {
+			return fmt.Errorf("invalid account %x: %v", accountHash, err)
+		}
+		// Recompute the storage root of the account from its flat slots
+		storeTrie, _ := trie.New(common.Hash{}, nil)
+		err = iterateKeys(diskdb, storagePrefix(accountHash), len(StoragePrefix)+2*common.HashLength, func(key, value []byte) error {
+			slots++
+			return storeTrie.TryUpdate(common.CopyBytes(key[len(StoragePrefix)+common.HashLength:]), common.CopyBytes(value))
+		})
+		if err != nil {
+			return err
+		}
+		if have, want := storeTrie.Hash(), common.BytesToHash(account.Root); have != want {
+			return fmt.Errorf("storage root mismatch for account %x: have %x, want %x", accountHash, have, want)
+		}
+		// Insert the account in consensus format into the account trie
+		data, err := rlp.EncodeToBytes(account)
+		if err != nil {
+			return err
+		}
+		accounts++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return accTrie.TryUpdate(accountHash[:], data)
+	})
+	if err != nil {
+		return err
+	}
+	if have := accTrie.Hash(); have != root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", have, root)
+	}
+	log.Info("Verified state snapshot", "root", root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/core/state"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/crypto"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rlp"
+	"github.com/xdn/go-xdn/trie"
+)
+
+const (
+	// stateDumpVersion is the version number of the state dump format.
+	stateDumpVersion = 1
+
+	// stateDumpChunkSize is the approximate number of bytes of state entries
+	// bundled into a single chunk of a state dump.
+	stateDumpChunkSize = 4 * 1024 * 1024
+
+	// stateDumpHeaderBatch is the number of headers imported from a state dump
+	// in a single header chain insertion.
+	stateDumpHeaderBatch = 2048
+)
+
+// errStateDumpVersion is returned if a state dump was created with an unknown
+// version of the format.
+var errStateDumpVersion = errors.New("unsupported state dump version")
+
+// stateDumpHeader is the first item of a state dump, identifying the chain and
+// the block whose state is contained.
+//
+// The header is followed by the headers of the blocks 1..Number, the body and
+// the receipts of the block and finally by a sequence of stateDumpChunks, the
+// last one of which is empty.
+type stateDumpHeader struct {
+	Version uint64
+	Genesis common.Hash
+	Number  uint64
+	Hash    common.Hash
+	Root    common.Hash
+}
+
+// stateDumpChunk is a batch of state trie nodes and contract codes in the order
+// they were iterated. Entries are identified by their hashes on import.
+type stateDumpChunk struct {
+	Index   uint64
+	Entries [][]byte
+}
+
+// ExportState writes the header chain up to the canonical block with the given
+// number, the block itself along with its receipts and a chunked dump of the
+// entire state of the block to the given writer.
+func (bc *BlockChain) ExportState(w io.Writer, number uint64) error {
+	block := bc.GetBlockByNumber(number)
+	if block == nil {
+		return fmt.Errorf("export failed on #%d: not found", number)
+	}
+	receipts := GetBlockReceipts(bc.chainDb, block.Hash(), number)
+	if receipts == nil && len(block.Transactions()) > 0 {
+		return fmt.Errorf("export failed on #%d: receipts not found", number)
+	}
+	statedb, err := state.New(block.Root(), bc.stateCache)
+	if err != nil {
+		return fmt.Errorf("export failed on #%d: %v", number, err)
+	}
+	log.Info("Exporting state", "number", number, "hash", block.Hash(), "root", block.Root())
+
+	// Write the identifier of the dump, followed by the header chain
+	if err := rlp.Encode(w, &stateDumpHeader{
+		Version: stateDumpVersion,
+		Genesis: bc.genesisBlock.Hash(),
+		Number:  number,
+		Hash:    block.Hash(),
+		Root:    block.Root(),
+	}); err != nil {
+		return err
+	}
+	for n := uint64(1); n <= number; n++ {
+		header := bc.GetHeaderByNumber(n)
+		if header == nil {
+			return fmt.Errorf("export failed on #%d: header not found", n)
+		}
+		if err := rlp.Encode(w, header); err != nil {
+			return err
+		}
+	}
+	if err := rlp.Encode(w, block.Body()); err != nil {
+		return err
+	}
+	if err := rlp.Encode(w, receipts); err != nil {
+		return err
+	}
+	// Iterate over the entire state and dump it chunk by chunk
+	var (
+		triedb = bc.stateCache.TrieDB()
+		it     = state.NewNodeIterator(statedb)
+		chunk  = new(stateDumpChunk)
+		size   int
+
+		entries int
+		bytes   common.StorageSize
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	for it.Next() {
+		// Embedded nodes are contained within their parents
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		blob, err := triedb.Get(it.Hash[:])
+		if err != nil {
+			return fmt.Errorf("state entry %x missing: %v", it.Hash, err)
+		}
+		chunk.Entries = append(chunk.Entries, blob)
+		size += len(blob)
+
+		entries++
+		bytes += common.StorageSize(len(blob))
+
+		if size >= stateDumpChunkSize {
+			if err := rlp.Encode(w, chunk); err != nil {
+				return err
+			}
+			chunk, size = &stateDumpChunk{Index: chunk.Index + 1}, 0
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "entries", entries, "size", bytes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	// Flush the last chunk and terminate the dump with an empty one
+	if len(chunk.Entries) > 0 {
+		if err := rlp.Encode(w, chunk); err != nil {
+			return err
+		}
+		chunk = &stateDumpChunk{Index: chunk.Index + 1}
+	}
+	if err := rlp.Encode(w, chunk); err != nil {
+		return err
+	}
+	log.Info("Exported state", "number", number, "entries", entries, "size", bytes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// ImportState loads a state dump created by ExportState into an empty chain,
+// and makes the contained block the new head. Every item of the dump is checked
+// against the header chain: headers are verified by the consensus engine, the
+// body and receipts against the head header, and the state entries are only
+// accepted if they are reachable from the state root of the head header.
+func (bc *BlockChain) ImportState(r io.Reader) (*types.Block, error) {
+	if head := bc.CurrentBlock(); head.NumberU64() > 0 {
+		return nil, fmt.Errorf("chain not empty, head block #%d", head.NumberU64())
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var dump stateDumpHeader
+	if err := stream.Decode(&dump); err != nil {
+		return nil, fmt.Errorf("invalid state dump: %v", err)
+	}
+	if dump.Version != stateDumpVersion {
+		return nil, fmt.Errorf("%v: %d", errStateDumpVersion, dump.Version)
+	}
+	if genesis := bc.genesisBlock.Hash(); dump.Genesis != genesis {
+		return nil, fmt.Errorf("genesis mismatch: have %x, want %x", dump.Genesis, genesis)
+	}
+	log.Info("Importing state", "number", dump.Number, "hash", dump.Hash, "root", dump.Root)
+
+	// Import and verify the header chain leading up to the block
+	head := bc.genesisBlock.Header()
+	for head.Number.Uint64() < dump.Number {
+		headers := make([]*types.Header, 0, stateDumpHeaderBatch)
+		for len(headers) < stateDumpHeaderBatch && head.Number.Uint64()+uint64(len(headers)) < dump.Number {
+			header := new(types.Header)
+			if err := stream.Decode(header); err != nil {
+				return nil, fmt.Errorf("invalid header #%d: %v", head.Number.Uint64()+uint64(len(headers))+1, err)
+			}
+			headers = append(headers, header)
+		}
+		// The dump is untrusted, verify the seal of every single header
+		if n, err := bc.InsertHeaderChain(headers, 1); err != nil {
+			return nil, fmt.Errorf("invalid header #%d: %v", headers[n].Number, err)
+		}
+		head = headers[len(headers)-1]
+	}
+	if head.Hash() != dump.Hash {
+		return nil, fmt.Errorf("head hash mismatch: have %x, want %x", head.Hash(), dump.Hash)
+	}
+	if head.Root != dump.Root {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", dump.Root, head.Root)
+	}
+	// Retrieve the block body and receipts, and verify them against the header
+	body := new(types.Body)
+	if err := stream.Decode(body); err != nil {
+		return nil, fmt.Errorf("invalid block body: %v", err)
+	}
+	if hash := types.DeriveSha(types.Transactions(body.Transactions)); hash != head.TxHash {
+		return nil, fmt.Errorf("transaction root mismatch: have %x, want %x", hash, head.TxHash)
+	}
+	if hash := types.CalcUncleHash(body.Uncles); hash != head.UncleHash {
+		return nil, fmt.Errorf("uncle root mismatch: have %x, want %x", hash, head.UncleHash)
+	}
+	var receipts types.Receipts
+	if err := stream.Decode(&receipts); err != nil {
+		return nil, fmt.Errorf("invalid block receipts: %v", err)
+	}
+	if hash := types.DeriveSha(receipts); hash != head.ReceiptHash {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", hash, head.ReceiptHash)
+	}
+	block := types.NewBlockWithHeader(head).WithBody(body.Transactions, body.Uncles)
+
+	// Feed the state entries into a state sync rooted at the verified root. Any
+	// entry not requested by the scheduler is either already known locally, or
+	// unrelated to the state, and is discarded.
+	var (
+		sched = state.NewStateSync(head.Root, bc.chainDb)
+
+		entries int
+		bytes   common.StorageSize
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	for index := uint64(0); ; index++ {
+		var chunk stateDumpChunk
+		if err := stream.Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("invalid state chunk %d: %v", index, err)
+		}
+		if chunk.Index != index {
+			return nil, fmt.Errorf("state chunk index mismatch: have %d, want %d", chunk.Index, index)
+		}
+		if len(chunk.Entries) == 0 {
+			break
+		}
+		for _, blob := range chunk.Entries {
+			result := trie.SyncResult{Hash: crypto.Keccak256Hash(blob), Data: blob}
+			if _, _, err := sched.Process([]trie.SyncResult{result}); err != nil && err != trie.ErrNotRequested && err != trie.ErrAlreadyProcessed {
+				return nil, fmt.Errorf("invalid state entry %x: %v", result.Hash, err)
+			}
+			entries++
+			bytes += common.StorageSize(len(blob))
+		}
+		batch := bc.chainDb.NewBatch()
+		if _, err := sched.Commit(batch); err != nil {
+			return nil, err
+		}
+		if err := batch.Write(); err != nil {
+			return nil, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state", "entries", entries, "size", bytes, "pending", sched.Pending(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if pending := sched.Pending(); pending > 0 {
+		return nil, fmt.Errorf("state dump incomplete, %d entries missing", pending)
+	}
+	// The state is complete, commit the block as the new head
+	if _, err := bc.InsertReceiptChain(types.Blocks{block}, []types.Receipts{receipts}); err != nil {
+		return nil, err
+	}
+	if err := bc.FastSyncCommitHead(block.Hash()); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadBlockHash(bc.chainDb, block.Hash()); err != nil {
+		log.Crit("Failed to update head block hash", "err", err)
+	}
+	// None of the ancestor bodies and receipts are available, mark them pruned
+	if number := block.NumberU64(); number > 0 {
+		if err := WriteBodyTail(bc.chainDb, number); err != nil {
+			return nil, err
+		}
+		if err := WriteTxLookupTail(bc.chainDb, number); err != nil {
+			return nil, err
+		}
+		atomic.StoreUint64(&bc.bodyTail, number)
+		atomic.StoreUint64(&bc.lookupTail, number)
+	}
+	log.Info("Imported state", "number", block.Number(), "hash", block.Hash(), "entries", entries, "size", bytes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return block, nil
+}
@@ -26,6 +26,8 @@ import (
 	"github.com/xdn/go-xdn/crypto"
 	"github.com/xdn/go-xdn/crypto/bn256"
 	"github.com/xdn/go-xdn/params"
+	"github.com/xdn/go-xdn/poc"
+	"github.com/xdn/go-xdn/shabal"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -59,6 +61,21 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+// PrecompiledContractsPoc contains the default set of pre-compiled Dnp
+// contracts used after the PoC fork, exposing plot verification to contracts.
+var PrecompiledContractsPoc = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):    &ecrecover{},
+	common.BytesToAddress([]byte{2}):    &sha256hash{},
+	common.BytesToAddress([]byte{3}):    &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):    &dataCopy{},
+	common.BytesToAddress([]byte{5}):    &bigModExp{},
+	common.BytesToAddress([]byte{6}):    &bn256Add{},
+	common.BytesToAddress([]byte{7}):    &bn256ScalarMul{},
+	common.BytesToAddress([]byte{8}):    &bn256Pairing{},
+	common.BytesToAddress([]byte{1, 0}): &shabal256hash{},
+	common.BytesToAddress([]byte{1, 1}): &pocDeadline{},
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -377,3 +394,55 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+// Shabal-256 implemented as a native contract.
+type shabal256hash struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This method does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *shabal256hash) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*params.Shabal256PerWordGas + params.Shabal256BaseGas
+}
+func (c *shabal256hash) Run(input []byte) ([]byte, error) {
+	hasher := shabal.NewSha_bal256()
+	hasher.Write(input)
+	return hasher.Sum(nil), nil
+}
+
+var (
+	// errPocInvalidInput is returned if the PoC deadline input fields overflow.
+	errPocInvalidInput = errors.New("invalid PoC deadline input")
+)
+
+// pocDeadline implements the PoC deadline verification as a native contract.
+type pocDeadline struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *pocDeadline) RequiredGas(input []byte) uint64 {
+	return params.PocDeadlineGas
+}
+
+// Run regenerates a plot nonce and returns the deadline it yields. The input is
+// (genSig, height, plotID, nonce, baseTarget), each 32 bytes. If baseTarget is
+// zero, the raw target of the nonce is returned instead of the deadline.
+func (c *pocDeadline) Run(input []byte) ([]byte, error) {
+	input = common.RightPadBytes(input, 160)
+
+	var (
+		genSig     = common.BytesToHash(input[:32])
+		height     = new(big.Int).SetBytes(input[32:64])
+		plotID     = new(big.Int).SetBytes(input[64:96])
+		nonce      = new(big.Int).SetBytes(input[96:128])
+		baseTarget = new(big.Int).SetBytes(input[128:160])
+	)
+	if height.BitLen() > 64 || plotID.BitLen() > 64 || nonce.BitLen() > 64 {
+		return nil, errPocInvalidInput
+	}
+	target := poc.CalcNonceTarget(genSig, height.Uint64(), plotID.Uint64(), nonce.Uint64())
+	if baseTarget.Sign() == 0 {
+		return math.PaddedBigBytes(target, 32), nil
+	}
+	return math.PaddedBigBytes(poc.CalcDeadLine(target, baseTarget), 32), nil
+}
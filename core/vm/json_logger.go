@@ -14,30 +14,43 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
 
-package main
+package vm
 
 import (
 	"encoding/json"
 	"io"
+	"math/big"
 	"time"
 
 	"github.com/xdn/go-xdn/common"
 	"github.com/xdn/go-xdn/common/math"
-	"github.com/xdn/go-xdn/core/vm"
 )
 
+// JSONLogger is a Tracer emitting every executed step and the final result of
+// the execution as one JSON object per line, for diffing against other clients.
 type JSONLogger struct {
 	encoder *json.Encoder
-	cfg     *vm.LogConfig
+	cfg     *LogConfig
 }
 
-func NewJSONLogger(cfg *vm.LogConfig, writer io.Writer) *JSONLogger {
+// NewJSONLogger creates a new EVM tracer that prints execution steps as JSON
+// objects into the provided stream.
+func NewJSONLogger(cfg *LogConfig, writer io.Writer) *JSONLogger {
+	if cfg == nil {
+		cfg = &LogConfig{}
+	}
 	return &JSONLogger{json.NewEncoder(writer), cfg}
 }
 
+// CaptureStart implements the Tracer interface, the JSON log only contains
+// the executed steps and the final result.
+func (l *JSONLogger) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
 // CaptureState outputs state information on the logger.
-func (l *JSONLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
-	log := vm.StructLog{
+func (l *JSONLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	log := StructLog{
 		Pc:         pc,
 		Op:         op,
 		Gas:        gas,
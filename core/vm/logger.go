@@ -79,11 +79,12 @@ func (s *StructLog) OpName() string {
 }
 
 // Tracer is used to collect execution traces from an EVM transaction
-// execution. CaptureState is called for each step of the VM with the
-// current VM state.
+// execution. CaptureStart and CaptureEnd wrap the outermost call or create,
+// CaptureState is called for each step of the VM with the current VM state.
 // Note that reference types are actual VM data structures; make copies
 // if you need to retain them beyond the current call.
 type Tracer interface {
+	CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error
 	CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
@@ -98,6 +99,9 @@ type StructLogger struct {
 
 	logs          []StructLog
 	changedValues map[common.Address]Storage
+
+	output []byte
+	err    error
 }
 
 // NewStructLogger returns a new logger
@@ -111,6 +115,12 @@ func NewStructLogger(cfg *LogConfig) *StructLogger {
 	return logger
 }
 
+// CaptureStart implements the Tracer interface, nothing is logged before the
+// first step.
+func (l *StructLogger) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
 // CaptureState logs a new structured log message and pushes it out to the environment
 //
 // CaptureState also tracks SSTORE ops to track dirty values.
@@ -161,11 +171,10 @@ func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost ui
 	return nil
 }
 
+// CaptureEnd records the return data and error of the outermost call.
 func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
-	fmt.Printf("0x%x", output)
-	if err != nil {
-		fmt.Printf(" error: %v\n", err)
-	}
+	l.output = output
+	l.err = err
 	return nil
 }
 
@@ -174,6 +183,16 @@ func (l *StructLogger) StructLogs() []StructLog {
 	return l.logs
 }
 
+// Output returns the return data of the traced execution.
+func (l *StructLogger) Output() []byte {
+	return l.output
+}
+
+// Error returns the VM error of the traced execution, if any.
+func (l *StructLogger) Error() error {
+	return l.err
+}
+
 // WriteTrace writes a formatted trace to the given writer
 func WriteTrace(writer io.Writer, logs []StructLog) {
 	for _, log := range logs {
@@ -24,12 +24,14 @@ var Modules = map[string]string{
 	"debug":      Debug_JS,
 	"xdn":        Dnp_JS,
 	"miner":      Miner_JS,
+	"multisig":   Multisig_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
 	"rpc":        RPC_JS,
 	"shh":        Shh_JS,
 	"swarmfs":    SWARMFS_JS,
 	"txpool":     TxPool_JS,
+	"xdnoc":      Xdnoc_JS,
 }
 
 const Chequebook_JS = `
@@ -209,12 +211,26 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByHash',
 			call: 'debug_traceBlockByHash',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'standardTraceBlockToFile',
+			call: 'debug_standardTraceBlockToFile',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'standardTraceBadBlockToFile',
+			call: 'debug_standardTraceBadBlockToFile',
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'seedHash',
@@ -381,6 +397,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'xdn_signTypedData',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'resend',
 			call: 'xdn_resend',
@@ -470,6 +492,44 @@ web3._extend({
 });
 `
 
+const Multisig_JS = `
+web3._extend({
+	property: 'multisig',
+	methods: [
+		new web3._extend.Method({
+			name: 'deploy',
+			call: 'multisig_deploy',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'info',
+			call: 'multisig_info',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'multisig_propose',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'sign',
+			call: 'multisig_sign',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'execute',
+			call: 'multisig_execute',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
+	]
+});
+`
+
 const Net_JS = `
 web3._extend({
 	property: 'net',
@@ -498,6 +558,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'personal_signTypedData',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'ecRecover',
 			call: 'personal_ecRecover',
@@ -513,6 +579,16 @@ web3._extend({
 			call: 'personal_deriveAccount',
 			params: 3
 		}),
+		new web3._extend.Method({
+			name: 'newMnemonicWallet',
+			call: 'personal_newMnemonicWallet',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'importMnemonic',
+			call: 'personal_importMnemonic',
+			params: 2
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -606,3 +682,16 @@ web3._extend({
 	]
 });
 `
+
+const Xdnoc_JS = `
+web3._extend({
+	property: 'xdnoc',
+	methods: [],
+	properties: [
+		new web3._extend.Property({
+			name: 'clockDrift',
+			getter: 'xdnoc_clockDrift'
+		}),
+	]
+});
+`
@@ -295,6 +295,35 @@ func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error)
 	return common.Address{}, err
 }
 
+// mnemonicWallet is the JSON representation of a newly created HD wallet, along
+// with the mnemonic phrase to back it up with.
+type mnemonicWallet struct {
+	URL      string             `json:"url"`
+	Mnemonic string             `json:"mnemonic,omitempty"`
+	Accounts []accounts.Account `json:"accounts"`
+}
+
+// NewMnemonicWallet creates a new HD wallet from a freshly generated mnemonic,
+// encrypting its seed with the password. The mnemonic is returned only once and
+// must be backed up by the caller.
+func (s *PrivateAccountAPI) NewMnemonicWallet(password string) (*mnemonicWallet, error) {
+	wallet, mnemonic, err := fetchKeystore(s.am).NewMnemonicWallet(password)
+	if err != nil {
+		return nil, err
+	}
+	return &mnemonicWallet{URL: wallet.URL().String(), Mnemonic: mnemonic, Accounts: wallet.Accounts()}, nil
+}
+
+// ImportMnemonic restores an HD wallet from a BIP-39 mnemonic, encrypting its
+// seed with the password.
+func (s *PrivateAccountAPI) ImportMnemonic(mnemonic string, password string) (*mnemonicWallet, error) {
+	wallet, err := fetchKeystore(s.am).ImportMnemonic(mnemonic, password)
+	if err != nil {
+		return nil, err
+	}
+	return &mnemonicWallet{URL: wallet.URL().String(), Accounts: wallet.Accounts()}, nil
+}
+
 // fetchKeystore retrives the encrypted keystore from the account manager.
 func fetchKeystore(am *accounts.Manager) *keystore.KeyStore {
 	return am.Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
@@ -408,6 +437,30 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 	return signature, nil
 }
 
+// SignTypedData calculates an ECDSA signature for the EIP-712 structured data:
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message)).
+//
+// Note, the produced signature conforms to the secp256k1 curve R, S and V values,
+// where the V value will be 27 or 28 for legacy reasons.
+//
+// The key used to calculate the signature is decrypted with the given password.
+func (s *PrivateAccountAPI) SignTypedData(ctx context.Context, data accounts.TypedData, addr common.Address, passwd string) (hexutil.Bytes, error) {
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// Assemble sign the typed data with the wallet
+	signature, err := wallet.SignTypedDataWithPassphrase(account, passwd, &data)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
 // EcRecover returns the address for the account that was used to create the signature.
 // Note, this function is compatible with xdn_sign and personal_sign. As such it recovers
 // the address of:
@@ -980,18 +1033,28 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
+// txPrunedError returns an error wrapping core.ErrHistoryPruned if the given
+// transaction is still indexed, but the body of its block was pruned.
+func txPrunedError(db core.DatabaseReader, hash common.Hash) error {
+	blockHash, blockNumber, _ := core.GetTxLookupEntry(db, hash)
+	if blockHash == (common.Hash{}) {
+		return nil
+	}
+	return core.CheckHistory(db, blockNumber)
+}
+
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx), nil
 	}
-	// Transaction unknown, return as such
-	return nil
+	// Transaction unknown, return as such unless its block was pruned
+	return nil, txPrunedError(s.b.ChainDb(), hash)
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1002,7 +1065,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	if tx, _, _, _ = core.GetTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, txPrunedError(s.b.ChainDb(), hash)
 		}
 	}
 	// Serialize to RLP and return
@@ -1013,7 +1076,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
-		return nil, nil
+		return nil, txPrunedError(s.b.ChainDb(), hash)
 	}
 	receipt, _, _, _ := core.GetReceipt(s.b.ChainDb(), hash) // Old receipts don't have the lookup data available
 
@@ -1205,6 +1268,30 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes)
 	return signature, err
 }
 
+// SignTypedData calculates an ECDSA signature for the EIP-712 structured data:
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message)).
+//
+// Note, the produced signature conforms to the secp256k1 curve R, S and V values,
+// where the V value will be 27 or 28 for legacy reasons.
+//
+// The account associated with addr must be unlocked, or reside on a hardware
+// wallet able to display and sign typed data.
+func (s *PublicTransactionPoolAPI) SignTypedData(addr common.Address, data accounts.TypedData) (hexutil.Bytes, error) {
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// Sign the typed data with the wallet
+	signature, err := wallet.SignTypedData(account, &data)
+	if err == nil {
+		signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	}
+	return signature, err
+}
+
 // SignTransactionResult represents a RLP encoded signed transaction.
 type SignTransactionResult struct {
 	Raw hexutil.Bytes      `json:"raw"`
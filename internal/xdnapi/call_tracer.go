@@ -0,0 +1,265 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdnapi
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core/vm"
+)
+
+// callFrame is a single call or create in the call tree, laid out the same way
+// as the objects produced by the Javascript callTracer.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    string          `json:"time,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // Gas available to the caller before the call op
+	gasCost uint64 // Cost of the call op, including the gas forwarded to it
+	outOff  uint64 // Memory offset of the call's return data in the caller
+	outLen  uint64 // Memory size of the call's return data in the caller
+}
+
+// CallTracer is a native implementation of the Javascript callTracer, which
+// reconstructs the tree of internal calls made during a transaction.
+type CallTracer struct {
+	callstack []*callFrame
+	descended bool // Whether the last step entered a new call frame
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// NewCallTracer creates a new call tree tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{callstack: []*callFrame{{}}}
+}
+
+// CaptureStart implements the Tracer interface to initialize the outermost
+// call frame of the transaction.
+func (t *CallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	top := t.callstack[0]
+
+	top.Type = "CALL"
+	if create {
+		top.Type = "CREATE"
+	}
+	top.From, top.To = from, &to
+	top.Input = common.CopyBytes(input)
+	top.Gas = (*hexutil.Uint64)(&gas)
+	top.Value = (*hexutil.Big)(new(big.Int).Set(value))
+
+	return nil
+}
+
+// CaptureState implements the Tracer interface, pushing a frame for every call
+// or create and popping it once execution is back in the caller.
+func (t *CallTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	// Abort the execution if the tracer was stopped
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return nil
+	}
+	// If a new frame was just entered, record the gas it was given
+	if t.descended {
+		if depth >= len(t.callstack) {
+			t.callstack[len(t.callstack)-1].Gas = newUint64(gas)
+		}
+		t.descended = false
+	}
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			Input:   memorySlice(memory, stack.Back(1), stack.Back(2)),
+			Value:   (*hexutil.Big)(new(big.Int).Set(stack.Back(0))),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		to := common.BigToAddress(stack.Back(0))
+
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			To:      &to,
+			Value:   (*hexutil.Big)(new(big.Int).Set(env.StateDB.GetBalance(contract.Address()))),
+			Gas:     newUint64(gas),
+			GasUsed: newUint64(cost),
+		})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// Precompiles are invisible to the Javascript tracer too
+		to := common.BigToAddress(stack.Back(1))
+		if env.IsPrecompile(to) {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		call := &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			To:      &to,
+			Input:   memorySlice(memory, stack.Back(2+off), stack.Back(3+off)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(4 + off).Uint64(),
+			outLen:  stack.Back(5 + off).Uint64(),
+		}
+		if off == 1 {
+			call.Value = (*hexutil.Big)(new(big.Int).Set(stack.Back(2)))
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return nil
+
+	case vm.REVERT:
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return nil
+	}
+	// If we've just returned into the caller, finalize the call frame
+	if depth == len(t.callstack)-1 {
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		ret := stack.Back(0)
+		if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+			// Creates pay the forwarded gas outside of the op cost
+			call.GasUsed = newUint64(call.gasIn - call.gasCost - gas)
+			if ret.Sign() != 0 {
+				to := common.BigToAddress(ret)
+				call.To = &to
+				call.Output = env.StateDB.GetCode(to)
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		} else {
+			// Calls pay the forwarded gas as part of the op cost
+			if call.Gas != nil {
+				call.GasUsed = newUint64(call.gasIn - call.gasCost + uint64(*call.Gas) - gas)
+			}
+			if ret.Sign() != 0 {
+				call.Output = memorySlice(memory, new(big.Int).SetUint64(call.outOff), new(big.Int).SetUint64(call.outLen))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		}
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+	}
+	return nil
+}
+
+// fault closes the current call frame with the error that aborted it.
+func (t *CallTracer) fault(err error) {
+	// If the frame already reverted, don't handle the resulting failure again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	// A failed frame consumes all the gas it was given
+	call.Error = err.Error()
+	if call.Gas != nil {
+		call.GasUsed = newUint64(uint64(*call.Gas))
+	}
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	t.callstack = append(t.callstack, call)
+}
+
+// CaptureEnd implements the Tracer interface to finalize the outermost call.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	top := t.callstack[0]
+
+	top.GasUsed = newUint64(gasUsed)
+	top.Output = common.CopyBytes(output)
+	top.Time = d.String()
+	if err != nil && top.Error == "" {
+		top.Error = err.Error()
+	}
+	return nil
+}
+
+// Stop terminates the execution of the traced transaction at the next step.
+func (t *CallTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// GetResult returns the call tree of the traced transaction.
+func (t *CallTracer) GetResult() (interface{}, error) {
+	if atomic.LoadUint32(&t.interrupt) > 0 && t.reason != nil {
+		return nil, t.reason
+	}
+	if len(t.callstack) != 1 {
+		return nil, errors.New("incorrect number of top-level calls")
+	}
+	result := t.callstack[0]
+	if result.Error != "" && result.Error != "execution reverted" {
+		result.Output = nil
+	}
+	return result, nil
+}
+
+// newUint64 returns a JSON encodable copy of a gas value.
+func newUint64(n uint64) *hexutil.Uint64 {
+	return (*hexutil.Uint64)(&n)
+}
+
+// memorySlice returns a copy of the requested memory region, or nil if it's out
+// of the bounds of the current memory.
+func memorySlice(memory *vm.Memory, offset, size *big.Int) []byte {
+	if size.Sign() == 0 || offset.BitLen() > 64 || size.BitLen() > 64 {
+		return nil
+	}
+	begin, length := offset.Uint64(), size.Uint64()
+	if begin+length < begin || begin+length > uint64(memory.Len()) {
+		return nil
+	}
+	return memory.Get(int64(begin), int64(length))
+}
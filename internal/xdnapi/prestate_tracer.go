@@ -0,0 +1,165 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdnapi
+
+import (
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/core/vm"
+	"github.com/xdn/go-xdn/crypto"
+)
+
+// prestateAccount is the state of an account before the traced transaction,
+// laid out the same way as the objects produced by the Javascript
+// prestateTracer.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// PrestateTracer is a native implementation of the Javascript prestateTracer,
+// which collects every account and storage slot touched by a transaction with
+// the values they had before it executed.
+type PrestateTracer struct {
+	prestate map[common.Address]*prestateAccount
+	create   bool           // Whether the transaction is a contract creation
+	to       common.Address // Recipient or created contract of the transaction
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// NewPrestateTracer creates a new pre-state tracer.
+func NewPrestateTracer() *PrestateTracer {
+	return &PrestateTracer{prestate: make(map[common.Address]*prestateAccount)}
+}
+
+// lookupAccount fetches the details of an account and adds it to the prestate
+// if it doesn't exist there yet.
+func (t *PrestateTracer) lookupAccount(db vm.StateDB, addr common.Address) {
+	if _, ok := t.prestate[addr]; ok {
+		return
+	}
+	t.prestate[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(db.GetBalance(addr))),
+		Nonce:   db.GetNonce(addr),
+		Code:    common.CopyBytes(db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage fetches the requested storage slot and adds it to the prestate
+// of the account if it doesn't exist there yet.
+func (t *PrestateTracer) lookupStorage(db vm.StateDB, addr common.Address, key common.Hash) {
+	t.lookupAccount(db, addr)
+	if _, ok := t.prestate[addr].Storage[key]; ok {
+		return
+	}
+	t.prestate[addr].Storage[key] = db.GetState(addr, key)
+}
+
+// CaptureStart implements the Tracer interface to collect the sender and the
+// recipient of the transaction.
+func (t *PrestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create, t.to = create, to
+
+	t.lookupAccount(env.StateDB, from)
+	t.lookupAccount(env.StateDB, to)
+
+	// The gas was bought, the value transferred and the sender's nonce bumped by
+	// the time the call starts, move them back to get the original state. Only
+	// the intrinsic gas was used before the call, which yields the gas limit.
+	homestead := env.ChainConfig().IsHomestead(env.BlockNumber)
+	gasLimit := core.IntrinsicGas(input, create, homestead)
+	gasLimit.Add(gasLimit, new(big.Int).SetUint64(gas))
+
+	toBal := (*big.Int)(t.prestate[to].Balance)
+	t.prestate[to].Balance = (*hexutil.Big)(new(big.Int).Sub(toBal, value))
+
+	fromBal := new(big.Int).Add((*big.Int)(t.prestate[from].Balance), value)
+	fromBal.Add(fromBal, new(big.Int).Mul(env.GasPrice, gasLimit))
+	t.prestate[from].Balance = (*hexutil.Big)(fromBal)
+	t.prestate[from].Nonce--
+
+	return nil
+}
+
+// CaptureState implements the Tracer interface, looking up every account and
+// storage slot the executed op accesses.
+func (t *PrestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	// Abort the execution if the tracer was stopped
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	db := env.StateDB
+
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.EXTCODEHASH, vm.BALANCE, vm.SELFDESTRUCT:
+		t.lookupAccount(db, common.BigToAddress(stack.Back(0)))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(db, crypto.CreateAddress(from, db.GetNonce(from)))
+
+	case vm.CREATE2:
+		code := memorySlice(memory, stack.Back(1), stack.Back(2))
+		t.lookupAccount(db, crypto.CreateAddress2(contract.Address(), common.BigToHash(stack.Back(3)), crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(db, common.BigToAddress(stack.Back(1)))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(db, contract.Address(), common.BigToHash(stack.Back(0)))
+	}
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, the prestate is complete once
+// the last step executed.
+func (t *PrestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// Stop terminates the execution of the traced transaction at the next step.
+func (t *PrestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// GetResult returns the collected pre-state of the traced transaction.
+func (t *PrestateTracer) GetResult() (interface{}, error) {
+	if atomic.LoadUint32(&t.interrupt) > 0 && t.reason != nil {
+		return nil, t.reason
+	}
+	// A created contract can't have had any state before, otherwise the
+	// transaction would have been rejected as invalid.
+	if t.create {
+		delete(t.prestate, t.to)
+	}
+	return t.prestate, nil
+}
@@ -316,6 +316,12 @@ func wrapError(context string, err error) error {
 	return fmt.Errorf("%v    in server-side tracer function '%v'", message, context)
 }
 
+// CaptureStart implements the Tracer interface, the Javascript tracer only
+// sees the individual steps of the execution.
+func (jst *JavascriptTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
 // CaptureState implements the Tracer interface to trace a single step of VM execution
 func (jst *JavascriptTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
 	if jst.err == nil {
@@ -363,3 +369,27 @@ func (jst *JavascriptTracer) GetResult() (result interface{}, err error) {
 	}
 	return
 }
+
+// ResultTracer is a Tracer aggregating an execution into a single result,
+// which can be aborted from another goroutine.
+type ResultTracer interface {
+	vm.Tracer
+	Stop(err error)
+	GetResult() (interface{}, error)
+}
+
+// nativeTracers are the built-in Go tracers, selectable by name instead of
+// Javascript code.
+var nativeTracers = map[string]func() ResultTracer{
+	"callTracer":     func() ResultTracer { return NewCallTracer() },
+	"prestateTracer": func() ResultTracer { return NewPrestateTracer() },
+}
+
+// NewTracer returns the built-in tracer registered under the given name, or
+// evaluates code as a Javascript tracer otherwise.
+func NewTracer(code string) (ResultTracer, error) {
+	if constructor, ok := nativeTracers[code]; ok {
+		return constructor(), nil
+	}
+	return NewJavascriptTracer(code)
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/core"
 	"github.com/xdn/go-xdn/core/types"
 	"github.com/xdn/go-xdn/xdn"
 	"github.com/xdn/go-xdn/les/flowcontrol"
@@ -62,8 +63,9 @@ type peer struct {
 
 	id string
 
-	headInfo *announceData
-	lock     sync.RWMutex
+	headInfo   *announceData
+	chainSince uint64 // Oldest block whose body and receipts the server serves
+	lock       sync.RWMutex
 
 	announceChn chan announceData
 	sendQueue   *execQueue
@@ -182,6 +184,15 @@ func (p *peer) HasBlock(hash common.Hash, number uint64) bool {
 	return hasBlock != nil && hasBlock(hash, number)
 }
 
+// HasHistory checks if the peer still serves the body and receipts of the block
+// with the given number, i.e. it has not pruned them from its database.
+func (p *peer) HasHistory(number uint64) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return number == 0 || number >= p.chainSince
+}
+
 // SendAnnounce announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendAnnounce(request announceData) error {
@@ -407,7 +418,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 	send = send.add("genesisHash", genesis)
 	if server != nil {
 		send = send.add("serveHeaders", nil)
-		send = send.add("serveChainSince", uint64(0))
+		send = send.add("serveChainSince", core.GetBodyTail(server.protocolManager.chainDb))
 		send = send.add("serveStateSince", uint64(0))
 		send = send.add("txRelay", nil)
 		send = send.add("flowControl/BL", server.defParams.BufLimit)
@@ -467,7 +478,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		}
 		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
 	} else {
-		if recv.get("serveChainSince", nil) != nil {
+		if recv.get("serveChainSince", &p.chainSince) != nil {
 			return errResp(ErrUselessPeer, "peer cannot serve chain")
 		}
 		if recv.get("serveStateSince", nil) != nil {
@@ -83,6 +83,13 @@ func (db *odrDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, er
 	return len(code), err
 }
 
+// TrieDB implements state.Database. Light clients never commit state tries, so
+// there are no in-memory nodes to serve and the returned database only wraps
+// the local database holding the nodes retrieved on demand.
+func (db *odrDatabase) TrieDB() *trie.NodeDatabase {
+	return trie.NewNodeDatabase(db.backend.Database(), nil)
+}
+
 type odrTrie struct {
 	db   *odrDatabase
 	id   *TrieID
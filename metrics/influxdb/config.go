@@ -0,0 +1,52 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package influxdb
+
+import "time"
+
+// DefaultConfig contains default settings for the InfluxDB reporter.
+var DefaultConfig = Config{
+	Database: "xdn",
+	Interval: 10 * time.Second,
+}
+
+// Config contains the configuration parameters of the InfluxDB reporter. Note,
+// setting an endpoint, either on the command line or in the configuration file,
+// implicitly enables metrics collection.
+type Config struct {
+	// Endpoint is the base URL of the InfluxDB compatible HTTP API the metrics
+	// are pushed to (e.g. http://localhost:8086). If this field is empty, no
+	// reporter will be started.
+	Endpoint string `toml:",omitempty"`
+
+	// Database is the name of the database the metrics are written into.
+	Database string `toml:",omitempty"`
+
+	// Username and Password are the credentials to authenticate with, if any.
+	Username string `toml:",omitempty"`
+	Password string `toml:",omitempty"`
+
+	// Interval is the time between two pushes of the metrics registry.
+	Interval time.Duration `toml:",omitempty"`
+
+	// Host is the value of the host tag attached to all points. If this field
+	// is empty, the hostname of the machine is used.
+	Host string `toml:",omitempty"`
+
+	// Network is the value of the network tag attached to all points.
+	Network string `toml:",omitempty"`
+}
@@ -0,0 +1,305 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package influxdb implements a reporter pushing the metrics registry to an
+// InfluxDB compatible HTTP endpoint in line protocol.
+package influxdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/p2p"
+	"github.com/xdn/go-xdn/rpc"
+	"github.com/rcrowley/go-metrics"
+)
+
+// pushTimeout is the maximum time a single push to the endpoint may take.
+const pushTimeout = 10 * time.Second
+
+// quantiles are the percentiles reported for histograms and timers.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// quantileFields are the field names of the reported percentiles.
+var quantileFields = []string{"p50", "p75", "p95", "p99", "p999"}
+
+// lineEscaper escapes the special characters of measurement names and tag values.
+var lineEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// Reporter periodically pushes the contents of a metrics registry to an
+// InfluxDB compatible HTTP endpoint.
+type Reporter struct {
+	config   Config
+	registry metrics.Registry
+	client   *http.Client
+	endpoint string // Fully assembled write URL
+	tags     string // Pre-encoded tag set attached to all points
+
+	quit chan chan struct{} // Channel used for graceful exit
+	lock sync.Mutex         // Lock protecting the running state
+}
+
+// New creates a reporter pushing the given registry with the given configuration.
+func New(config Config, registry metrics.Registry) (*Reporter, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("no InfluxDB endpoint specified")
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig.Interval
+	}
+	if config.Database == "" {
+		config.Database = DefaultConfig.Database
+	}
+	if config.Host == "" {
+		config.Host, _ = os.Hostname()
+	}
+	// Assemble the write endpoint and the tag set once
+	base, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB endpoint: %v", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid InfluxDB endpoint scheme %q", base.Scheme)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/write"
+	query := url.Values{"db": {config.Database}, "precision": {"ns"}}
+	base.RawQuery = query.Encode()
+
+	tags := ""
+	if config.Host != "" {
+		tags += ",host=" + lineEscaper.Replace(config.Host)
+	}
+	if config.Network != "" {
+		tags += ",network=" + lineEscaper.Replace(config.Network)
+	}
+	return &Reporter{
+		config:   config,
+		registry: registry,
+		client:   &http.Client{Timeout: pushTimeout},
+		endpoint: base.String(),
+		tags:     tags,
+	}, nil
+}
+
+// Protocols is a meaningless implementation of node.Service.
+func (r *Reporter) Protocols() []p2p.Protocol { return nil }
+
+// APIs is a meaningless implementation of node.Service.
+func (r *Reporter) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the periodic reporting loop.
+func (r *Reporter) Start(server *p2p.Server) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.quit != nil {
+		return errors.New("already started")
+	}
+	r.quit = make(chan chan struct{})
+	go r.loop(r.quit)
+
+	log.Info("Started InfluxDB metrics reporter", "endpoint", r.config.Endpoint, "database", r.config.Database, "interval", r.config.Interval)
+	return nil
+}
+
+// Stop implements node.Service, pushing the metrics a final time and stopping
+// the reporting loop.
+func (r *Reporter) Stop() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.quit == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	r.quit <- done
+	<-done
+	r.quit = nil
+
+	log.Info("Stopped InfluxDB metrics reporter")
+	return nil
+}
+
+// loop pushes the registry every configured interval until terminated.
+func (r *Reporter) loop(quit chan chan struct{}) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Report(); err != nil {
+				log.Warn("Failed to push metrics to InfluxDB", "err", err)
+			}
+		case done := <-quit:
+			if err := r.Report(); err != nil {
+				log.Warn("Failed to push metrics to InfluxDB", "err", err)
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// Report pushes the current contents of the registry to the endpoint once.
+func (r *Reporter) Report() error {
+	points := r.encode(time.Now())
+	if len(points) == 0 {
+		return nil
+	}
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(points))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if r.config.Username != "" || r.config.Password != "" {
+		req.SetBasicAuth(r.config.Username, r.config.Password)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("push failed: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// encode converts all the metrics of the registry into line protocol points
+// sharing the given timestamp.
+func (r *Reporter) encode(now time.Time) []byte {
+	// Gather and sort the metrics for a stable output
+	names := []string{}
+	all := make(map[string]interface{})
+	r.registry.Each(func(name string, metric interface{}) {
+		names = append(names, name)
+		all[name] = metric
+	})
+	sort.Strings(names)
+
+	buffer := new(bytes.Buffer)
+	for _, name := range names {
+		fields := encodeFields(all[name])
+		if fields == "" {
+			continue
+		}
+		fmt.Fprintf(buffer, "%s%s %s %d\n", lineEscaper.Replace(name), r.tags, fields, now.UnixNano())
+	}
+	return buffer.Bytes()
+}
+
+// encodeFields encodes the values of a single metric into a line protocol field
+// set, or returns an empty string if the metric type is not supported or it has
+// no finite values.
+func encodeFields(metric interface{}) string {
+	switch metric := metric.(type) {
+	case metrics.Counter:
+		return "count=" + formatInt(metric.Count())
+
+	case metrics.Gauge:
+		return "value=" + formatInt(metric.Value())
+
+	case metrics.GaugeFloat64:
+		return floatField("value", metric.Value())
+
+	case metrics.Meter:
+		snapshot := metric.Snapshot()
+		return joinFields([]string{
+			"count=" + formatInt(snapshot.Count()),
+			floatField("m1", snapshot.Rate1()),
+			floatField("m5", snapshot.Rate5()),
+			floatField("m15", snapshot.Rate15()),
+			floatField("mean", snapshot.RateMean()),
+		})
+
+	case metrics.Timer:
+		snapshot := metric.Snapshot()
+		fields := []string{
+			"count=" + formatInt(snapshot.Count()),
+			"min=" + formatInt(snapshot.Min()),
+			"max=" + formatInt(snapshot.Max()),
+			floatField("mean", snapshot.Mean()),
+			floatField("stddev", snapshot.StdDev()),
+			floatField("m1", snapshot.Rate1()),
+			floatField("m5", snapshot.Rate5()),
+			floatField("m15", snapshot.Rate15()),
+			floatField("meanrate", snapshot.RateMean()),
+		}
+		return joinFields(append(fields, encodeQuantiles(snapshot.Percentiles(quantiles))...))
+
+	case metrics.Histogram:
+		snapshot := metric.Snapshot()
+		fields := []string{
+			"count=" + formatInt(snapshot.Count()),
+			"min=" + formatInt(snapshot.Min()),
+			"max=" + formatInt(snapshot.Max()),
+			floatField("mean", snapshot.Mean()),
+			floatField("stddev", snapshot.StdDev()),
+		}
+		return joinFields(append(fields, encodeQuantiles(snapshot.Percentiles(quantiles))...))
+	}
+	return ""
+}
+
+// encodeQuantiles converts the computed percentiles into line protocol fields.
+func encodeQuantiles(percentiles []float64) []string {
+	fields := make([]string, len(percentiles))
+	for i, value := range percentiles {
+		fields[i] = floatField(quantileFields[i], value)
+	}
+	return fields
+}
+
+// formatInt formats an integer field value.
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10) + "i"
+}
+
+// floatField formats a float field, or returns an empty string if the value is
+// NaN or infinite, which the line protocol can't represent.
+func floatField(key string, value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+	return key + "=" + strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// joinFields joins the non-empty fields into a line protocol field set.
+func joinFields(fields []string) string {
+	set := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field != "" {
+			set = append(set, field)
+		}
+	}
+	return strings.Join(set, ",")
+}
@@ -0,0 +1,222 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package influxdb
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// pushedRequest is the relevant content of a request received by the test server.
+type pushedRequest struct {
+	method   string
+	path     string
+	query    map[string][]string
+	username string
+	password string
+	body     string
+}
+
+// newTestServer starts an InfluxDB mock recording all writes, and replying with
+// the given status code.
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan *pushedRequest) {
+	pushes := make(chan *pushedRequest, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read pushed body: %v", err)
+		}
+		username, password, _ := r.BasicAuth()
+		pushes <- &pushedRequest{
+			method:   r.Method,
+			path:     r.URL.Path,
+			query:    r.URL.Query(),
+			username: username,
+			password: password,
+			body:     string(body),
+		}
+		if status != http.StatusNoContent {
+			http.Error(w, "database not found", status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	return server, pushes
+}
+
+// Tests that the registry is pushed to the write endpoint in line protocol, with
+// the configured database, credentials and tags.
+func TestReport(t *testing.T) {
+	server, pushes := newTestServer(t, http.StatusNoContent)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("chain/inserts", registry).Inc(3)
+	metrics.GetOrRegisterGauge("p2p/peers", registry).Update(25)
+	metrics.GetOrRegisterGaugeFloat64("txpool/ratio", registry).Update(0.5)
+	metrics.GetOrRegisterMeter("p2p/InboundTraffic", registry).Mark(1024)
+	metrics.GetOrRegisterTimer("chain/block time", registry).Update(time.Second)
+
+	reporter, err := New(Config{
+		Endpoint: server.URL + "/",
+		Database: "test",
+		Username: "user",
+		Password: "secret",
+		Host:     "node,1",
+		Network:  "1",
+	}, registry)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := reporter.Report(); err != nil {
+		t.Fatalf("failed to push metrics: %v", err)
+	}
+	push := <-pushes
+
+	if push.method != "POST" {
+		t.Errorf("method mismatch: have %s, want POST", push.method)
+	}
+	if push.path != "/write" {
+		t.Errorf("path mismatch: have %s, want /write", push.path)
+	}
+	if db := push.query["db"]; len(db) != 1 || db[0] != "test" {
+		t.Errorf("database mismatch: have %v, want [test]", db)
+	}
+	if precision := push.query["precision"]; len(precision) != 1 || precision[0] != "ns" {
+		t.Errorf("precision mismatch: have %v, want [ns]", precision)
+	}
+	if push.username != "user" || push.password != "secret" {
+		t.Errorf("credentials mismatch: have %s:%s, want user:secret", push.username, push.password)
+	}
+	// Points are sorted by measurement name, check each of them in order
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`^chain/block\\ time,host=node\\,1,network=1 count=1i,min=1000000000i,max=1000000000i,mean=1000000000,stddev=0,m1=[0-9.]+,m5=[0-9.]+,m15=[0-9.]+,meanrate=[0-9.]+,p50=1000000000,p75=1000000000,p95=1000000000,p99=1000000000,p999=1000000000 [0-9]+$`),
+		regexp.MustCompile(`^chain/inserts,host=node\\,1,network=1 count=3i [0-9]+$`),
+		regexp.MustCompile(`^p2p/InboundTraffic,host=node\\,1,network=1 count=1024i,m1=[0-9.]+,m5=[0-9.]+,m15=[0-9.]+,mean=[0-9.]+ [0-9]+$`),
+		regexp.MustCompile(`^p2p/peers,host=node\\,1,network=1 value=25i [0-9]+$`),
+		regexp.MustCompile(`^txpool/ratio,host=node\\,1,network=1 value=0.5 [0-9]+$`),
+	}
+	lines := strings.Split(strings.TrimSuffix(push.body, "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("point count mismatch: have %d, want %d\n%s", len(lines), len(expected), push.body)
+	}
+	for i, line := range lines {
+		if !expected[i].MatchString(line) {
+			t.Errorf("point %d mismatch: have %q, want match of %q", i, line, expected[i])
+		}
+	}
+}
+
+// Tests that rejected pushes are reported as errors, and that empty registries
+// are not pushed at all.
+func TestReportFailures(t *testing.T) {
+	server, pushes := newTestServer(t, http.StatusNotFound)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	reporter, err := New(Config{Endpoint: server.URL}, registry)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := reporter.Report(); err != nil {
+		t.Fatalf("failed to skip empty push: %v", err)
+	}
+	if len(pushes) != 0 {
+		t.Fatalf("empty registry pushed")
+	}
+	metrics.GetOrRegisterCounter("chain/inserts", registry).Inc(1)
+	if err := reporter.Report(); err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Fatalf("push error mismatch: have %v, want database not found", err)
+	}
+	if push := <-pushes; push.query["db"][0] != DefaultConfig.Database {
+		t.Errorf("default database mismatch: have %v, want %s", push.query["db"], DefaultConfig.Database)
+	}
+}
+
+// Tests that the reporter pushes periodically once started, and a final time
+// when stopped.
+func TestReporterLoop(t *testing.T) {
+	server, pushes := newTestServer(t, http.StatusNoContent)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("chain/inserts", registry).Inc(1)
+
+	// The interval is long enough for no periodic push to race with stopping
+	reporter, err := New(Config{Endpoint: server.URL, Interval: 100 * time.Millisecond}, registry)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := reporter.Start(nil); err != nil {
+		t.Fatalf("failed to start reporter: %v", err)
+	}
+	select {
+	case <-pushes:
+	case <-time.After(time.Second):
+		t.Fatalf("no periodic push")
+	}
+	for len(pushes) > 0 {
+		<-pushes
+	}
+	if err := reporter.Stop(); err != nil {
+		t.Fatalf("failed to stop reporter: %v", err)
+	}
+	// The final push is done synchronously before stop returns
+	if n := len(pushes); n != 1 {
+		t.Fatalf("final push count mismatch: have %d, want 1", n)
+	}
+	<-pushes
+
+	// No more pushes are allowed after the reporter stopped
+	select {
+	case <-pushes:
+		t.Fatalf("push after stop")
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+// Tests that NaN and infinite values, which the line protocol can't represent,
+// are left out of the pushed points.
+func TestReportNonFinite(t *testing.T) {
+	server, pushes := newTestServer(t, http.StatusNoContent)
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterGaugeFloat64("txpool/nan", registry).Update(math.NaN())
+	metrics.GetOrRegisterGaugeFloat64("txpool/ratio", registry).Update(math.Inf(1))
+	metrics.GetOrRegisterCounter("chain/inserts", registry).Inc(1)
+
+	reporter, err := New(Config{Endpoint: server.URL, Host: "node"}, registry)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := reporter.Report(); err != nil {
+		t.Fatalf("failed to push metrics: %v", err)
+	}
+	push := <-pushes
+	if !regexp.MustCompile(`^chain/inserts,host=node count=1i [0-9]+\n$`).MatchString(push.body) {
+		t.Errorf("points mismatch: have %q, want only chain/inserts", push.body)
+	}
+}
@@ -32,6 +32,14 @@ import (
 const MetricsEnabledFlag = "metrics"
 const DashboardEnabledFlag = "dashboard"
 
+// MetricsHTTPFlag is the CLI flag name to use to expose metrics over HTTP in the
+// Prometheus text format. Setting it implicitly enables metrics collection.
+const MetricsHTTPFlag = "metrics.addr"
+
+// MetricsInfluxDBFlag is the CLI flag name to use to push metrics to an InfluxDB
+// endpoint. Setting it implicitly enables metrics collection.
+const MetricsInfluxDBFlag = "metrics.influxdb.endpoint"
+
 // Enabled is the flag specifying if metrics are enable or not.
 var Enabled = false
 
@@ -40,7 +48,12 @@ var Enabled = false
 // and peek into the command line args for the metrics flag.
 func init() {
 	for _, arg := range os.Args {
-		if flag := strings.TrimLeft(arg, "-"); flag == MetricsEnabledFlag || flag == DashboardEnabledFlag {
+		flag := strings.TrimLeft(arg, "-")
+		if i := strings.Index(flag, "="); i >= 0 {
+			flag = flag[:i]
+		}
+		switch flag {
+		case MetricsEnabledFlag, DashboardEnabledFlag, MetricsHTTPFlag, MetricsInfluxDBFlag:
 			log.Info("Enabling metrics collection")
 			Enabled = true
 		}
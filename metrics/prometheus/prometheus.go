@@ -0,0 +1,166 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package prometheus exposes the go-metrics registries in the Prometheus text
+// exposition format.
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xdn/go-xdn/log"
+	"github.com/rcrowley/go-metrics"
+)
+
+// contentType is the content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// quantiles are the percentiles exported for histograms and timers.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// Handler returns an HTTP handler which dumps the metrics of the registry in the
+// Prometheus text exposition format on every request.
+func Handler(reg metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Gather and sort the metrics for a stable output
+		names := []string{}
+		all := make(map[string]interface{})
+		reg.Each(func(name string, metric interface{}) {
+			names = append(names, name)
+			all[name] = metric
+		})
+		sort.Strings(names)
+
+		// Distinct go-metrics names may mangle into the same Prometheus series
+		// (e.g. "a/b" and "a_b"), only export the first of the colliding ones
+		buffer := new(bytes.Buffer)
+		owners := make(map[string]string)
+		for _, name := range names {
+			mangled := mangleName(name)
+			if owner, ok := claimSeries(owners, name, seriesNames(mangled, all[name])); !ok {
+				log.Debug("Skipping colliding Prometheus metric", "name", name, "collides", owner)
+				continue
+			}
+			writeMetric(buffer, mangled, all[name])
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(buffer.Bytes())
+	})
+}
+
+// writeMetric encodes a single metric in the Prometheus text format. Counters
+// and gauges are exported as gauges (go-metrics counters may also decrease),
+// meters as counters of the marked events, histograms as summaries and timers
+// as summaries in seconds.
+func writeMetric(w *bytes.Buffer, name string, metric interface{}) {
+	switch metric := metric.(type) {
+	case metrics.Counter:
+		writeGauge(w, name, float64(metric.Count()))
+
+	case metrics.Gauge:
+		writeGauge(w, name, float64(metric.Value()))
+
+	case metrics.GaugeFloat64:
+		writeGauge(w, name, metric.Value())
+
+	case metrics.Meter:
+		fmt.Fprintf(w, "# TYPE %s_total counter\n", name)
+		fmt.Fprintf(w, "%s_total %d\n", name, metric.Count())
+
+	case metrics.Timer:
+		snapshot := metric.Snapshot()
+		writeSummary(w, name+"_seconds", snapshot.Percentiles(quantiles), float64(snapshot.Sum()), snapshot.Count(), 1e-9)
+
+	case metrics.Histogram:
+		snapshot := metric.Snapshot()
+		writeSummary(w, name, snapshot.Percentiles(quantiles), float64(snapshot.Sum()), snapshot.Count(), 1)
+	}
+}
+
+// seriesNames returns the names of all the series writeMetric would export for a
+// metric under the given mangled name.
+func seriesNames(name string, metric interface{}) []string {
+	switch metric.(type) {
+	case metrics.Counter, metrics.Gauge, metrics.GaugeFloat64:
+		return []string{name}
+	case metrics.Meter:
+		return []string{name + "_total"}
+	case metrics.Timer:
+		name += "_seconds"
+		return []string{name, name + "_sum", name + "_count"}
+	case metrics.Histogram:
+		return []string{name, name + "_sum", name + "_count"}
+	}
+	return nil
+}
+
+// claimSeries assigns the given series to a metric, unless any of them is already
+// exported by another one, in which case the name of that owner is returned.
+func claimSeries(owners map[string]string, metric string, series []string) (string, bool) {
+	for _, name := range series {
+		if owner, ok := owners[name]; ok {
+			return owner, false
+		}
+	}
+	for _, name := range series {
+		owners[name] = metric
+	}
+	return "", true
+}
+
+// writeGauge encodes a single value metric.
+func writeGauge(w *bytes.Buffer, name string, value float64) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+// writeSummary encodes a distribution metric with its quantiles, sum and count,
+// scaling all the sampled values by the given factor.
+func writeSummary(w *bytes.Buffer, name string, percentiles []float64, sum float64, count int64, scale float64) {
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	for i, quantile := range quantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n", name, formatFloat(quantile), formatFloat(percentiles[i]*scale))
+	}
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(sum*scale))
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+}
+
+// formatFloat formats a sample value as expected by Prometheus.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// mangleName converts a go-metrics name (e.g. "xdn/db/chaindata/user/gets") into
+// a valid Prometheus metric name (e.g. "xdn_db_chaindata_user_gets").
+func mangleName(name string) string {
+	mangled := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if len(mangled) == 0 || (mangled[0] >= '0' && mangled[0] <= '9') {
+		mangled = "_" + mangled
+	}
+	return mangled
+}
@@ -24,8 +24,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/accounts/external"
 	"github.com/xdn/go-xdn/accounts/keystore"
 	"github.com/xdn/go-xdn/accounts/usbwallet"
 	"github.com/xdn/go-xdn/common"
@@ -33,6 +35,7 @@ import (
 	"github.com/xdn/go-xdn/log"
 	"github.com/xdn/go-xdn/p2p"
 	"github.com/xdn/go-xdn/p2p/discover"
+	"github.com/xdn/go-xdn/rpc"
 )
 
 const (
@@ -85,6 +88,15 @@ type Config struct {
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// ExternalSigner is the endpoint of a standalone signer the node delegates
+	// transaction signing to, keeping the keys out of the node's process.
+	ExternalSigner string `toml:",omitempty"`
+
+	// ExternalSignerJWTSecret is the path of a file containing the hex encoded
+	// HMAC secret to authenticate to an HTTP external signer with. Relative paths
+	// are resolved within the instance directory.
+	ExternalSignerJWTSecret string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -135,6 +147,30 @@ type Config struct {
 	// *WARNING* Only set this if the node is running in a trusted network, exposing
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
+
+	// JWTSecret is the path of a file containing the hex encoded HMAC secret used
+	// to authenticate HTTP and websocket RPC requests with HS256 signed JSON Web
+	// Tokens. Relative paths are resolved within the instance directory.
+	JWTSecret string `toml:",omitempty"`
+
+	// AuthTokens is a list of static bearer tokens accepted on the HTTP and
+	// websocket RPC interfaces. If neither tokens nor a JWT secret are set, the
+	// RPC interfaces don't require any authentication.
+	AuthTokens []string `toml:",omitempty"`
+
+	// IPCAccess, HTTPAccess and WSAccess restrict the individual methods callable
+	// over the respective RPC interface, on top of the exposed API modules.
+	IPCAccess  rpc.AccessList `toml:",omitempty"`
+	HTTPAccess rpc.AccessList `toml:",omitempty"`
+	WSAccess   rpc.AccessList `toml:",omitempty"`
+
+	// RPCLimits configures the per client rate limits and the batch and response
+	// size limits of the HTTP and websocket RPC interfaces.
+	RPCLimits rpc.Limits `toml:",omitempty"`
+
+	// RPCSlowCallThreshold is the duration above which RPC method calls on any of
+	// the interfaces are logged as slow. Zero disables slow call logging.
+	RPCSlowCallThreshold time.Duration `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -318,6 +354,27 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// RPCAuth loads the authentication settings of the HTTP and websocket RPC
+// interfaces, returning nil if they don't require authentication.
+func (c *Config) RPCAuth() (*rpc.AuthConfig, error) {
+	if c.JWTSecret == "" && len(c.AuthTokens) == 0 {
+		return nil, nil
+	}
+	auth := &rpc.AuthConfig{Tokens: c.AuthTokens}
+	if c.JWTSecret != "" {
+		path := c.resolvePath(c.JWTSecret)
+		if path == "" {
+			return nil, fmt.Errorf("can't resolve JWT secret path %q without a datadir", c.JWTSecret)
+		}
+		secret, err := rpc.ReadJWTSecret(path)
+		if err != nil {
+			return nil, err
+		}
+		auth.JWTSecret = secret
+	}
+	return auth, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirStaticNodes))
@@ -421,5 +478,21 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 			backends = append(backends, trezorhub)
 		}
 	}
+	if conf.ExternalSigner != "" {
+		// Connect to the standalone signer holding the keys
+		var auth rpc.ClientAuth
+		if conf.ExternalSignerJWTSecret != "" {
+			secret, err := rpc.ReadJWTSecret(conf.resolvePath(conf.ExternalSignerJWTSecret))
+			if err != nil {
+				return nil, "", err
+			}
+			auth = rpc.JWTAuth(secret)
+		}
+		extapi, err := external.NewExternalBackend(conf.ExternalSigner, auth)
+		if err != nil {
+			return nil, "", fmt.Errorf("error connecting to external signer: %v", err)
+		}
+		backends = append(backends, extapi)
+	}
 	return accounts.NewManager(backends...), ephemeral, nil
 }
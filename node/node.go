@@ -282,6 +282,7 @@ func (n *Node) startInProc(apis []rpc.API) error {
 		}
 		log.Debug(fmt.Sprintf("InProc registered %T under '%s'", api.Service, api.Namespace))
 	}
+	handler.SetSlowCallThreshold(n.config.RPCSlowCallThreshold)
 	n.inprocHandler = handler
 	return nil
 }
@@ -308,6 +309,8 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		}
 		log.Debug(fmt.Sprintf("IPC registered %T under '%s'", api.Service, api.Namespace))
 	}
+	handler.SetAccessList(n.config.IPCAccess)
+	handler.SetSlowCallThreshold(n.config.RPCSlowCallThreshold)
 	// All APIs registered, start the IPC listener
 	var (
 		listener net.Listener
@@ -378,16 +381,20 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 			log.Debug(fmt.Sprintf("HTTP registered %T under '%s'", api.Service, api.Namespace))
 		}
 	}
+	handler.SetAccessList(n.config.HTTPAccess)
+	handler.SetSlowCallThreshold(n.config.RPCSlowCallThreshold)
+	handler.SetLimits(n.config.RPCLimits)
+	auth, err := n.config.RPCAuth()
+	if err != nil {
+		return err
+	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
+	var listener net.Listener
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
-	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint))
+	go rpc.NewHTTPServerWithAuth(cors, auth, handler).Serve(listener)
+	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint), "auth", auth != nil)
 
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -432,16 +439,20 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 			log.Debug(fmt.Sprintf("WebSocket registered %T under '%s'", api.Service, api.Namespace))
 		}
 	}
+	handler.SetAccessList(n.config.WSAccess)
+	handler.SetSlowCallThreshold(n.config.RPCSlowCallThreshold)
+	handler.SetLimits(n.config.RPCLimits)
+	auth, err := n.config.RPCAuth()
+	if err != nil {
+		return err
+	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
+	var listener net.Listener
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
-	log.Info(fmt.Sprintf("WebSocket endpoint opened: ws://%s", listener.Addr()))
+	go rpc.NewWSServerWithAuth(wsOrigins, auth, handler).Serve(listener)
+	log.Info(fmt.Sprintf("WebSocket endpoint opened: ws://%s", listener.Addr()), "auth", auth != nil)
 
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
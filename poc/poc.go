@@ -186,4 +186,24 @@ func CalcDeadLine(target *big.Int, baseTarget *big.Int) *big.Int {
 func CalcPlotID(addr common.Address) uint64 {
 	tmp := (addr.Bytes())[15:]
 	return uint64(binary.BigEndian.Uint64(tmp))
-}
\ No newline at end of file
+}
+
+// CalcNonceTarget regenerates the given nonce of a plot and returns its target
+// value for the block at number mined on top of genSig.
+func CalcNonceTarget(genSig common.Hash, number uint64, plotID uint64, nonce uint64) *big.Int {
+	genHash := GenHash(genSig, number)
+	scoopID := GetScoopID(genHash)
+
+	cells := GenCell(nonce, plotID)
+	scoop_1 := cells[64 * scoopID : 64 * scoopID + 32]
+	scoop_2 := cells[64 * scoopID + 32 : 64 * scoopID + 64]
+
+	target := CalcTarget(scoop_1, scoop_2, genSig)
+	return new(big.Int).SetBytes((target.Bytes())[24:])
+}
+
+// CalcNonceDeadLine regenerates the given nonce of a plot and returns the
+// deadline it yields against baseTarget.
+func CalcNonceDeadLine(genSig common.Hash, number uint64, plotID uint64, nonce uint64, baseTarget *big.Int) *big.Int {
+	return CalcDeadLine(CalcNonceTarget(genSig, number, plotID, nonce), baseTarget)
+}
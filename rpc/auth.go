@@ -0,0 +1,228 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtIssuedAtLeeway is the maximum allowed difference between the issuance
+	// time of a JSON Web Token and the local clock.
+	jwtIssuedAtLeeway = 60 * time.Second
+
+	// minJWTSecretLength is the minimum number of bytes of a JWT secret.
+	minJWTSecretLength = 32
+)
+
+var (
+	errMissingAuth    = errors.New("missing bearer token")
+	errInvalidAuth    = errors.New("invalid bearer token")
+	errJWTAlgorithm   = errors.New("unsupported JWT signing algorithm")
+	errJWTSignature   = errors.New("invalid JWT signature")
+	errJWTIssuedAt    = errors.New("JWT issued-at time out of range")
+	errJWTExpired     = errors.New("JWT expired")
+	errJWTMalformed   = errors.New("malformed JWT")
+	errEmptyJWTSecret = errors.New("empty JWT secret")
+)
+
+// AuthConfig configures the authentication of incoming HTTP and WebSocket RPC
+// requests. A request is accepted if it carries an "Authorization: Bearer"
+// header with either one of the static tokens, or with a JSON Web Token signed
+// with the HMAC secret (HS256).
+type AuthConfig struct {
+	JWTSecret []byte   // HMAC secret for the JSON Web Tokens (nil = JWTs not accepted)
+	Tokens    []string // Static bearer tokens to accept
+}
+
+// enabled returns whxdner any authentication is configured.
+func (auth *AuthConfig) enabled() bool {
+	return auth != nil && (len(auth.JWTSecret) > 0 || len(auth.Tokens) > 0)
+}
+
+// verify checks the credentials carried by an HTTP request.
+func (auth *AuthConfig) verify(r *http.Request) error {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return errMissingAuth
+	}
+	token := strings.TrimSpace(header[7:])
+	for _, allowed := range auth.Tokens {
+		if subtle.ConstantTimeCompare([]byte(allowed), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	if len(auth.JWTSecret) > 0 && strings.Count(token, ".") == 2 {
+		return verifyJWT(auth.JWTSecret, token, time.Now())
+	}
+	return errInvalidAuth
+}
+
+// ReadJWTSecret loads a hex encoded JWT secret from the given file.
+func ReadJWTSecret(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT secret: %v", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret in %s: %v", path, err)
+	}
+	if len(secret) < minJWTSecretLength {
+		return nil, fmt.Errorf("JWT secret in %s too short: %d bytes < %d", path, len(secret), minJWTSecretLength)
+	}
+	return secret, nil
+}
+
+// newAuthHandler wraps an HTTP handler, rejecting all requests that fail the
+// configured authentication. If no authentication is configured, the handler
+// is returned as is.
+func newAuthHandler(auth *AuthConfig, next http.Handler) http.Handler {
+	if !auth.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := auth.verify(r); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rpc"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientAuth sets the credentials of an outgoing HTTP request, or of the HTTP
+// handshake of a WebSocket connection.
+type ClientAuth func(header http.Header) error
+
+// BearerAuth creates a client authenticator sending a static bearer token.
+func BearerAuth(token string) ClientAuth {
+	return func(header http.Header) error {
+		header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// JWTAuth creates a client authenticator signing a fresh JSON Web Token with
+// the given HMAC secret for every request.
+func JWTAuth(secret []byte) ClientAuth {
+	return func(header http.Header) error {
+		if len(secret) == 0 {
+			return errEmptyJWTSecret
+		}
+		token, err := signJWT(secret, jwtClaims{IssuedAt: time.Now().Unix()})
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// jwtHeader is the JOSE header of the supported JSON Web Tokens.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// jwtClaims are the registered claims checked by the server. Any other claims
+// are ignored.
+type jwtClaims struct {
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// signJWT creates an HS256 signed JSON Web Token with the given claims.
+func signJWT(secret []byte, claims jwtClaims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(secret, signed)), nil
+}
+
+// verifyJWT checks the signature and the time claims of an HS256 signed JSON
+// Web Token. Tokens need to carry an issued-at claim close to the local time,
+// limiting the usefulness of leaked ones.
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errJWTMalformed
+	}
+	// Check the signature before interpreting any of the contents
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errJWTMalformed
+	}
+	if !hmac.Equal(sig, jwtSignature(secret, parts[0]+"."+parts[1])) {
+		return errJWTSignature
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return fmt.Errorf("%v: %q", errJWTAlgorithm, header.Alg)
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return err
+	}
+	issued := time.Unix(claims.IssuedAt, 0)
+	if issued.Before(now.Add(-jwtIssuedAtLeeway)) || issued.After(now.Add(jwtIssuedAtLeeway)) {
+		return errJWTIssuedAt
+	}
+	if claims.ExpiresAt != 0 && !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return errJWTExpired
+	}
+	return nil
+}
+
+// jwtSignature calculates the HS256 signature of a JWT signing input.
+func jwtSignature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// decodeJWTPart decodes a base64 encoded JSON segment of a JWT.
+func decodeJWTPart(part string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
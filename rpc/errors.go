@@ -64,3 +64,19 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// request is for a method forbidden by the access list of the server
+type methodDeniedError struct{ method string }
+
+func (e *methodDeniedError) ErrorCode() int { return -32601 }
+
+func (e *methodDeniedError) Error() string {
+	return fmt.Sprintf("The method %s is not allowed", e.method)
+}
+
+// request exceeds one of the resource limits of the server
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
@@ -43,6 +43,7 @@ var nullAddr, _ = net.ResolveTCPAddr("tcp", "127.0.0.1:0")
 type httpConn struct {
 	client    *http.Client
 	req       *http.Request
+	auth      ClientAuth
 	closeOnce sync.Once
 	closed    chan struct{}
 }
@@ -67,6 +68,12 @@ func (hc *httpConn) Close() error {
 
 // DialHTTP creates a new RPC clients that connection to an RPC server over HTTP.
 func DialHTTP(endpoint string) (*Client, error) {
+	return DialHTTPWithAuth(endpoint, nil)
+}
+
+// DialHTTPWithAuth creates a new RPC client that connects to an RPC server over
+// HTTP, authenticating every request with the given credentials.
+func DialHTTPWithAuth(endpoint string, auth ClientAuth) (*Client, error) {
 	req, err := http.NewRequest("POST", endpoint, nil)
 	if err != nil {
 		return nil, err
@@ -76,7 +83,7 @@ func DialHTTP(endpoint string) (*Client, error) {
 
 	initctx := context.Background()
 	return newClient(initctx, func(context.Context) (net.Conn, error) {
-		return &httpConn{client: new(http.Client), req: req, closed: make(chan struct{})}, nil
+		return &httpConn{client: new(http.Client), req: req, auth: auth, closed: make(chan struct{})}, nil
 	})
 }
 
@@ -121,10 +128,24 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	req.Body = ioutil.NopCloser(bytes.NewReader(body))
 	req.ContentLength = int64(len(body))
 
+	if hc.auth != nil {
+		req.Header = make(http.Header, len(hc.req.Header)+1)
+		for key, values := range hc.req.Header {
+			req.Header[key] = values
+		}
+		if err := hc.auth(req.Header); err != nil {
+			return nil, err
+		}
+	}
+
 	resp, err := hc.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unauthorized: %s", resp.Header.Get("WWW-Authenticate"))
+	}
 	return resp.Body, nil
 }
 
@@ -143,7 +164,15 @@ func (t *httpReadWriteNopCloser) Close() error {
 //
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, srv *Server) *http.Server {
-	return &http.Server{Handler: newCorsHandler(srv, cors)}
+	return NewHTTPServerWithAuth(cors, nil, srv)
+}
+
+// NewHTTPServerWithAuth creates a new HTTP RPC server around an API provider,
+// rejecting any request not authenticated according to auth. CORS preflight
+// requests are answered without authentication, as browsers can't attach any
+// credentials to them.
+func NewHTTPServerWithAuth(cors []string, auth *AuthConfig, srv *Server) *http.Server {
+	return &http.Server{Handler: newCorsHandler(newAuthHandler(auth, srv), cors)}
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
@@ -163,7 +192,7 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer codec.Close()
 
 	w.Header().Set("content-type", contentType)
-	srv.ServeSingleRequest(codec, OptionMethodInvocation)
+	srv.serveRequest(codec, true, OptionMethodInvocation, clientAddress(r.RemoteAddr))
 }
 
 // validateRequest returns a non-zero response code and error message if the
@@ -184,7 +213,7 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -0,0 +1,279 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterPruneInterval is the time between two sweeps of the rate limiter
+// dropping the token buckets of idle clients.
+const rateLimiterPruneInterval = time.Minute
+
+var (
+	// errResponseTooLarge is returned by the response encoder if a reply exceeds
+	// the remaining response budget.
+	errResponseTooLarge = errors.New("response too large")
+
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// AccessList restricts the methods callable on a server. Entries are either full
+// method names (e.g. "xdn_call"), namespace wildcards (e.g. "debug_*") or "*"
+// for every method. Subscriptions are matched as "<namespace>_subscribe".
+type AccessList struct {
+	Allow []string `toml:",omitempty"` // If non-empty, only matching methods may be called
+	Deny  []string `toml:",omitempty"` // Matching methods may never be called, even if allowed
+}
+
+// permits returns whxdner the method with the given full name may be called.
+func (l *AccessList) permits(method string) bool {
+	for _, pattern := range l.Deny {
+		if matchMethod(pattern, method) {
+			return false
+		}
+	}
+	if len(l.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range l.Allow {
+		if matchMethod(pattern, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMethod returns whxdner a method name matches an access list entry.
+func matchMethod(pattern, method string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, serviceMethodSeparator+"*") {
+		return strings.HasPrefix(method, pattern[:len(pattern)-1])
+	}
+	return pattern == method
+}
+
+// Limits configures the resource limits a server enforces on its clients. Zero
+// values disable the respective limit.
+type Limits struct {
+	RequestRate   float64 `toml:",omitempty"` // Sustained number of requests per second allowed per client
+	RequestBurst  int     `toml:",omitempty"` // Number of requests a client may issue at once above the rate
+	BatchItems    int     `toml:",omitempty"` // Maximum number of requests in a single batch, at most the burst if rate limited
+	ResponseBytes int     `toml:",omitempty"` // Maximum size of a single (batch) response in bytes
+}
+
+// SetAccessList restricts the methods callable on the server. It must be called
+// before the server starts serving requests.
+func (s *Server) SetAccessList(list AccessList) {
+	s.access = list
+}
+
+// SetLimits configures the resource limits of the server. It must be called
+// before the server starts serving requests.
+func (s *Server) SetLimits(limits Limits) {
+	s.limits = limits
+	s.limiter = newRateLimiter(limits.RequestRate, limits.RequestBurst)
+}
+
+// limitResponse enforces the response size limit on a single reply, deducting
+// its encoded size from the remaining budget of the request. If the budget is
+// exceeded, an error response is returned in place of the reply and ok is false.
+func (s *Server) limitResponse(codec ServerCodec, id interface{}, response interface{}, budget *int) (interface{}, bool) {
+	buf := &limitedBuffer{limit: *budget}
+	if err := encodeResponse(buf, response); err != nil {
+		if err != errResponseTooLarge {
+			return response, true // Let the codec report the encoding failure
+		}
+		*budget = 0
+		return codec.CreateErrorResponse(&id, &limitExceededError{fmt.Sprintf("response too large (> %d bytes)", s.limits.ResponseBytes)}), false
+	}
+	*budget -= buf.Len()
+	return json.RawMessage(buf.Bytes()), true
+}
+
+// limitedBuffer is a byte buffer refusing writes beyond a size limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write implements io.Writer, failing with errResponseTooLarge if the data does
+// not fit into the buffer any more.
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	if b.Len()+len(data) > b.limit {
+		return 0, errResponseTooLarge
+	}
+	return b.Buffer.Write(data)
+}
+
+// encodeResponse JSON encodes a response into a limited buffer. List results
+// (e.g. logs) are encoded item by item, so an oversized reply is abandoned at
+// the first item not fitting into the buffer, without serializing it in full.
+func encodeResponse(buf *limitedBuffer, response interface{}) error {
+	if res, ok := response.(*jsonSuccessResponse); ok {
+		if items := reflect.ValueOf(res.Result); isPlainList(items) {
+			if _, err := buf.WriteString(`{"jsonrpc":"` + res.Version + `",`); err != nil {
+				return err
+			}
+			if res.Id != nil {
+				if err := encodeJSON(buf, `"id":`, res.Id); err != nil {
+					return err
+				}
+				if _, err := buf.Write([]byte{','}); err != nil {
+					return err
+				}
+			}
+			if _, err := buf.WriteString(`"result":[`); err != nil {
+				return err
+			}
+			for i := 0; i < items.Len(); i++ {
+				prefix := ","
+				if i == 0 {
+					prefix = ""
+				}
+				if err := encodeJSON(buf, prefix, items.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			_, err := buf.WriteString("]}")
+			return err
+		}
+	}
+	return encodeJSON(buf, "", response)
+}
+
+// encodeJSON writes the prefix and the JSON encoding of v into the buffer.
+func encodeJSON(buf *limitedBuffer, prefix string, v interface{}) error {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = buf.Write(append([]byte(prefix), blob...))
+	return err
+}
+
+// isPlainList returns whxdner a value is a non-nil slice encoded as a JSON array
+// of its items, i.e. neither a byte slice nor a type with custom marshalling.
+func isPlainList(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice || v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return false
+	}
+	return true
+}
+
+// clientAddress returns the host of a remote address, used to identify clients
+// for rate limiting.
+func clientAddress(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// rateLimiter is a per client token bucket rate limiter.
+type rateLimiter struct {
+	rate  float64 // Tokens added to every bucket per second
+	burst float64 // Capacity of the buckets
+
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+	lock    sync.Mutex
+}
+
+// tokenBucket tracks the available request allowance of a single client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a rate limiter allowing the given number of requests
+// per second to every client, or nil if rate limiting is disabled.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		pruned:  time.Now(),
+	}
+}
+
+// allow takes the given number of tokens from the bucket of the client, or
+// returns an error if not enough are available. Requests costing more than the
+// burst could never be served, so they fail with a distinct error. Local clients
+// without an address (in-process, IPC) are never limited.
+func (l *rateLimiter) allow(client string, cost int) Error {
+	if l == nil || client == "" {
+		return nil
+	}
+	if float64(cost) > l.burst {
+		return &limitExceededError{fmt.Sprintf("batch exceeds rate burst (%d > %d)", cost, int(l.burst))}
+	}
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Drop the buckets of idle clients, they'd be full anyway
+	if now.Sub(l.pruned) > rateLimiterPruneInterval {
+		for id, bucket := range l.buckets {
+			if bucket.refill(now, l.rate, l.burst) >= l.burst {
+				delete(l.buckets, id)
+			}
+		}
+		l.pruned = now
+	}
+	bucket := l.buckets[client]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+	if bucket.refill(now, l.rate, l.burst) < float64(cost) {
+		return &limitExceededError{"request rate limit exceeded"}
+	}
+	bucket.tokens -= float64(cost)
+	return nil
+}
+
+// refill adds the tokens accrued since the last refill to the bucket, returning
+// the number of tokens available.
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) float64 {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	return b.tokens
+}
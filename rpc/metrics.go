@@ -0,0 +1,103 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+var (
+	rpcRequestMeter = metrics.NewMeter("rpc/requests")
+	rpcFailureMeter = metrics.NewMeter("rpc/failures")
+	rpcInflight     = metrics.NewCounter("rpc/inflight")
+)
+
+// methodMetrics are the metrics collected about the calls of a single method.
+type methodMetrics struct {
+	name     string            // Full name of the method (namespace_method)
+	requests gometrics.Meter   // Number of calls of the method
+	failures gometrics.Meter   // Number of calls returning an error
+	duration gometrics.Timer   // Latency distribution of the calls
+	inflight gometrics.Counter // Number of calls currently executing
+}
+
+// newMethodMetrics creates the metrics of the method with the given full name.
+func newMethodMetrics(name string) *methodMetrics {
+	return &methodMetrics{
+		name:     name,
+		requests: metrics.NewMeter("rpc/calls/" + name),
+		failures: metrics.NewMeter("rpc/errors/" + name),
+		duration: metrics.NewTimer("rpc/duration/" + name),
+		inflight: metrics.NewCounter("rpc/inflight/" + name),
+	}
+}
+
+// SetSlowCallThreshold configures the duration above which method calls are
+// logged as slow. Zero disables slow call logging. It must be called before the
+// server starts serving requests.
+func (s *Server) SetSlowCallThreshold(threshold time.Duration) {
+	s.slowCall = threshold
+}
+
+// beginCall marks the start of executing a method call.
+func (s *Server) beginCall(req *serverRequest) time.Time {
+	rpcInflight.Inc(1)
+	req.callb.metrics.inflight.Inc(1)
+	return time.Now()
+}
+
+// endCall records the metrics of a finished method call, logging it if it took
+// longer than the slow call threshold.
+func (s *Server) endCall(req *serverRequest, start time.Time, failed bool) {
+	elapsed := time.Since(start)
+
+	rpcInflight.Dec(1)
+	req.callb.metrics.inflight.Dec(1)
+	req.callb.metrics.requests.Mark(1)
+	req.callb.metrics.duration.Update(elapsed)
+	if failed {
+		rpcFailureMeter.Mark(1)
+		req.callb.metrics.failures.Mark(1)
+	}
+	if s.slowCall > 0 && elapsed >= s.slowCall {
+		log.Warn("Slow RPC call", "method", req.callb.metrics.name, "params", paramsDigest(req.params), "client", req.client, "failed", failed, "elapsed", elapsed)
+	}
+}
+
+// paramsDigest returns a short digest of the raw parameters of a request, used
+// to correlate repeated calls in the logs without dumping their contents.
+func paramsDigest(params interface{}) string {
+	if params == nil {
+		return "none"
+	}
+	blob, ok := params.(json.RawMessage)
+	if !ok {
+		var err error
+		if blob, err = json.Marshal(params); err != nil {
+			return "invalid"
+		}
+	}
+	digest := sha256.Sum256(blob)
+	return fmt.Sprintf("%x(%d)", digest[:6], len(blob))
+}
@@ -95,6 +95,12 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	}
 
 	methods, subscriptions := suitableCallbacks(rcvrVal, svc.typ)
+	for method, callb := range methods {
+		callb.metrics = newMethodMetrics(name + serviceMethodSeparator + method)
+	}
+	for method, callb := range subscriptions {
+		callb.metrics = newMethodMetrics(name + serviceMethodSeparator + method)
+	}
 
 	// already a previous service register under given sname, merge methods/subscriptions
 	if regsvc, present := s.services[name]; present {
@@ -131,7 +137,10 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 // If singleShot is true it will process a single request, otherwise it will handle
 // requests until the codec returns an error when reading a request (in most cases
 // an EOF). It executes requests in parallel when singleShot is false.
-func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption) error {
+//
+// The client is the address of the remote party used for rate limiting, empty
+// for local connections.
+func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption, client string) error {
 	var pend sync.WaitGroup
 
 	defer func() {
@@ -165,7 +174,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 	// test if the server is ordered to stop
 	for atomic.LoadInt32(&s.run) == 1 {
-		reqs, batch, err := s.readRequest(codec)
+		reqs, batch, err := s.readRequest(codec, client)
 		if err != nil {
 			// If a parsing error occurred, send an error
 			if err.Error() != "EOF" {
@@ -221,14 +230,21 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(codec, false, options)
+	s.serveRequest(codec, false, options, "")
+}
+
+// serveRemoteCodec is like ServeCodec, but rate limits the requests based on the
+// address of the remote client.
+func (s *Server) serveRemoteCodec(codec ServerCodec, options CodecOption, client string) {
+	defer codec.Close()
+	s.serveRequest(codec, false, options, client)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
 func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
-	s.serveRequest(codec, true, options)
+	s.serveRequest(codec, true, options, "")
 }
 
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
@@ -283,7 +299,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	if req.callb.isSubscribe {
+		start := s.beginCall(req)
 		subid, err := s.createSubscription(ctx, codec, req)
+		s.endCall(req, start, err != nil)
 		if err != nil {
 			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 		}
@@ -299,6 +317,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 	// regular RPC call, prepare arguments
 	if len(req.args) != len(req.callb.argTypes) {
+		s.endCall(req, s.beginCall(req), true)
 		rpcErr := &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
 			req.svcname, serviceMethodSeparator, req.callb.method.Name,
 			len(req.callb.argTypes), len(req.args))}
@@ -314,7 +333,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	// execute RPC method and return result
+	start := s.beginCall(req)
 	reply := req.callb.method.Func.Call(arguments)
+	s.endCall(req, start, req.callb.errPos >= 0 && !reply[req.callb.errPos].IsNil())
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
@@ -333,11 +354,21 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
 	var callback func()
+	rpcRequestMeter.Mark(1)
 	if req.err != nil {
+		rpcFailureMeter.Mark(1)
 		response = codec.CreateErrorResponse(&req.id, req.err)
 	} else {
 		response, callback = s.handle(ctx, codec, req)
 	}
+	if s.limits.ResponseBytes > 0 {
+		budget := s.limits.ResponseBytes
+
+		var ok bool
+		if response, ok = s.limitResponse(codec, req.id, response, &budget); !ok {
+			callback = nil // The client never learned the subscription id
+		}
+	}
 
 	if err := codec.Write(response); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
@@ -355,15 +386,28 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
+	budget := s.limits.ResponseBytes
+	rpcRequestMeter.Mark(int64(len(requests)))
 	for i, req := range requests {
+		var callback func()
 		if req.err != nil {
+			rpcFailureMeter.Mark(1)
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
+		} else if s.limits.ResponseBytes > 0 && budget == 0 {
+			// Don't bother executing calls once the response is known to be too large
+			responses[i] = codec.CreateErrorResponse(&req.id, &limitExceededError{fmt.Sprintf("response too large (> %d bytes)", s.limits.ResponseBytes)})
 		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
+			responses[i], callback = s.handle(ctx, codec, req)
+		}
+		if s.limits.ResponseBytes > 0 {
+			var ok bool
+			if responses[i], ok = s.limitResponse(codec, req.id, responses[i], &budget); !ok {
+				callback = nil // The client never learned the subscription id
 			}
 		}
+		if callback != nil {
+			callbacks = append(callbacks, callback)
+		}
 	}
 
 	if err := codec.Write(responses); err != nil {
@@ -380,7 +424,7 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
 // error when the request could not be read/parsed.
-func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error) {
+func (s *Server) readRequest(codec ServerCodec, client string) ([]*serverRequest, bool, Error) {
 	reqs, batch, err := codec.ReadRequestHeaders()
 	if err != nil {
 		return nil, batch, err
@@ -388,6 +432,20 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 	requests := make([]*serverRequest, len(reqs))
 
+	// reject the entire (batch) request if it exceeds the limits of the server
+	var limitErr Error
+	if batch && s.limits.BatchItems > 0 && len(reqs) > s.limits.BatchItems {
+		limitErr = &limitExceededError{fmt.Sprintf("batch too large (%d > %d)", len(reqs), s.limits.BatchItems)}
+	} else {
+		limitErr = s.limiter.allow(client, len(reqs))
+	}
+	if limitErr != nil {
+		for i, r := range reqs {
+			requests[i] = &serverRequest{id: r.id, err: limitErr}
+		}
+		return requests, batch, nil
+	}
+
 	// verify requests
 	for i, r := range reqs {
 		var ok bool
@@ -409,6 +467,15 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
+		name := r.service + serviceMethodSeparator + r.method
+		if r.isPubSub {
+			name = r.service + serviceMethodSeparator + "subscribe"
+		}
+		if !s.access.permits(name) { // rpc method is forbidden
+			requests[i] = &serverRequest{id: r.id, err: &methodDeniedError{name}}
+			continue
+		}
+
 		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
 			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 			continue
@@ -446,6 +513,8 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 	}
-
+	for i, r := range reqs {
+		requests[i].params, requests[i].client = r.params, client
+	}
 	return requests, batch, nil
 }
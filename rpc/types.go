@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xdn/go-xdn/common/hexutil"
 	"gopkg.in/fatih/set.v0"
@@ -43,6 +44,7 @@ type callback struct {
 	hasCtx      bool           // method's first argument is a context (not included in argTypes)
 	errPos      int            // err return idx, of -1 when method cannot return error
 	isSubscribe bool           // indication if the callback is a subscription
+	metrics     *methodMetrics // metrics collected about the calls of the method
 }
 
 // service represents a registered object
@@ -59,6 +61,8 @@ type serverRequest struct {
 	svcname       string
 	callb         *callback
 	args          []reflect.Value
+	params        interface{} // raw arguments, retained for slow call logging
+	client        string      // address of the remote client, empty if local
 	isUnsubscribe bool
 	err           Error
 }
@@ -71,6 +75,12 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 type Server struct {
 	services serviceRegistry
 
+	access  AccessList   // Methods allowed to be called on the server
+	limits  Limits       // Resource limits enforced on the clients
+	limiter *rateLimiter // Per client request rate limiter (nil = unlimited)
+
+	slowCall time.Duration // Duration above which method calls are logged
+
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
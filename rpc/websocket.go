@@ -40,7 +40,7 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 	return websocket.Server{
 		Handshake: wsHandshakeValidator(allowedOrigins),
 		Handler: func(conn *websocket.Conn) {
-			srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+			srv.serveRemoteCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions, clientAddress(conn.Request().RemoteAddr))
 		},
 	}
 }
@@ -49,7 +49,13 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 //
 // Deprecated: use Server.WebsocketHandler
 func NewWSServer(allowedOrigins []string, srv *Server) *http.Server {
-	return &http.Server{Handler: srv.WebsocketHandler(allowedOrigins)}
+	return NewWSServerWithAuth(allowedOrigins, nil, srv)
+}
+
+// NewWSServerWithAuth creates a new websocket RPC server around an API provider,
+// rejecting any handshake not authenticated according to auth.
+func NewWSServerWithAuth(allowedOrigins []string, auth *AuthConfig, srv *Server) *http.Server {
+	return &http.Server{Handler: newAuthHandler(auth, srv.WebsocketHandler(allowedOrigins))}
 }
 
 // wsHandshakeValidator returns a handler that verifies the origin during the
@@ -96,6 +102,13 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return DialWebsocketWithAuth(ctx, endpoint, origin, nil)
+}
+
+// DialWebsocketWithAuth creates a new RPC client that communicates with a JSON-RPC
+// server listening on the given endpoint, authenticating the handshake of every
+// (re)connection with the given credentials.
+func DialWebsocketWithAuth(ctx context.Context, endpoint, origin string, auth ClientAuth) (*Client, error) {
 	if origin == "" {
 		var err error
 		if origin, err = os.Hostname(); err != nil {
@@ -113,6 +126,12 @@ func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error
 	}
 
 	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		if auth != nil {
+			config.Header = make(http.Header)
+			if err := auth(config.Header); err != nil {
+				return nil, err
+			}
+		}
 		return wsDialContext(ctx, config)
 	})
 }
@@ -0,0 +1,192 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package core implements the account signing service of the standalone
+// signer, guarding every request with a pluggable approval UI.
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/accounts/keystore"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/crypto"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rlp"
+)
+
+// ErrRequestDenied is returned if a request was rejected by the approval UI.
+var ErrRequestDenied = errors.New("request denied")
+
+// SignerAPI implements the account_ namespace of the signer, signing requests
+// with the keys of a keystore once approved by the UI.
+type SignerAPI struct {
+	chainID *big.Int
+	am      *accounts.Manager
+	ui      SignerUI
+
+	txLock sync.Mutex // Serializes transactions from approval until the UI is notified of the result
+}
+
+// NewSignerAPI creates a signer API for the keystore at the given location,
+// signing transactions for the given chain.
+func NewSignerAPI(chainID int64, keydir string, lightKDF bool, ui SignerUI) *SignerAPI {
+	n, p := keystore.StandardScryptN, keystore.StandardScryptP
+	if lightKDF {
+		n, p = keystore.LightScryptN, keystore.LightScryptP
+	}
+	return &SignerAPI{
+		chainID: big.NewInt(chainID),
+		am:      accounts.NewManager(keystore.NewKeyStore(keydir, n, p)),
+		ui:      ui,
+	}
+}
+
+// List returns the addresses of the accounts the UI approves revealing.
+func (api *SignerAPI) List(ctx context.Context) ([]common.Address, error) {
+	var accs []accounts.Account
+	for _, wallet := range api.am.Wallets() {
+		accs = append(accs, wallet.Accounts()...)
+	}
+	result, err := api.ui.ApproveListing(&ListRequest{Accounts: accs})
+	if err != nil {
+		return nil, err
+	}
+	if result.Accounts == nil {
+		return nil, ErrRequestDenied
+	}
+	addresses := make([]common.Address, 0, len(result.Accounts))
+	for _, acc := range result.Accounts {
+		addresses = append(addresses, acc.Address)
+	}
+	return addresses, nil
+}
+
+// SignTransaction signs the given transaction if approved by the UI, returning
+// the signed transaction both in RLP encoded and JSON form. The transaction is
+// not submitted to any node.
+//
+// Transactions are signed one at a time, so a UI tracking the signed ones (e.g.
+// to enforce spending limits) never approves a request before the previously
+// approved one was recorded via OnApprovedTx.
+func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs) (*SignTxResult, error) {
+	api.txLock.Lock()
+	defer api.txLock.Unlock()
+
+	result, err := api.ui.ApproveTx(&SignTxRequest{Transaction: args})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Approved {
+		return nil, ErrRequestDenied
+	}
+	// The approver may adjust the transaction, but not who signs it
+	if result.Transaction.From != args.From {
+		return nil, fmt.Errorf("sender modified by approver: have %x, want %x", result.Transaction.From, args.From)
+	}
+	account := accounts.Account{Address: args.From}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := wallet.SignTxWithPassphrase(account, result.Password, result.Transaction.toTransaction(), api.chainID)
+	if err != nil {
+		api.ui.ShowError(err.Error())
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	response := SignTxResult{Raw: raw, Tx: signed}
+	api.ui.OnApprovedTx(response)
+
+	log.Info("Signed transaction", "hash", signed.Hash(), "from", args.From, "nonce", signed.Nonce())
+	return &response, nil
+}
+
+// SignData signs the keccak256 hash of the given data, prefixed the same way
+// as the node's personal_sign, if approved by the UI:
+//
+//   keccak256("\x19Dnp Signed Message:\n" + len(data) + data)
+//
+// The V value of the returned signature is 27 or 28.
+func (api *SignerAPI) SignData(ctx context.Context, addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	hash, msg := signHash(data)
+
+	result, err := api.ui.ApproveSignData(&SignDataRequest{Address: addr, Rawdata: data, Message: msg, Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Approved {
+		return nil, ErrRequestDenied
+	}
+	account := accounts.Account{Address: addr}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignHashWithPassphrase(account, result.Password, hash)
+	if err != nil {
+		api.ui.ShowError(err.Error())
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
+// SignTypedData signs the EIP-712 hash of the given structured data, if approved
+// by the UI. The V value of the returned signature is 27 or 28.
+func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.Address, data accounts.TypedData) (hexutil.Bytes, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	request := &SignDataRequest{Address: addr, Message: data.EncodeType(data.PrimaryType), Hash: hash, TypedData: &data}
+
+	result, err := api.ui.ApproveSignData(request)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Approved {
+		return nil, ErrRequestDenied
+	}
+	account := accounts.Account{Address: addr}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignTypedDataWithPassphrase(account, result.Password, &data)
+	if err != nil {
+		api.ui.ShowError(err.Error())
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
+// signHash returns the hash to sign for the given data, along with the prefixed
+// message it was calculated from.
+func signHash(data []byte) ([]byte, string) {
+	msg := fmt.Sprintf("\x19Dnp Signed Message:\n%d%s", len(data), data)
+	return crypto.Keccak256([]byte(msg)), msg
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/console"
+	"github.com/xdn/go-xdn/log"
+)
+
+// CommandlineUI is an interactive SignerUI asking the operator on the terminal
+// to approve every request and to provide the account passwords.
+type CommandlineUI struct {
+	lock sync.Mutex // Serializes the prompts of concurrent requests
+}
+
+// NewCommandlineUI creates an interactive terminal UI.
+func NewCommandlineUI() *CommandlineUI {
+	return &CommandlineUI{}
+}
+
+// confirm asks the operator for a yes/no decision, defaulting to no on errors.
+func (ui *CommandlineUI) confirm(prompt string) bool {
+	ok, err := console.Stdin.PromptConfirm(prompt)
+	if err != nil {
+		log.Warn("Failed to read confirmation", "err", err)
+		return false
+	}
+	return ok
+}
+
+// password asks the operator for the password of an account.
+func (ui *CommandlineUI) password() (string, error) {
+	return console.Stdin.PromptPassword("Password: ")
+}
+
+// ApproveTx implements SignerUI, displaying the transaction and asking for
+// confirmation and the password of the sender.
+func (ui *CommandlineUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	fmt.Printf("--------- Transaction request -------------\n%v\n-------------------------------------------\n", request.Transaction)
+	if !ui.confirm("Approve?") {
+		return SignTxResponse{Transaction: request.Transaction, Approved: false}, nil
+	}
+	password, err := ui.password()
+	if err != nil {
+		return SignTxResponse{}, err
+	}
+	return SignTxResponse{Transaction: request.Transaction, Approved: true, Password: password}, nil
+}
+
+// ApproveSignData implements SignerUI, displaying the data to sign and asking
+// for confirmation and the password of the signer.
+func (ui *CommandlineUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	fmt.Printf("--------- Sign data request ---------------\n")
+	if request.TypedData != nil {
+		domain, _ := json.MarshalIndent(request.TypedData.Domain, "          ", "  ")
+		message, _ := json.MarshalIndent(request.TypedData.Message, "          ", "  ")
+		fmt.Printf("account:  %s\ntype:     %s\ndomain:   %s\nmessage:  %s\nhash:     %v\n", request.Address.Hex(), request.Message, domain, message, request.Hash)
+	} else {
+		fmt.Printf("account:  %s\nmessage:  %q\nraw data: %v\nhash:     %v\n", request.Address.Hex(), request.Message, request.Rawdata, request.Hash)
+	}
+	fmt.Printf("-------------------------------------------\n")
+	if !ui.confirm("Approve?") {
+		return SignDataResponse{Approved: false}, nil
+	}
+	password, err := ui.password()
+	if err != nil {
+		return SignDataResponse{}, err
+	}
+	return SignDataResponse{Approved: true, Password: password}, nil
+}
+
+// ApproveListing implements SignerUI, asking whether the accounts may be listed.
+func (ui *CommandlineUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	fmt.Printf("--------- List accounts request -----------\n")
+	for _, account := range request.Accounts {
+		fmt.Printf("  [x] %s (%s)\n", account.Address.Hex(), account.URL)
+	}
+	fmt.Printf("-------------------------------------------\n")
+	if !ui.confirm("Approve?") {
+		return ListResponse{}, nil
+	}
+	return ListResponse{Accounts: append([]accounts.Account{}, request.Accounts...)}, nil
+}
+
+// ShowError implements SignerUI, printing the error to the terminal.
+func (ui *CommandlineUI) ShowError(message string) {
+	fmt.Printf("ERROR: %v\n", message)
+}
+
+// ShowInfo implements SignerUI, printing the message to the terminal.
+func (ui *CommandlineUI) ShowInfo(message string) {
+	fmt.Printf("INFO: %v\n", message)
+}
+
+// OnApprovedTx implements SignerUI, printing the signed transaction.
+func (ui *CommandlineUI) OnApprovedTx(tx SignTxResult) {
+	fmt.Printf("Transaction signed: %x\n", tx.Tx.Hash())
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core/types"
+)
+
+// SendTxArgs represents the fully specified transaction a signer is requested
+// to sign. Contrary to the node's API, no fields are filled in by the signer.
+type SendTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Big     `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+// String implements fmt.Stringer, formatting the transaction for display.
+func (args SendTxArgs) String() string {
+	to := "contract creation"
+	if args.To != nil {
+		to = args.To.Hex()
+	}
+	return fmt.Sprintf("from:     %s\nto:       %s\nvalue:    %v wei\ngas:      %v\ngasprice: %v wei\nnonce:    %d\ndata:     %s",
+		args.From.Hex(), to, args.Value.ToInt(), args.Gas.ToInt(), args.GasPrice.ToInt(), uint64(args.Nonce), args.Data)
+}
+
+// toTransaction assembles the unsigned transaction from the arguments.
+func (args *SendTxArgs) toTransaction() *types.Transaction {
+	if args.To == nil {
+		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), (*big.Int)(&args.Gas), (*big.Int)(&args.GasPrice), args.Data)
+	}
+	return types.NewTransaction(uint64(args.Nonce), *args.To, (*big.Int)(&args.Value), (*big.Int)(&args.Gas), (*big.Int)(&args.GasPrice), args.Data)
+}
+
+// SignTxRequest is a request to approve a transaction signing.
+type SignTxRequest struct {
+	Transaction SendTxArgs `json:"transaction"`
+}
+
+// SignTxResponse is the answer to a SignTxRequest. The transaction may have
+// been modified by the approver, but not its sender.
+type SignTxResponse struct {
+	Transaction SendTxArgs `json:"transaction"`
+	Approved    bool       `json:"approved"`
+	Password    string     `json:"password"`
+}
+
+// SignDataRequest is a request to approve signing arbitrary data. For EIP-712
+// typed data, TypedData holds the structured message and Rawdata is empty.
+type SignDataRequest struct {
+	Address   common.Address      `json:"address"`
+	Rawdata   hexutil.Bytes       `json:"raw_data"`
+	Message   string              `json:"message"`
+	Hash      hexutil.Bytes       `json:"hash"`
+	TypedData *accounts.TypedData `json:"typed_data,omitempty"`
+}
+
+// SignDataResponse is the answer to a SignDataRequest.
+type SignDataResponse struct {
+	Approved bool   `json:"approved"`
+	Password string `json:"password"`
+}
+
+// ListRequest is a request to approve revealing the signer's accounts.
+type ListRequest struct {
+	Accounts []accounts.Account `json:"accounts"`
+}
+
+// ListResponse is the answer to a ListRequest, containing the accounts which
+// may be revealed.
+type ListResponse struct {
+	Accounts []accounts.Account `json:"accounts"`
+}
+
+// SignTxResult is the result of a transaction signing, containing both the RLP
+// encoded and the JSON form of the signed transaction.
+type SignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// SignerUI is the interface through which signing requests are approved, be
+// it interactively or by a set of rules.
+type SignerUI interface {
+	// ApproveTx prompts the user for confirmation to sign a transaction.
+	ApproveTx(request *SignTxRequest) (SignTxResponse, error)
+
+	// ApproveSignData prompts the user for confirmation to sign data.
+	ApproveSignData(request *SignDataRequest) (SignDataResponse, error)
+
+	// ApproveListing prompts the user for confirmation to list accounts.
+	ApproveListing(request *ListRequest) (ListResponse, error)
+
+	// ShowError displays an error message to the user.
+	ShowError(message string)
+
+	// ShowInfo displays an informational message to the user.
+	ShowInfo(message string)
+
+	// OnApprovedTx notifies the UI about a signed transaction, e.g. to keep
+	// track of spent amounts.
+	OnApprovedTx(tx SignTxResult)
+}
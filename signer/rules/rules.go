@@ -0,0 +1,255 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rules implements a SignerUI evaluating JavaScript rules to approve or
+// reject signing requests automatically, deferring all undecided requests to a
+// fallback UI.
+//
+// A ruleset may define any of the following functions, each receiving the JSON
+// form of the request and returning "Approve", "Reject" or anything else to let
+// the fallback UI decide:
+//
+//   ApproveTx(request)       // account_signTransaction
+//   ApproveSignData(request) // account_signData, account_signTypedData
+//   ApproveListing(request)  // account_list
+//
+// and OnApprovedTx(result) to be notified about signed transactions. Rules may
+// use BigNumber, console.log, and storage.put/storage.get to persist state
+// between requests. E.g. a destination allowlist with a daily value limit:
+//
+//   var allowed = ["0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192"];
+//   var limit = new BigNumber("10000000000000000000"); // 10 coins a day
+//
+//   function spent() {
+//     var day = new Date().toISOString().slice(0, 10);
+//     return { key: "spent-" + day, value: new BigNumber(storage.get("spent-" + day) || "0") };
+//   }
+//   function ApproveTx(r) {
+//     var tx = r.transaction;
+//     if (!tx.to || allowed.indexOf(tx.to.toLowerCase()) < 0) {
+//       return "Reject";
+//     }
+//     var value = new BigNumber(tx.value.slice(2), 16);
+//     if (spent().value.add(value).greaterThan(limit)) {
+//       return "Reject";
+//     }
+//     return "Approve";
+//   }
+//   function OnApprovedTx(res) {
+//     var s = spent();
+//     storage.put(s.key, s.value.add(new BigNumber(res.tx.value.slice(2), 16)).toString(10));
+//   }
+//
+// Transactions are signed one at a time, so the OnApprovedTx of an approved
+// transaction always runs before the ApproveTx of the next one, keeping such
+// limits exact. Requests approved by the rules are signed with the account
+// passwords from the credentials storage, keyed by the lowercase hex address.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xdn/go-xdn/accounts"
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/internal/jsre/deps"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/signer/core"
+	"github.com/xdn/go-xdn/signer/storage"
+	"github.com/robertkrimen/otto"
+)
+
+// bigNumberJS is the BigNumber library made available to the rules.
+var bigNumberJS = deps.MustAsset("bignumber.js")
+
+// RulesetUI is a SignerUI deciding on requests by evaluating a JavaScript
+// ruleset, falling back to another UI for anything the rules don't decide.
+type RulesetUI struct {
+	next        core.SignerUI   // UI to consult if the rules don't decide
+	storage     storage.Storage // Persistent storage available to the rules
+	credentials storage.Storage // Account passwords used for approved requests
+
+	rules string     // JavaScript source of the ruleset
+	lock  sync.Mutex // Serializes rule evaluations, keeping storage updates atomic
+}
+
+// NewRulesetUI creates a rule evaluating UI for the given ruleset source.
+func NewRulesetUI(next core.SignerUI, jsStorage, credentials storage.Storage, rules string) (*RulesetUI, error) {
+	ui := &RulesetUI{
+		next:        next,
+		storage:     jsStorage,
+		credentials: credentials,
+		rules:       rules,
+	}
+	// Make sure the ruleset at least loads before accepting it
+	if _, err := ui.newVM(); err != nil {
+		return nil, err
+	}
+	return ui, nil
+}
+
+// newVM creates a fresh JavaScript environment with the ruleset loaded.
+func (ui *RulesetUI) newVM() (*otto.Otto, error) {
+	vm := otto.New()
+
+	storageObj, _ := vm.Object("({})")
+	storageObj.Set("put", func(call otto.FunctionCall) otto.Value {
+		key, val := call.Argument(0).String(), call.Argument(1).String()
+		ui.storage.Put(key, val)
+		return otto.NullValue()
+	})
+	storageObj.Set("get", func(call otto.FunctionCall) otto.Value {
+		value, _ := vm.ToValue(ui.storage.Get(call.Argument(0).String()))
+		return value
+	})
+	vm.Set("storage", storageObj)
+
+	consoleObj, _ := vm.Object("({})")
+	consoleObj.Set("log", func(call otto.FunctionCall) otto.Value {
+		args := make([]string, len(call.ArgumentList))
+		for i, arg := range call.ArgumentList {
+			args[i] = arg.String()
+		}
+		log.Info("Ruleset: " + strings.Join(args, " "))
+		return otto.UndefinedValue()
+	})
+	vm.Set("console", consoleObj)
+
+	if _, err := vm.Run(string(bigNumberJS)); err != nil {
+		return nil, fmt.Errorf("failed to load BigNumber: %v", err)
+	}
+	if _, err := vm.Run(ui.rules); err != nil {
+		return nil, fmt.Errorf("failed to load ruleset: %v", err)
+	}
+	return vm, nil
+}
+
+// execute calls the given rule function with the JSON form of the argument,
+// returning its string result, or the empty string if the rule is not defined.
+func (ui *RulesetUI) execute(function string, arg interface{}) (string, error) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	blob, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+	vm, err := ui.newVM()
+	if err != nil {
+		return "", err
+	}
+	if fn, err := vm.Get(function); err != nil || !fn.IsFunction() {
+		return "", nil
+	}
+	result, err := vm.Run(fmt.Sprintf("%s(%s)", function, blob))
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v", function, err)
+	}
+	return result.String(), nil
+}
+
+// decide evaluates a rule function, returning whxdner the rules approved or
+// rejected the request, or ok=false if it is left to the fallback UI.
+func (ui *RulesetUI) decide(function string, arg interface{}) (approved bool, ok bool) {
+	result, err := ui.execute(function, arg)
+	if err != nil {
+		log.Warn("Failed to evaluate ruleset", "err", err)
+		return false, false
+	}
+	switch result {
+	case "Approve":
+		log.Info("Request approved by ruleset", "rule", function)
+		return true, true
+	case "Reject":
+		log.Info("Request rejected by ruleset", "rule", function)
+		return false, true
+	}
+	return false, false
+}
+
+// password looks up the stored password of an account.
+func (ui *RulesetUI) password(address common.Address) (string, error) {
+	password := ui.credentials.Get(strings.ToLower(address.Hex()))
+	if password == "" {
+		return "", fmt.Errorf("no credentials stored for %s", address.Hex())
+	}
+	return password, nil
+}
+
+// ApproveTx implements core.SignerUI, consulting the ApproveTx rule.
+func (ui *RulesetUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	approved, ok := ui.decide("ApproveTx", request)
+	if !ok {
+		return ui.next.ApproveTx(request)
+	}
+	if !approved {
+		return core.SignTxResponse{Transaction: request.Transaction, Approved: false}, nil
+	}
+	password, err := ui.password(request.Transaction.From)
+	if err != nil {
+		return core.SignTxResponse{}, err
+	}
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true, Password: password}, nil
+}
+
+// ApproveSignData implements core.SignerUI, consulting the ApproveSignData rule.
+func (ui *RulesetUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	approved, ok := ui.decide("ApproveSignData", request)
+	if !ok {
+		return ui.next.ApproveSignData(request)
+	}
+	if !approved {
+		return core.SignDataResponse{Approved: false}, nil
+	}
+	password, err := ui.password(request.Address)
+	if err != nil {
+		return core.SignDataResponse{}, err
+	}
+	return core.SignDataResponse{Approved: true, Password: password}, nil
+}
+
+// ApproveListing implements core.SignerUI, consulting the ApproveListing rule.
+func (ui *RulesetUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	approved, ok := ui.decide("ApproveListing", request)
+	if !ok {
+		return ui.next.ApproveListing(request)
+	}
+	if !approved {
+		return core.ListResponse{}, nil
+	}
+	return core.ListResponse{Accounts: append([]accounts.Account{}, request.Accounts...)}, nil
+}
+
+// ShowError implements core.SignerUI, forwarding to the fallback UI.
+func (ui *RulesetUI) ShowError(message string) {
+	ui.next.ShowError(message)
+}
+
+// ShowInfo implements core.SignerUI, forwarding to the fallback UI.
+func (ui *RulesetUI) ShowInfo(message string) {
+	ui.next.ShowInfo(message)
+}
+
+// OnApprovedTx implements core.SignerUI, calling the OnApprovedTx rule so it
+// can track the signed transactions, and notifying the fallback UI.
+func (ui *RulesetUI) OnApprovedTx(tx core.SignTxResult) {
+	if _, err := ui.execute("OnApprovedTx", tx); err != nil {
+		log.Warn("Failed to evaluate ruleset", "err", err)
+	}
+	ui.next.OnApprovedTx(tx)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package storage implements the key-value stores used by the signer for rule
+// state and account credentials.
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/xdn/go-xdn/log"
+)
+
+// Storage is a simple string key-value store.
+type Storage interface {
+	// Put stores a value by key, an empty value deleting it.
+	Put(key, value string)
+
+	// Get returns the previously stored value, or the empty string if none.
+	Get(key string) string
+}
+
+// EphemeralStorage is an in-memory storage that does not persist anything.
+type EphemeralStorage struct {
+	data map[string]string
+	lock sync.RWMutex
+}
+
+// NewEphemeralStorage creates an empty in-memory storage.
+func NewEphemeralStorage() *EphemeralStorage {
+	return &EphemeralStorage{data: make(map[string]string)}
+}
+
+// Put implements Storage, storing the value in memory.
+func (s *EphemeralStorage) Put(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if value == "" {
+		delete(s.data, key)
+		return
+	}
+	s.data[key] = value
+}
+
+// Get implements Storage, returning the value from memory.
+func (s *EphemeralStorage) Get(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// JSONStorage is a storage persisting all its values into a JSON file on every
+// update.
+type JSONStorage struct {
+	path string
+	data map[string]string
+	lock sync.RWMutex
+}
+
+// NewJSONStorage opens the JSON file at the given path, creating an empty
+// storage if it does not exist yet.
+func NewJSONStorage(path string) (*JSONStorage, error) {
+	s := &JSONStorage{path: path, data: make(map[string]string)}
+
+	blob, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, &s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put implements Storage, storing the value and flushing the file to disk.
+func (s *JSONStorage) Put(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if value == "" {
+		delete(s.data, key)
+	} else {
+		s.data[key] = value
+	}
+	blob, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		log.Error("Failed to encode storage", "path", s.path, "err", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.path, blob, 0600); err != nil {
+		log.Error("Failed to write storage", "path", s.path, "err", err)
+	}
+}
+
+// Get implements Storage, returning the value from the loaded file.
+func (s *JSONStorage) Get(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
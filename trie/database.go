@@ -0,0 +1,369 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/xdndb"
+)
+
+// LeafCallback is invoked for every value stored in a trie node inserted into a
+// NodeDatabase, returning the hashes of any data the value references (e.g.
+// the storage root and code of an account). These are counted as children of
+// the node so they live exactly as long as it does.
+type LeafCallback func(leaf []byte) []common.Hash
+
+// cachedNode is a trie node or referenced blob held in memory, along with the
+// reference counts needed to garbage collect it.
+type cachedNode struct {
+	blob     []byte              // Encoded node or raw referenced data
+	parents  int                 // Number of live nodes referencing this one
+	children map[common.Hash]int // Referenced children with their multiplicities
+}
+
+// NodeDatabase is an intermediate write layer between the trie data structures
+// and the disk database. Trie nodes are accumulated in memory and only flushed
+// to disk when a root is explicitly committed, while roots no longer needed can
+// be dereferenced to drop all the nodes only they were keeping alive.
+//
+// The zero hash is reserved as a metaroot: referencing a state root from it
+// keeps the whole state in memory until it is dereferenced again.
+type NodeDatabase struct {
+	diskdb xdndb.Database // Persistent storage for matured trie nodes
+	onleaf LeafCallback   // Extractor of references hidden in trie values
+
+	nodes     map[common.Hash]*cachedNode // Data and references of the in-memory nodes
+	preimages map[string][]byte           // Secure trie key preimages pending to be written
+
+	gcnodes uint64             // Nodes garbage collected since the last commit
+	gcsize  common.StorageSize // Data storage garbage collected since the last commit
+	gctime  time.Duration      // Time spent on garbage collection since the last commit
+
+	nodesSize     common.StorageSize // Storage size of the nodes cache
+	preimagesSize common.StorageSize // Storage size of the preimages cache
+
+	lock sync.RWMutex
+}
+
+// NewNodeDatabase creates a new trie node database to store ephemeral trie
+// content before its written out to disk or garbage collected. The optional
+// onleaf callback is used to track references from trie values to other data.
+func NewNodeDatabase(diskdb xdndb.Database, onleaf LeafCallback) *NodeDatabase {
+	return &NodeDatabase{
+		diskdb:    diskdb,
+		onleaf:    onleaf,
+		nodes:     map[common.Hash]*cachedNode{{}: {children: make(map[common.Hash]int)}},
+		preimages: make(map[string][]byte),
+	}
+}
+
+// DiskDB retrieves the persistent storage backing the trie database.
+func (db *NodeDatabase) DiskDB() xdndb.Database {
+	return db.diskdb
+}
+
+// Put implements DatabaseWriter, inserting a trie node or referenced blob into
+// the memory cache. Keys that aren't hashes are secure trie key preimages.
+func (db *NodeDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(key) != hashLen {
+		if _, ok := db.preimages[string(key)]; !ok {
+			db.preimages[string(key)] = common.CopyBytes(value)
+			db.preimagesSize += common.StorageSize(len(key) + len(value))
+		}
+		return nil
+	}
+	hash := common.BytesToHash(key)
+	if _, ok := db.nodes[hash]; ok {
+		return nil
+	}
+	entry := &cachedNode{
+		blob:     common.CopyBytes(value),
+		children: make(map[common.Hash]int),
+	}
+	// Reference every child already in memory, anything else is on disk. Blobs
+	// failing to decode are contract code, which has no children.
+	if n, err := decodeNode(key, value, 0); err == nil {
+		for _, child := range db.references(n) {
+			if c, ok := db.nodes[child]; ok {
+				c.parents++
+				entry.children[child]++
+			}
+		}
+	}
+	db.nodes[hash] = entry
+	db.nodesSize += common.StorageSize(hashLen + len(value))
+	return nil
+}
+
+// references gathers the hashes of the nodes and data directly referenced by a
+// decoded trie node, including the ones hidden inside its values.
+func (db *NodeDatabase) references(n node) []common.Hash {
+	var refs []common.Hash
+	switch n := n.(type) {
+	case *shortNode:
+		refs = append(refs, db.references(n.Val)...)
+	case *fullNode:
+		for _, child := range n.Children {
+			refs = append(refs, db.references(child)...)
+		}
+	case hashNode:
+		refs = append(refs, common.BytesToHash(n))
+	case valueNode:
+		if db.onleaf != nil {
+			refs = append(refs, db.onleaf(n)...)
+		}
+	}
+	return refs
+}
+
+// Get implements DatabaseReader, retrieving a node from memory or, failing
+// that, from the persistent database.
+func (db *NodeDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	if len(key) == hashLen {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return node.blob, nil
+		}
+	} else if preimage, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return preimage, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Get(key)
+}
+
+// Has implements DatabaseReader, checking whether a node is available either
+// in memory or in the persistent database.
+func (db *NodeDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	if len(key) == hashLen {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return true, nil
+		}
+	} else if _, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return true, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Has(key)
+}
+
+// Reference adds a new reference from a parent node to a child node. Use the
+// zero hash as parent to pin a state root in memory.
+func (db *NodeDatabase) Reference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	owner, ok := db.nodes[parent]
+	if !ok {
+		return
+	}
+	// Node references are only counted once, only state roots may be pinned
+	// multiple times by the metaroot
+	if _, ok = owner.children[child]; ok && parent != (common.Hash{}) {
+		return
+	}
+	node.parents++
+	owner.children[child]++
+}
+
+// Dereference removes a reference from a parent node to a child node, deleting
+// every node that is no longer referenced by anything.
+func (db *NodeDatabase) Dereference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	nodes, storage, start := len(db.nodes), db.nodesSize, time.Now()
+	db.dereference(child, parent)
+
+	db.gcnodes += uint64(nodes - len(db.nodes))
+	db.gcsize += storage - db.nodesSize
+	db.gctime += time.Since(start)
+
+	log.Debug("Dereferenced trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+}
+
+// dereference is the private locked version of Dereference.
+func (db *NodeDatabase) dereference(child common.Hash, parent common.Hash) {
+	// Dereference the parent-child
+	if node, ok := db.nodes[parent]; ok {
+		if _, ok := node.children[child]; ok {
+			node.children[child]--
+			if node.children[child] == 0 {
+				delete(node.children, child)
+			}
+		}
+	}
+	// If the child was already flushed or the node is still referenced, stop
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		for hash := range node.children {
+			db.dereference(hash, child)
+		}
+		delete(db.nodes, child)
+		db.nodesSize -= common.StorageSize(hashLen + len(node.blob))
+	}
+}
+
+// Commit iterates over all the children of a particular node, writes them out
+// to disk and drops them from memory. All the pending preimages are written
+// along with them. The report flag controls whether the flush is logged at info
+// or debug level.
+func (db *NodeDatabase) Commit(node common.Hash, report bool) error {
+	// Create the batch outside of the read lock, the disk database may block
+	db.lock.RLock()
+
+	start := time.Now()
+	batch := db.diskdb.NewBatch()
+
+	for key, preimage := range db.preimages {
+		if err := batch.Put([]byte(key), preimage); err != nil {
+			db.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() > xdndb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				db.lock.RUnlock()
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	nodes, storage := len(db.nodes), db.nodesSize
+	if err := db.commit(node, batch); err != nil {
+		log.Error("Failed to commit trie from trie database", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write trie to disk", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Write successful, drop the flushed data from memory
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[string][]byte)
+	db.preimagesSize = 0
+
+	db.uncache(node)
+
+	logger := log.Debug
+	if report {
+		logger = log.Info
+	}
+	logger("Persisted trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+
+	// Reset the garbage collection statistics
+	db.gcnodes, db.gcsize, db.gctime = 0, 0, 0
+
+	return nil
+}
+
+// commit is the private locked version of Commit.
+func (db *NodeDatabase) commit(hash common.Hash, batch xdndb.Batch) error {
+	// If the node does not exist, it's a previously committed node
+	node, ok := db.nodes[hash]
+	if !ok {
+		return nil
+	}
+	for child := range node.children {
+		if err := db.commit(child, batch); err != nil {
+			return err
+		}
+	}
+	if err := batch.Put(hash[:], node.blob); err != nil {
+		return err
+	}
+	// If we've reached an optimal batch size, commit and start over
+	if batch.ValueSize() >= xdndb.IdealBatchSize {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	return nil
+}
+
+// uncache is the post-processing step of a commit operation where the already
+// persisted trie is removed from the cache. The reason behind the two-phase
+// commit is to ensure consistent data availability while moving from memory
+// to disk.
+func (db *NodeDatabase) uncache(hash common.Hash) {
+	// If the node does not exist, we're done on this path
+	node, ok := db.nodes[hash]
+	if !ok {
+		return
+	}
+	// Otherwise uncache the node's subtries and remove the node itself too
+	for child := range node.children {
+		db.uncache(child)
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(hashLen + len(node.blob))
+}
+
+// Size returns the current storage size of the memory cache in front of the
+// persistent database layer, split into trie nodes and key preimages.
+func (db *NodeDatabase) Size() (common.StorageSize, common.StorageSize) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.nodesSize, db.preimagesSize
+}
+
+// Nodes retrieves the hashes of all the nodes cached within the memory database.
+// This method is extremely expensive and should only be used to validate
+// internal states in tests or debugging.
+func (db *NodeDatabase) Nodes() []common.Hash {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var hashes = make([]common.Hash, 0, len(db.nodes))
+	for hash := range db.nodes {
+		if hash != (common.Hash{}) { // Special case for "root" references/nodes
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
@@ -78,7 +78,10 @@ func (b *DnpApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 	if blockNr == rpc.LatestBlockNumber {
 		return b.xdn.blockchain.CurrentBlock(), nil
 	}
-	return b.xdn.blockchain.GetBlockByNumber(uint64(blockNr)), nil
+	if block := b.xdn.blockchain.GetBlockByNumber(uint64(blockNr)); block != nil {
+		return block, nil
+	}
+	return nil, core.CheckHistory(b.xdn.chainDb, uint64(blockNr))
 }
 
 func (b *DnpApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
@@ -97,11 +100,24 @@ func (b *DnpApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 }
 
 func (b *DnpApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
-	return b.xdn.blockchain.GetBlockByHash(blockHash), nil
+	if block := b.xdn.blockchain.GetBlockByHash(blockHash); block != nil {
+		return block, nil
+	}
+	if header := b.xdn.blockchain.GetHeaderByHash(blockHash); header != nil {
+		return nil, core.CheckHistory(b.xdn.chainDb, header.Number.Uint64())
+	}
+	return nil, nil
 }
 
 func (b *DnpApiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
-	return core.GetBlockReceipts(b.xdn.chainDb, blockHash, core.GetBlockNumber(b.xdn.chainDb, blockHash)), nil
+	number := core.GetBlockNumber(b.xdn.chainDb, blockHash)
+	if receipts := core.GetBlockReceipts(b.xdn.chainDb, blockHash, number); receipts != nil {
+		return receipts, nil
+	}
+	if header := b.xdn.blockchain.GetHeaderByHash(blockHash); header != nil {
+		return nil, core.CheckHistory(b.xdn.chainDb, header.Number.Uint64())
+	}
+	return nil, nil
 }
 
 func (b *DnpApiBackend) GetTd(blockHash common.Hash) *big.Int {
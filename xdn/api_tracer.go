@@ -0,0 +1,660 @@
+// Copyright 2018 The go-xdn Authors
+// This file is part of the go-xdn library.
+//
+// The go-xdn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-xdn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-xdn library. If not, see <http://www.gnu.org/licenses/>.
+
+package xdn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/xdn/go-xdn/common"
+	"github.com/xdn/go-xdn/common/hexutil"
+	"github.com/xdn/go-xdn/core"
+	"github.com/xdn/go-xdn/core/state"
+	"github.com/xdn/go-xdn/core/types"
+	"github.com/xdn/go-xdn/core/vm"
+	"github.com/xdn/go-xdn/internal/xdnapi"
+	"github.com/xdn/go-xdn/log"
+	"github.com/xdn/go-xdn/rpc"
+)
+
+// traceCheckpointCacheSize is the number of blocks for which the state before
+// the last traced transaction is retained, so tracing the transactions of a
+// block one by one doesn't replay the block from its start every time.
+const traceCheckpointCacheSize = 16
+
+// txTraceResult is the result of a single transaction trace.
+type txTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// chainTraceResult is the result of tracing a single block of a chain segment,
+// streamed by the traceChain subscription.
+type chainTraceResult struct {
+	Block  hexutil.Uint64   `json:"block"`
+	Hash   common.Hash      `json:"hash"`
+	Traces []*txTraceResult `json:"traces"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// txCheckpoint is the state of a block right before one of its transactions.
+type txCheckpoint struct {
+	index   int
+	statedb *state.StateDB
+}
+
+// blockTraceTask is a block queued for tracing on top of the given state. A
+// task without a block reports that the chain couldn't be walked any further.
+type blockTraceTask struct {
+	number  uint64
+	block   *types.Block
+	statedb *state.StateDB
+	root    common.Hash // State root pinned in the trie database for the tracer, if any
+	traces  []*txTraceResult
+	err     error
+}
+
+// txTraceTask is a transaction queued for tracing on top of the given state.
+type txTraceTask struct {
+	statedb *state.StateDB
+	index   int
+}
+
+// blockByNumber resolves a block number, including the pending and latest
+// aliases, into a block.
+func (api *PrivateDebugAPI) blockByNumber(number rpc.BlockNumber) *types.Block {
+	switch number {
+	case rpc.PendingBlockNumber:
+		// Pending block is only known by the miner
+		return api.xdn.miner.PendingBlock()
+	case rpc.LatestBlockNumber:
+		return api.xdn.blockchain.CurrentBlock()
+	default:
+		return api.xdn.blockchain.GetBlockByNumber(uint64(number))
+	}
+}
+
+// TraceBlockByNumber traces all the transactions of the block with the given
+// number and returns the result of each, in block order.
+func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceArgs) ([]*txTraceResult, error) {
+	block := api.blockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return api.traceBlockTxs(ctx, block, config)
+}
+
+// TraceBlockByHash traces all the transactions of the block with the given hash
+// and returns the result of each, in block order.
+func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceArgs) ([]*txTraceResult, error) {
+	block := api.xdn.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block #%x not found", hash)
+	}
+	return api.traceBlockTxs(ctx, block, config)
+}
+
+// traceBlockTxs traces all the transactions of a block on top of its parent's
+// state, using all available cores.
+func (api *PrivateDebugAPI) traceBlockTxs(ctx context.Context, block *types.Block, config *TraceArgs) ([]*txTraceResult, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent := api.xdn.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	statedb, err := api.xdn.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	return api.traceTxs(ctx, block, statedb, config, runtime.NumCPU())
+}
+
+// traceTxs traces the transactions of a block on top of the given state, which
+// is advanced past them. With multiple threads every transaction is traced on
+// a copy of the state while the original is moved forward, otherwise they are
+// traced in place. Either way, a failing trace is reported in the result of its
+// transaction, and only the failure of the block itself aborts the whole trace.
+func (api *PrivateDebugAPI) traceTxs(ctx context.Context, block *types.Block, statedb *state.StateDB, config *TraceArgs, threads int) ([]*txTraceResult, error) {
+	var (
+		signer             = types.MakeSigner(api.config, block.Number())
+		txs                = block.Transactions()
+		results            = make([]*txTraceResult, len(txs))
+		deleteEmptyObjects = api.config.IsEIP158(block.Number())
+	)
+	if threads > len(txs) {
+		threads = len(txs)
+	}
+	if threads <= 1 {
+		for i, tx := range txs {
+			msg, _ := tx.AsMessage(signer)
+			vmctx := core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil)
+
+			statedb.Prepare(tx.Hash(), block.Hash(), i)
+			snap := statedb.Snapshot()
+			res, err := api.traceTx(ctx, msg, vmctx, statedb, config)
+			if err != nil {
+				// Record the failure and advance the state without the tracer
+				results[i] = &txTraceResult{Error: err.Error()}
+				statedb.RevertToSnapshot(snap)
+
+				vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
+				if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+					return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+				}
+			} else {
+				results[i] = &txTraceResult{Result: res}
+			}
+			statedb.Finalise(deleteEmptyObjects)
+		}
+		return results, nil
+	}
+	var (
+		pend = new(sync.WaitGroup)
+		jobs = make(chan *txTraceTask, len(txs))
+	)
+	for th := 0; th < threads; th++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			for task := range jobs {
+				msg, _ := txs[task.index].AsMessage(signer)
+				vmctx := core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil)
+
+				task.statedb.Prepare(txs[task.index].Hash(), block.Hash(), task.index)
+				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+				if err != nil {
+					results[task.index] = &txTraceResult{Error: err.Error()}
+					continue
+				}
+				results[task.index] = &txTraceResult{Result: res}
+			}
+		}()
+	}
+	// Feed the transactions into the tracers, advancing the state on the way
+	var failed error
+	for i, tx := range txs {
+		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
+
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil)
+
+		vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
+		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			failed = fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+			break
+		}
+		statedb.Finalise(deleteEmptyObjects)
+	}
+	close(jobs)
+	pend.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	return results, nil
+}
+
+// TraceChain traces all the transactions of the blocks between start and end,
+// both inclusive, streaming the results block by block in chain order. Blocks
+// are traced in parallel on top of states derived while walking the chain.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceArgs) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	from := api.blockByNumber(start)
+	if from == nil {
+		return nil, fmt.Errorf("start block #%d not found", start)
+	}
+	to := api.blockByNumber(end)
+	if to == nil {
+		return nil, fmt.Errorf("end block #%d not found", end)
+	}
+	if from.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("end block #%d is before start block #%d", to.NumberU64(), from.NumberU64())
+	}
+	parent := api.xdn.BlockChain().GetBlock(from.ParentHash(), from.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", from.ParentHash())
+	}
+	statedb, err := api.xdn.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+
+	go api.traceChain(ctx, from.NumberU64(), to.NumberU64(), statedb, config, notifier, sub)
+	return sub, nil
+}
+
+// traceChain is the background loop of TraceChain. A feeder walks the chain and
+// hands the state at the start of every block to a pool of tracers, whose
+// results are put back into order before being streamed.
+func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end uint64, statedb *state.StateDB, config *TraceArgs, notifier *rpc.Notifier, sub *rpc.Subscription) {
+	var (
+		blockchain = api.xdn.BlockChain()
+		triedb     = blockchain.StateCache().TrieDB()
+		threads    = runtime.NumCPU()
+		begin      = time.Now()
+	)
+	if blocks := int(end - start + 1); threads > blocks {
+		threads = blocks
+	}
+	var (
+		pend    = new(sync.WaitGroup)
+		tasks   = make(chan *blockTraceTask, threads)
+		results = make(chan *blockTraceTask, threads)
+		slots   = make(chan struct{}, 4*threads) // Bounds the blocks traced ahead of the streamed one
+		abort   = make(chan struct{})
+	)
+	for th := 0; th < threads; th++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			for task := range tasks {
+				if task.err == nil {
+					task.traces, task.err = api.traceTxs(ctx, task.block, task.statedb, config, 1)
+				}
+				task.statedb = nil
+				if task.root != (common.Hash{}) {
+					triedb.Dereference(task.root, common.Hash{})
+				}
+				results <- task
+			}
+		}()
+	}
+	// Walk the chain, handing out the state at the start of every block
+	go func() {
+		defer close(tasks)
+
+		// States executed here are committed to the trie database to keep memory
+		// bounded, and pinned until both the feeder and the tracer are done
+		var proot common.Hash
+		defer func() {
+			if proot != (common.Hash{}) {
+				triedb.Dereference(proot, common.Hash{})
+			}
+		}()
+		send := func(task *blockTraceTask) bool {
+			select {
+			case slots <- struct{}{}:
+			case <-abort:
+				return false
+			}
+			select {
+			case tasks <- task:
+				return true
+			case <-abort:
+				return false
+			}
+		}
+		for number := start; number <= end; number++ {
+			task := &blockTraceTask{number: number, block: blockchain.GetBlockByNumber(number)}
+			if task.block == nil {
+				task.err = fmt.Errorf("block #%d not found", number)
+			} else {
+				task.statedb = statedb.Copy()
+				if proot != (common.Hash{}) {
+					triedb.Reference(proot, common.Hash{})
+					task.root = proot
+				}
+			}
+			if !send(task) {
+				if task.root != (common.Hash{}) {
+					triedb.Dereference(task.root, common.Hash{})
+				}
+				return
+			}
+			if task.err != nil {
+				return
+			}
+			// Advance to the next block, reusing its state from the database if
+			// it's available there and only executing the block otherwise
+			if next, err := blockchain.StateAt(task.block.Root()); err == nil {
+				statedb = next
+				continue
+			}
+			fail := func(err error) {
+				if number < end {
+					send(&blockTraceTask{number: number + 1, err: fmt.Errorf("processing block #%d failed: %v", number, err)})
+				}
+			}
+			if _, _, _, err := blockchain.Processor().Process(task.block, statedb, vm.Config{}); err != nil {
+				fail(err)
+				return
+			}
+			// Commit the state every block, so dirty objects don't pile up, and
+			// release the previous one
+			root, err := statedb.CommitTo(triedb, api.config.IsEIP158(task.block.Number()))
+			if err == nil {
+				statedb, err = state.New(root, blockchain.StateCache())
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+			triedb.Reference(root, common.Hash{})
+			if proot != (common.Hash{}) {
+				triedb.Dereference(proot, common.Hash{})
+			}
+			proot = root
+		}
+	}()
+	// Close the results once all tracers are done
+	go func() {
+		pend.Wait()
+		close(results)
+	}()
+	// Reorder the traced blocks and stream them until done or unsubscribed
+	var (
+		done = make(map[uint64]*blockTraceTask)
+		next = start
+	)
+	for {
+		select {
+		case task, ok := <-results:
+			if !ok {
+				log.Info("Chain tracing finished", "start", start, "end", end, "elapsed", common.PrettyDuration(time.Since(begin)))
+				return
+			}
+			done[task.number] = task
+			for task, ok := done[next]; ok; task, ok = done[next] {
+				result := &chainTraceResult{Block: hexutil.Uint64(next), Traces: task.traces}
+				if task.block != nil {
+					result.Hash = task.block.Hash()
+				}
+				if task.err != nil {
+					result.Error = task.err.Error()
+				}
+				notifier.Notify(sub.ID, result)
+				delete(done, next)
+				next++
+				<-slots
+
+				if task.err != nil {
+					close(abort)
+					go drain(results)
+					return
+				}
+			}
+
+		case <-sub.Err():
+			close(abort)
+			go drain(results)
+			return
+
+		case <-notifier.Closed():
+			close(abort)
+			go drain(results)
+			return
+		}
+	}
+}
+
+// drain discards all remaining tasks so the tracers can terminate.
+func drain(results chan *blockTraceTask) {
+	for range results {
+	}
+}
+
+// TraceTransaction returns the structured logs created during the execution of EVM
+// and returns them as a JSON object.
+func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
+	// Retrieve the tx from the chain and the containing block
+	tx, blockHash, _, txIndex := core.GetTransaction(api.xdn.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, vmctx, statedb, err := api.computeTxEnv(blockHash, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	return api.traceTx(ctx, msg, vmctx, statedb, config)
+}
+
+// traceTx runs a single message on the given state with the tracer selected by
+// the config and returns the tracer's result.
+func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceArgs) (interface{}, error) {
+	var tracer vm.Tracer
+	if config != nil && config.Tracer != nil {
+		timeout := defaultTraceTimeout
+		if config.Timeout != nil {
+			var err error
+			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		if tracer, err = xdnapi.NewTracer(*config.Tracer); err != nil {
+			return nil, err
+		}
+
+		// Handle timeouts and RPC cancellations
+		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+		go func() {
+			<-deadlineCtx.Done()
+			tracer.(xdnapi.ResultTracer).Stop(&timeoutError{})
+		}()
+		defer cancel()
+	} else if config == nil {
+		tracer = vm.NewStructLogger(nil)
+	} else {
+		tracer = vm.NewStructLogger(config.LogConfig)
+	}
+
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &xdnapi.ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  xdnapi.FormatLogs(tracer.StructLogs()),
+		}, nil
+	case xdnapi.ResultTracer:
+		return tracer.GetResult()
+	default:
+		panic(fmt.Sprintf("bad tracer type %T", tracer))
+	}
+}
+
+// computeTxEnv returns the execution environment of a certain transaction. The
+// state is derived from the closest checkpoint left by an earlier call for the
+// same block, so tracing a block's transactions one by one stays linear.
+func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, vm.Context, *state.StateDB, error) {
+	block := api.xdn.BlockChain().GetBlockByHash(blockHash)
+	if block == nil {
+		return nil, vm.Context{}, nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	txs := block.Transactions()
+	if txIndex < 0 || txIndex >= len(txs) {
+		return nil, vm.Context{}, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
+	}
+	// Start from the closest cached state, or the parent state if there's none
+	var (
+		start   int
+		statedb *state.StateDB
+	)
+	if cached, ok := api.checkpoints.Get(blockHash); ok {
+		if checkpoint := cached.(*txCheckpoint); checkpoint.index <= txIndex {
+			start, statedb = checkpoint.index, checkpoint.statedb.Copy()
+		}
+	}
+	if statedb == nil {
+		parent := api.xdn.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return nil, vm.Context{}, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+		}
+		var err error
+		if statedb, err = api.xdn.BlockChain().StateAt(parent.Root()); err != nil {
+			return nil, vm.Context{}, nil, err
+		}
+	}
+	// Recompute transactions up to the target index.
+	signer := types.MakeSigner(api.config, block.Number())
+	for idx := start; idx < txIndex; idx++ {
+		// Assemble the transaction call message
+		msg, _ := txs[idx].AsMessage(signer)
+		context := core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil)
+
+		statedb.Prepare(txs[idx].Hash(), blockHash, idx)
+		vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{})
+		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(txs[idx].Gas())); err != nil {
+			return nil, vm.Context{}, nil, fmt.Errorf("tx %x failed: %v", txs[idx].Hash(), err)
+		}
+		statedb.Finalise(api.config.IsEIP158(block.Number()))
+	}
+	// Remember where we got to, the returned state is mutated by the caller
+	api.checkpoints.Add(blockHash, &txCheckpoint{index: txIndex, statedb: statedb.Copy()})
+
+	msg, _ := txs[txIndex].AsMessage(signer)
+	statedb.Prepare(txs[txIndex].Hash(), blockHash, txIndex)
+
+	return msg, core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil), statedb, nil
+}
+
+// StdTraceConfig holds extra parameters to the standard JSON trace functions.
+type StdTraceConfig struct {
+	*vm.LogConfig
+	TxHash *common.Hash // Only trace the given transaction if set
+}
+
+// StandardTraceBlockToFile re-executes the block with the given hash and dumps
+// the per-opcode JSON trace of each of its transactions into a file of its own
+// in the data directory, returning the file paths in transaction order.
+func (api *PrivateDebugAPI) StandardTraceBlockToFile(ctx context.Context, hash common.Hash, config *StdTraceConfig) ([]string, error) {
+	block := api.xdn.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	return api.standardTraceBlockToFile(ctx, block, config)
+}
+
+// StandardTraceBadBlockToFile does the same as StandardTraceBlockToFile for a
+// block rejected by the chain, as returned by GetBadBlocks.
+func (api *PrivateDebugAPI) StandardTraceBadBlockToFile(ctx context.Context, hash common.Hash, config *StdTraceConfig) ([]string, error) {
+	block := api.xdn.BlockChain().BadBlock(hash)
+	if block == nil {
+		return nil, fmt.Errorf("bad block %x not found", hash)
+	}
+	return api.standardTraceBlockToFile(ctx, block, config)
+}
+
+// standardTraceBlockToFile executes all the transactions of a block on top of
+// its parent's state, tracing them into JSON lines files.
+func (api *PrivateDebugAPI) standardTraceBlockToFile(ctx context.Context, block *types.Block, config *StdTraceConfig) ([]string, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent := api.xdn.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	statedb, err := api.xdn.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		logConfig *vm.LogConfig
+		txHash    *common.Hash
+	)
+	if config != nil {
+		logConfig, txHash = config.LogConfig, config.TxHash
+	}
+	// Ephemeral nodes have no data directory, fall back to the system's one
+	dir := api.xdn.traceDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var (
+		signer = types.MakeSigner(api.config, block.Number())
+		dumps  []string
+	)
+	for i, tx := range block.Transactions() {
+		select {
+		case <-ctx.Done():
+			return dumps, ctx.Err()
+		default:
+		}
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, block.Header(), api.xdn.BlockChain(), nil)
+
+		// Trace the transaction into its own file if it was requested
+		var (
+			vmConf vm.Config
+			dump   *os.File
+			writer *bufio.Writer
+		)
+		if txHash == nil || *txHash == tx.Hash() {
+			prefix := fmt.Sprintf("block_%#x-%d-%#x-", block.Hash().Bytes()[:4], i, tx.Hash().Bytes()[:4])
+			if dump, err = ioutil.TempFile(dir, prefix); err != nil {
+				return dumps, err
+			}
+			dumps = append(dumps, dump.Name())
+
+			writer = bufio.NewWriter(dump)
+			vmConf = vm.Config{Debug: true, Tracer: vm.NewJSONLogger(logConfig, writer)}
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		vmenv := vm.NewEVM(vmctx, statedb, api.config, vmConf)
+		_, _, _, err = core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+		if writer != nil {
+			if ferr := writer.Flush(); ferr != nil && err == nil {
+				err = ferr
+			}
+			dump.Close()
+			log.Info("Wrote standard trace", "file", dump.Name())
+		}
+		if err != nil {
+			return dumps, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		statedb.Finalise(api.config.IsEIP158(block.Number()))
+
+		// If we've traced the requested transaction, the rest can be skipped
+		if txHash != nil && *txHash == tx.Hash() {
+			return dumps, nil
+		}
+	}
+	if txHash != nil {
+		return nil, fmt.Errorf("transaction %x not found in block %x", *txHash, block.Hash())
+	}
+	return dumps, nil
+}
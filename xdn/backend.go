@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -32,6 +33,7 @@ import (
 	// "github.com/xdn/go-xdn/consensus/clique"
 	// "github.com/xdn/go-xdn/consensus/ethash"
 	"github.com/xdn/go-xdn/consensus/xdnoc"
+	"github.com/xdn/go-xdn/contracts/multisig"
 	"github.com/xdn/go-xdn/core"
 	"github.com/xdn/go-xdn/core/bloombits"
 	"github.com/xdn/go-xdn/core/types"
@@ -76,6 +78,8 @@ type Dnp struct {
 	// DB interfaces
 	chainDb xdndb.Database // Block chain database
 
+	traceDir string // Directory for the trace files written by the debug API
+
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
 	accountManager *accounts.Manager
@@ -109,11 +113,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Dnp, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := CreateFreezerDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
 	}
-	stopDbUpgrade := upgradeDeduplicateData(chainDb)
+	stopDbUpgrade := upgradeDeduplicateData(core.KeyValueStore(chainDb))
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -134,6 +138,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Dnp, error) {
 		xdnerbase:      config.Dnperbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+		traceDir:       ctx.ResolvePath("traces"),
 	}
 
 	log.Info("Initialising Dnp protocol", "versions", ProtocolVersions, "network", config.NetworkId)
@@ -146,8 +151,18 @@ func New(ctx *node.ServiceContext, config *Config) (*Dnp, error) {
 		core.WriteBlockChainVersion(chainDb, core.BlockChainVersion)
 	}
 
-	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-	xdn.blockchain, err = core.NewBlockChain(chainDb, xdn.chainConfig, xdn.engine, vmConfig)
+	var (
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		cacheConfig = &core.CacheConfig{
+			Disabled:          config.NoPruning,
+			SnapshotDisabled:  config.NoSnapshot,
+			TrieNodeLimit:     config.TrieCache,
+			TrieTimeLimit:     config.TrieTimeout,
+			BodyRetention:     config.BodyRetention,
+			TxLookupRetention: config.TxLookupRetention,
+		}
+	)
+	xdn.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, xdn.chainConfig, xdn.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +224,33 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (xdndb.Data
 	return db, nil
 }
 
+// CreateFreezerDB creates the chain database with an ancient store attached,
+// which the immutable chain segments are moved into. Ephemeral databases have
+// no ancient store.
+func CreateFreezerDB(ctx *node.ServiceContext, config *Config, name string) (xdndb.Database, error) {
+	db, err := CreateDB(ctx, config, name)
+	if err != nil {
+		return nil, err
+	}
+	dbdir := ctx.ResolvePath(name)
+	if dbdir == "" {
+		return db, nil
+	}
+	freezer := config.DatabaseFreezer
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(dbdir, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = ctx.ResolvePath(freezer)
+	}
+	frdb, err := core.NewDatabaseWithFreezer(db, freezer, "xdn/db/"+name+"/ancient/", false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Dnp service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *Config, chainConfig *params.ChainConfig, db xdndb.Database) consensus.Engine {
 	//If proof-of-authority is requested, set it up
@@ -283,6 +325,10 @@ func (s *Dnp) APIs() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(s.chainConfig, s),
+		}, {
+			Namespace: "multisig",
+			Version:   "1.0",
+			Service:   multisig.NewPrivateMultisigAPI(s.ApiBackend, NewContractBackend(s.ApiBackend)),
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -409,6 +455,7 @@ func (s *Dnp) Stop() error {
 	}
 	s.txPool.Stop()
 	s.miner.Stop()
+	s.engine.Close()
 	s.eventMux.Stop()
 
 	s.chainDb.Close()
@@ -22,6 +22,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/xdn/go-xdn/common"
 	"github.com/xdn/go-xdn/common/hexutil"
@@ -42,6 +43,8 @@ var DefaultConfig = Config{
 	NetworkId:            1,
 	LightPeers:           20,
 	DatabaseCache:        128,
+	TrieCache:            256,
+	TrieTimeout:          5 * time.Minute,
 	GasPrice:             big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
@@ -84,6 +87,17 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string // Directory of the ancient chain store, defaults to inside the chain database
+
+	// State pruning options
+	NoPruning   bool          // Whether to keep every historical state on disk (archive node)
+	TrieCache   int           // Memory allowance (MB) of the in-memory trie node cache
+	TrieTimeout time.Duration // Time limit after which the in-memory tries are flushed to disk
+	NoSnapshot  bool          // Whether to skip maintaining the flat state snapshot
+
+	// History pruning options
+	BodyRetention     uint64 // Number of recent blocks to keep bodies and receipts for (0 = all)
+	TxLookupRetention uint64 // Number of recent blocks to keep transaction lookups for (0 = all)
 
 	// Mining-related options
 	Dnperbase    common.Address `toml:",omitempty"`
@@ -90,6 +90,12 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// HistoryPeer is implemented by peers that advertise the oldest block whose
+// body and receipts they are able to serve.
+type HistoryPeer interface {
+	HistoryTail() uint64
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -346,6 +352,17 @@ func (p *peerConnection) Lacks(hash common.Hash) bool {
 	return ok
 }
 
+// Pruned retrieves whxdner the peer advertised that it pruned the body and
+// receipts of the block with the given number.
+func (p *peerConnection) Pruned(number uint64) bool {
+	hp, ok := p.peer.(HistoryPeer)
+	if !ok {
+		return false
+	}
+	tail := hp.HistoryTail()
+	return number > 0 && number < tail
+}
+
 // peerSet represents the collection of active peer participating in the chain
 // download procedure.
 type peerSet struct {
@@ -511,8 +511,8 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 			progress = true
 			continue
 		}
-		// Otherwise unless the peer is known not to have the data, add to the retrieve list
-		if p.Lacks(header.Hash()) {
+		// Otherwise unless the peer is known not to have (or have pruned) the data, add to the retrieve list
+		if p.Lacks(header.Hash()) || p.Pruned(header.Number.Uint64()) {
 			skip = append(skip, header)
 		} else {
 			send = append(send, header)
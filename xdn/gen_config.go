@@ -4,6 +4,7 @@ package xdn
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/xdn/go-xdn/common"
 	"github.com/xdn/go-xdn/common/hexutil"
@@ -23,6 +24,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SkipBcVersionCheck      bool `toml:"-"`
 		DatabaseHandles         int  `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string
+		NoPruning               bool
+		TrieCache               int
+		TrieTimeout             time.Duration
+		NoSnapshot              bool
+		BodyRetention           uint64
+		TxLookupRetention       uint64
 		Dnperbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
@@ -50,6 +58,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.NoPruning = c.NoPruning
+	enc.TrieCache = c.TrieCache
+	enc.TrieTimeout = c.TrieTimeout
+	enc.NoSnapshot = c.NoSnapshot
+	enc.BodyRetention = c.BodyRetention
+	enc.TxLookupRetention = c.TxLookupRetention
 	enc.Dnperbase = c.Dnperbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
@@ -81,6 +96,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SkipBcVersionCheck      *bool `toml:"-"`
 		DatabaseHandles         *int  `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string
+		NoPruning               *bool
+		TrieCache               *int
+		TrieTimeout             *time.Duration
+		NoSnapshot              *bool
+		BodyRetention           *uint64
+		TxLookupRetention       *uint64
 		Dnperbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
@@ -127,6 +149,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.NoPruning != nil {
+		c.NoPruning = *dec.NoPruning
+	}
+	if dec.TrieCache != nil {
+		c.TrieCache = *dec.TrieCache
+	}
+	if dec.TrieTimeout != nil {
+		c.TrieTimeout = *dec.TrieTimeout
+	}
+	if dec.NoSnapshot != nil {
+		c.NoSnapshot = *dec.NoSnapshot
+	}
+	if dec.BodyRetention != nil {
+		c.BodyRetention = *dec.BodyRetention
+	}
+	if dec.TxLookupRetention != nil {
+		c.TxLookupRetention = *dec.TxLookupRetention
+	}
 	if dec.Dnperbase != nil {
 		c.Dnperbase = *dec.Dnperbase
 	}
@@ -217,6 +217,7 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
+	go pm.historyAnnounceLoop()
 }
 
 func (pm *ProtocolManager) Stop() {
@@ -258,7 +259,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	// Execute the Dnp handshake
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	if err := p.Handshake(pm.networkId, td, head, genesis, pm.blockchain.HistoryTail()); err != nil {
 		p.Log().Debug("Dnp handshake failed", "err", err)
 		return err
 	}
@@ -532,7 +533,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
 			// Retrieve the requested state entry, stopping if enough was found
-			if entry, err := pm.chaindb.Get(hash.Bytes()); err == nil {
+			if entry, err := pm.blockchain.StateCache().TrieDB().Get(hash.Bytes()); err == nil {
 				data = append(data, entry)
 				bytes += len(entry)
 			}
@@ -597,6 +598,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
+	case p.version >= xdn64 && msg.Code == HistoryTailMsg:
+		// The peer pruned its history further, stop requesting data below it
+		var tail uint64
+		if err := msg.Decode(&tail); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.SetHistoryTail(tail)
+
 	case msg.Code == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
@@ -48,6 +48,7 @@ type PeerInfo struct {
 	Version    int      `json:"version"`    // Dnp protocol version negotiated
 	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
 	Head       string   `json:"head"`       // SHA3 hash of the peer's best owned block
+	Tail       uint64   `json:"tail"`       // Oldest block whose body and receipts are served
 }
 
 type peer struct {
@@ -61,6 +62,7 @@ type peer struct {
 
 	head common.Hash
 	td   *big.Int
+	tail uint64 // Oldest block whose body and receipts the peer serves
 	lock sync.RWMutex
 
 	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
@@ -88,9 +90,29 @@ func (p *peer) Info() *PeerInfo {
 		Version:    p.version,
 		Difficulty: td,
 		Head:       hash.Hex(),
+		Tail:       p.HistoryTail(),
 	}
 }
 
+// HistoryTail retrieves the number of the oldest block whose body and receipts
+// the peer advertised to serve. Peers running legacy protocol versions always
+// report zero, meaning the entire chain.
+func (p *peer) HistoryTail() uint64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.tail
+}
+
+// SetHistoryTail updates the number of the oldest block whose body and receipts
+// the peer serves.
+func (p *peer) SetHistoryTail(tail uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.tail = tail
+}
+
 // Head retrieves a copy of the current head hash and total difficulty of the
 // peer.
 func (p *peer) Head() (hash common.Hash, td *big.Int) {
@@ -159,6 +181,12 @@ func (p *peer) SendNewBlock(block *types.Block, td *big.Int) error {
 	return p2p.Send(p.rw, NewBlockMsg, []interface{}{block, td})
 }
 
+// SendHistoryTail announces the number of the oldest block whose body and
+// receipts the local node still serves, after the history was pruned further.
+func (p *peer) SendHistoryTail(tail uint64) error {
+	return p2p.Send(p.rw, HistoryTailMsg, tail)
+}
+
 // SendBlockHeaders sends a batch of block headers to the remote peer.
 func (p *peer) SendBlockHeaders(headers []*types.Header) error {
 	return p2p.Send(p.rw, BlockHeadersMsg, headers)
@@ -229,19 +257,31 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the xdn protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, and since xdn/64 also the
+// history tail of the chains.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, tail uint64) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
+	var status statusData64 // safe to read after two values have been received from errc
 
 	go func() {
-		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+		if p.version < xdn64 {
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+			})
+			return
+		}
+		errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
 			ProtocolVersion: uint32(p.version),
 			NetworkId:       network,
 			TD:              td,
 			CurrentBlock:    head,
 			GenesisBlock:    genesis,
+			HistoryTail:     tail,
 		})
 	}()
 	go func() {
@@ -259,11 +299,11 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	p.td, p.head, p.tail = status.TD, status.CurrentBlock, status.HistoryTail
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+func (p *peer) readStatus(network uint64, status *statusData64, genesis common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -275,7 +315,19 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
 	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
+	if p.version < xdn64 {
+		var legacy statusData
+		if err := msg.Decode(&legacy); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		*status = statusData64{
+			ProtocolVersion: legacy.ProtocolVersion,
+			NetworkId:       legacy.NetworkId,
+			TD:              legacy.TD,
+			CurrentBlock:    legacy.CurrentBlock,
+			GenesisBlock:    legacy.GenesisBlock,
+		}
+	} else if err := msg.Decode(&status); err != nil {
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
@@ -387,6 +439,21 @@ func (ps *peerSet) PeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
+// PeersWithHistoryTail retrieves a list of peers running a protocol version able
+// to receive history tail announcements.
+func (ps *peerSet) PeersWithHistoryTail() []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.version >= xdn64 {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // BestPeer retrieves the known peer with the currently highest total difficulty.
 func (ps *peerSet) BestPeer() *peer {
 	ps.lock.RLock()
@@ -32,16 +32,17 @@ import (
 const (
 	xdn62 = 62
 	xdn63 = 63
+	xdn64 = 64
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "xdn"
 
 // Supported versions of the xdn protocol (first is primary).
-var ProtocolVersions = []uint{xdn63, xdn62}
+var ProtocolVersions = []uint{xdn64, xdn63, xdn62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{18, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +63,9 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to xdn/64
+	HistoryTailMsg = 0x11
 )
 
 type errCode int
@@ -117,6 +121,18 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// statusData64 is the network packet for the status message since xdn/64. It
+// extends the legacy one with the oldest block whose body and receipts are
+// still served, allowing pruned nodes to advertise their available history.
+type statusData64 struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	HistoryTail     uint64
+}
+
 // newBlockHashesData is the network packet for the block announcements.
 type newBlockHashesData []struct {
 	Hash   common.Hash // Hash of one particular block being announced
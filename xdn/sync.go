@@ -32,6 +32,8 @@ const (
 	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
 	minDesiredPeerCount = 5                // Amount of peers desired to start syncing
 
+	historyAnnounceCycle = time.Minute // Time interval to check and announce history tail changes
+
 	// This is the target size for the packs of transactions sent by txsyncLoop.
 	// A pack can get larger than this if a single transactions exceeds this size.
 	txsyncPackSize = 100 * 1024
@@ -212,3 +214,32 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		go pm.BroadcastBlock(head, false)
 	}
 }
+
+// historyAnnounceLoop periodically checks whxdner the local history tail moved
+// due to pruning and if so, announces the new tail to all xdn/64 peers, so they
+// stop requesting bodies and receipts that are no longer served.
+func (pm *ProtocolManager) historyAnnounceLoop() {
+	announce := time.NewTicker(historyAnnounceCycle)
+	defer announce.Stop()
+
+	last := pm.blockchain.HistoryTail()
+	for {
+		select {
+		case <-announce.C:
+			tail := pm.blockchain.HistoryTail()
+			if tail == last {
+				break
+			}
+			last = tail
+
+			peers := pm.peers.PeersWithHistoryTail()
+			for _, peer := range peers {
+				peer.SendHistoryTail(tail)
+			}
+			log.Debug("Announced history tail", "number", tail, "recipients", len(peers))
+
+		case <-pm.quitSync:
+			return
+		}
+	}
+}